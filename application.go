@@ -0,0 +1,258 @@
+package nodemodulebom
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// GitCommitProperty records the commit that the application was built
+	// from.
+	GitCommitProperty = "paketo:node-module-bom:git:commit"
+
+	// GitBranchProperty records the branch that the application was built
+	// from.
+	GitBranchProperty = "paketo:node-module-bom:git:branch"
+)
+
+// Application describes the application itself, from its package.json and
+// the metadata of its git repository.
+type Application struct {
+	Name         string
+	Version      string
+	License      string
+	Repository   string
+	Commit       string
+	Branch       string
+	Dependencies map[string]string
+}
+
+// ReadApplication reads the application in the given working directory. The
+// returned bool is false when the application has no package.json. The name
+// of the working directory is used when the package.json has no name.
+func ReadApplication(workingDir string) (Application, bool, error) {
+	pkg, err := readPackageJSON(filepath.Join(workingDir, "package.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Application{}, false, nil
+		}
+
+		return Application{}, false, err
+	}
+
+	application := Application{
+		Name:         pkg.Name,
+		Version:      pkg.Version,
+		License:      pkg.LicenseID(),
+		Repository:   pkg.RepositoryURL(),
+		Dependencies: mergeDependencies(pkg.Dependencies, pkg.OptionalDependencies, pkg.DevDependencies),
+	}
+
+	if application.Name == "" {
+		application.Name = filepath.Base(workingDir)
+	}
+
+	commit, branch, remote, err := readGitMetadata(filepath.Join(workingDir, ".git"))
+	if err != nil {
+		return Application{}, false, err
+	}
+
+	application.Commit = commit
+	application.Branch = branch
+	if application.Repository == "" {
+		application.Repository = remote
+	}
+
+	return application, true, nil
+}
+
+// Ref returns the CycloneDX bom-ref that identifies the application in the
+// dependency graph.
+func (a Application) Ref() string {
+	return PackageURL(a.Name, a.Version, nil)
+}
+
+// Component returns the CycloneDX component describing the application,
+// which becomes the metadata component of the BOM.
+func (a Application) Component() Component {
+	group, name := splitPackageName(a.Name)
+
+	component := Component{
+		BOMRef:   a.Ref(),
+		Type:     "application",
+		Group:    group,
+		Name:     name,
+		Version:  a.Version,
+		PURL:     a.Ref(),
+		Licenses: licenseChoices(a.License),
+	}
+
+	if a.Repository != "" {
+		component.ExternalReferences = []ExternalReference{{Type: "vcs", URL: a.Repository}}
+	}
+
+	if a.Commit != "" {
+		component.Properties = append(component.Properties, Property{Name: GitCommitProperty, Value: a.Commit})
+	}
+
+	if a.Branch != "" {
+		component.Properties = append(component.Properties, Property{Name: GitBranchProperty, Value: a.Branch})
+	}
+
+	return component
+}
+
+// readGitMetadata reads the commit and branch that HEAD points to, along with
+// the URL of the "origin" remote, from the given .git directory. Nothing is
+// returned when the directory does not exist.
+func readGitMetadata(gitDir string) (string, string, string, error) {
+	info, err := os.Stat(gitDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", "", "", nil
+		}
+
+		return "", "", "", fmt.Errorf("failed to stat .git: %w", err)
+	}
+
+	// The .git of worktrees and submodules is a file that points to the git
+	// directory.
+	if !info.IsDir() {
+		content, err := os.ReadFile(gitDir)
+		if err != nil {
+			return "", "", "", fmt.Errorf("failed to read .git: %w", err)
+		}
+
+		target, ok := cutPrefix(strings.TrimSpace(string(content)), "gitdir: ")
+		if !ok {
+			return "", "", "", nil
+		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(gitDir), target)
+		}
+		gitDir = target
+	}
+
+	content, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", "", "", nil
+		}
+
+		return "", "", "", fmt.Errorf("failed to read git HEAD: %w", err)
+	}
+
+	var commit, branch string
+
+	head := strings.TrimSpace(string(content))
+	if ref, ok := cutPrefix(head, "ref: "); ok {
+		branch = strings.TrimPrefix(ref, "refs/heads/")
+
+		commit, err = resolveGitRef(gitDir, ref)
+		if err != nil {
+			return "", "", "", err
+		}
+	} else {
+		commit = head
+	}
+
+	remote, err := readGitRemote(gitDir, "origin")
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return commit, branch, remote, nil
+}
+
+// resolveGitRef returns the commit of the given ref from either its loose
+// ref file or the packed-refs file, or nothing for a branch without commits.
+func resolveGitRef(gitDir, ref string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(gitDir, filepath.FromSlash(ref)))
+	if err == nil {
+		return strings.TrimSpace(string(content)), nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read git ref %s: %w", ref, err)
+	}
+
+	file, err := os.Open(filepath.Join(gitDir, "packed-refs"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("failed to read git packed-refs: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == ref {
+			return fields[0], nil
+		}
+	}
+
+	return "", scanner.Err()
+}
+
+// readGitRemote returns the URL of the named remote from the git config.
+func readGitRemote(gitDir, name string) (string, error) {
+	file, err := os.Open(filepath.Join(gitDir, "config"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("failed to read git config: %w", err)
+	}
+	defer file.Close()
+
+	var inRemote bool
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if strings.HasPrefix(line, "[") {
+			inRemote = line == fmt.Sprintf(`[remote "%s"]`, name)
+			continue
+		}
+
+		if !inRemote {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "url" {
+			return redactURL(strings.TrimSpace(value)), nil
+		}
+	}
+
+	return "", scanner.Err()
+}
+
+// redactURL removes any credentials from the URL of a remote.
+func redactURL(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || u.User == nil {
+		return remote
+	}
+
+	u.User = nil
+	return u.String()
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+
+	return s[len(prefix):], true
+}
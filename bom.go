@@ -0,0 +1,376 @@
+package nodemodulebom
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+
+	//nolint Ignore SA1019, informed usage of deprecated package
+	"github.com/paketo-buildpacks/packit/v2/paketosbom"
+)
+
+const (
+	// CycloneDXSpecVersion is the version of the CycloneDX specification that
+	// generated BOMs conform to. It is 1.5 rather than 1.4 because the
+	// evidence occurrences that record every installed copy of a component
+	// were introduced in 1.5.
+	CycloneDXSpecVersion = "1.5"
+
+	// LockfileProperty records the lockfile that the BOM was derived from.
+	LockfileProperty = "paketo:node-module-bom:lockfile"
+
+	// LockfileSHA256Property records the SHA-256 digest of the lockfile that
+	// the BOM was derived from.
+	LockfileSHA256Property = "paketo:node-module-bom:lockfile:sha256"
+
+	// InstalledTreeProperty records the source of the installed tree that the
+	// BOM components were limited to.
+	InstalledTreeProperty = "paketo:node-module-bom:installed-tree"
+
+	// NotInstalledProperty records the purl of an optional or platform-specific
+	// package of the lockfile that was not installed. It is only included in
+	// the build SBOM.
+	NotInstalledProperty = "paketo:node-module-bom:not-installed"
+
+	// IncompleteProperty records why the BOM does not describe every
+	// installed package, such as generation timing out.
+	IncompleteProperty = "paketo:node-module-bom:incomplete"
+)
+
+// BOM is a CycloneDX document describing the node modules of an application.
+type BOM struct {
+	BOMFormat    string        `json:"bomFormat"`
+	SpecVersion  string        `json:"specVersion"`
+	Version      int           `json:"version"`
+	Metadata     Metadata      `json:"metadata"`
+	Components   []Component   `json:"components"`
+	Dependencies []Dependency  `json:"dependencies,omitempty"`
+	Compositions []Composition `json:"compositions,omitempty"`
+}
+
+// Metadata is the CycloneDX metadata of a BOM.
+type Metadata struct {
+	Timestamp  string     `json:"timestamp,omitempty"`
+	Tools      []Tool     `json:"tools,omitempty"`
+	Component  *Component `json:"component,omitempty"`
+	Properties []Property `json:"properties,omitempty"`
+}
+
+// Tool is a tool that was used to create a BOM.
+type Tool struct {
+	Vendor  string `json:"vendor,omitempty"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// Property is a CycloneDX name-value property.
+type Property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Component is a CycloneDX component describing a single node module.
+type Component struct {
+	BOMRef     string          `json:"bom-ref,omitempty"`
+	Type       string          `json:"type"`
+	Group      string          `json:"group,omitempty"`
+	Name       string          `json:"name"`
+	Version    string          `json:"version,omitempty"`
+	PURL       string          `json:"purl,omitempty"`
+	Hashes     []Hash          `json:"hashes,omitempty"`
+	Licenses   []LicenseChoice `json:"licenses,omitempty"`
+	Copyright  string          `json:"copyright,omitempty"`
+	Properties []Property      `json:"properties,omitempty"`
+	Evidence   *Evidence       `json:"evidence,omitempty"`
+
+	ExternalReferences []ExternalReference `json:"externalReferences,omitempty"`
+}
+
+// ExternalReference is a CycloneDX reference to a resource outside of the
+// BOM, such as the version control repository of a component.
+type ExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// Evidence records how the presence of a component was established. Its
+// occurrences require CycloneDX 1.5.
+type Evidence struct {
+	Occurrences []Occurrence `json:"occurrences,omitempty"`
+}
+
+// Occurrence is a location at which a component was found.
+type Occurrence struct {
+	Location string `json:"location"`
+}
+
+// Dependency lists the components that the component identified by Ref
+// directly depends on.
+type Dependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// Composition is a CycloneDX composition, which states how complete the
+// inventory of the BOM is.
+type Composition struct {
+	Aggregate string `json:"aggregate"`
+}
+
+// Hash is a CycloneDX hash with its algorithm name and hex-encoded content.
+type Hash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+// LicenseChoice is a CycloneDX license entry, either a single license or an
+// SPDX license expression, such as "(MIT OR Apache-2.0)".
+type LicenseChoice struct {
+	License    *License `json:"license,omitempty"`
+	Expression string   `json:"expression,omitempty"`
+}
+
+// value returns the expression of the entry, or else the SPDX ID or the name
+// of its license.
+func (c LicenseChoice) value() string {
+	switch {
+	case c.Expression != "":
+		return c.Expression
+	case c.License == nil:
+		return ""
+	case c.License.ID != "":
+		return c.License.ID
+	default:
+		return c.License.Name
+	}
+}
+
+// License identifies a license either by its SPDX ID or by name.
+type License struct {
+	ID         string     `json:"id,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	Properties []Property `json:"properties,omitempty"`
+}
+
+// PackageName returns the full npm package name of the component, including
+// its scope when the component has a group.
+func (c Component) PackageName() string {
+	if c.Group != "" {
+		return c.Group + "/" + c.Name
+	}
+
+	return c.Name
+}
+
+// Entries converts the components of the BOM into the BOM entries of the
+// deprecated buildpack BOM format. Only the first hash of each component can
+// be represented.
+func (b BOM) Entries() ([]packit.BOMEntry, error) {
+	var entries []packit.BOMEntry
+	for _, component := range b.Components {
+		metadata := paketosbom.BOMMetadata{
+			Version: component.Version,
+			PURL:    component.PURL,
+		}
+
+		if len(component.Hashes) > 0 {
+			algorithm, err := paketosbom.GetBOMChecksumAlgorithm(component.Hashes[0].Algorithm)
+			if err != nil {
+				return nil, err
+			}
+			metadata.Checksum = paketosbom.BOMChecksum{
+				Algorithm: algorithm,
+				Hash:      component.Hashes[0].Content,
+			}
+		}
+
+		var licenses []string
+		for _, license := range component.Licenses {
+			licenses = append(licenses, license.value())
+		}
+		metadata.Licenses = licenses
+
+		entries = append(entries, packit.BOMEntry{
+			Name:     component.Name,
+			Metadata: metadata,
+		})
+	}
+
+	return entries, nil
+}
+
+// Subgraph returns a BOM of the component identified by the given bom-ref and
+// the components that it transitively depends on. The component itself
+// becomes the metadata component of the returned BOM.
+func (b BOM) Subgraph(ref string) BOM {
+	graph := map[string][]string{}
+	for _, dependency := range b.Dependencies {
+		graph[dependency.Ref] = dependency.DependsOn
+	}
+
+	reachable := map[string]bool{}
+	var visit func(ref string)
+	visit = func(ref string) {
+		if reachable[ref] {
+			return
+		}
+
+		reachable[ref] = true
+		for _, dependency := range graph[ref] {
+			visit(dependency)
+		}
+	}
+	visit(ref)
+
+	subgraph := BOM{
+		BOMFormat:   b.BOMFormat,
+		SpecVersion: b.SpecVersion,
+		Version:     b.Version,
+		Metadata: Metadata{
+			Timestamp:  b.Metadata.Timestamp,
+			Tools:      b.Metadata.Tools,
+			Properties: b.Metadata.Properties,
+		},
+	}
+
+	for _, component := range b.Components {
+		if component.BOMRef == ref {
+			component := component
+			subgraph.Metadata.Component = &component
+			continue
+		}
+
+		if reachable[component.BOMRef] {
+			subgraph.Components = append(subgraph.Components, component)
+		}
+	}
+
+	for _, dependency := range b.Dependencies {
+		if reachable[dependency.Ref] {
+			subgraph.Dependencies = append(subgraph.Dependencies, dependency)
+		}
+	}
+
+	return subgraph
+}
+
+// Incomplete returns the BOM marked as incomplete for the given reason, with
+// an "incomplete" composition and the IncompleteProperty in its metadata.
+// An empty BOM is given the CycloneDX format and version.
+func (b BOM) Incomplete(reason string) BOM {
+	if b.BOMFormat == "" {
+		b.BOMFormat = "CycloneDX"
+		b.SpecVersion = CycloneDXSpecVersion
+		b.Version = 1
+	}
+
+	if b.Components == nil {
+		b.Components = []Component{}
+	}
+
+	b.Compositions = []Composition{{Aggregate: "incomplete"}}
+	b.Metadata.Properties = append(b.Metadata.Properties, Property{Name: IncompleteProperty, Value: reason})
+
+	return b
+}
+
+// LaunchBOM returns the BOM without what only describes the build: the
+// packages that were not installed, and the downloaded artifacts whose
+// location is neither inside of the application nor inside of one of the given
+// directories, such as browsers cached in the home directory of the build
+// user, as they are not part of the application image.
+func (b BOM) LaunchBOM(exportedDirs ...string) BOM {
+	var properties []Property
+	for _, property := range b.Metadata.Properties {
+		if property.Name != NotInstalledProperty {
+			properties = append(properties, property)
+		}
+	}
+	b.Metadata.Properties = properties
+
+	removed := map[string]bool{}
+	var components []Component
+	for _, component := range b.Components {
+		if location, ok := propertyValue(component.Properties, LocationProperty); ok && !exported(location, exportedDirs) {
+			removed[component.BOMRef] = true
+			continue
+		}
+		components = append(components, component)
+	}
+
+	if len(removed) == 0 {
+		return b
+	}
+	b.Components = components
+
+	var dependencies []Dependency
+	for _, dependency := range b.Dependencies {
+		if removed[dependency.Ref] {
+			continue
+		}
+
+		var dependsOn []string
+		for _, ref := range dependency.DependsOn {
+			if !removed[ref] {
+				dependsOn = append(dependsOn, ref)
+			}
+		}
+		dependency.DependsOn = dependsOn
+		dependencies = append(dependencies, dependency)
+	}
+	b.Dependencies = dependencies
+
+	return b
+}
+
+// exported returns whether the given location of a downloaded artifact is part
+// of the application image: a location relative to the application, or one
+// inside of the given directories.
+func exported(location string, dirs []string) bool {
+	if !filepath.IsAbs(location) {
+		return true
+	}
+
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(dir, location)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SBOMFormats renders the BOM into the SBOM formats that are written
+// alongside the layers of the buildpack: CycloneDX and SPDX.
+func (b BOM) SBOMFormats() (packit.SBOMFormats, error) {
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode CycloneDX SBOM: %w", err)
+	}
+
+	document, err := b.SPDX()
+	if err != nil {
+		return nil, err
+	}
+
+	spdxContent, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode SPDX SBOM: %w", err)
+	}
+
+	return packit.SBOMFormats{
+		{
+			Extension: "cdx.json",
+			Content:   bytes.NewReader(content),
+		},
+		{
+			Extension: "spdx.json",
+			Content:   bytes.NewReader(spdxContent),
+		},
+	}, nil
+}
@@ -0,0 +1,227 @@
+package nodemodulebom_test
+
+import (
+	"io"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/paketo-buildpacks/packit/v2"
+
+	//nolint Ignore SA1019, informed usage of deprecated package
+	"github.com/paketo-buildpacks/packit/v2/paketosbom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testBOM(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		bom nodemodulebom.BOM
+	)
+
+	it.Before(func() {
+		bom = nodemodulebom.BOM{
+			BOMFormat:   "CycloneDX",
+			SpecVersion: "1.5",
+			Version:     1,
+			Metadata: nodemodulebom.Metadata{
+				Properties: []nodemodulebom.Property{
+					{Name: "paketo:node-module-bom:lockfile", Value: "package-lock.json"},
+				},
+			},
+			Components: []nodemodulebom.Component{
+				{
+					Type:    "library",
+					Name:    "leftpad",
+					Version: "0.0.1",
+					PURL:    "pkg:npm/leftpad@0.0.1",
+					Hashes: []nodemodulebom.Hash{
+						{Algorithm: "SHA-1", Content: "86b1a4de4face180ac545a83f1503523d8fed115"},
+						{Algorithm: "SHA-512", Content: "abcdef"},
+					},
+					Licenses: []nodemodulebom.LicenseChoice{
+						{License: &nodemodulebom.License{ID: "BSD-3-Clause"}},
+						{Expression: "(MIT OR Apache-2.0)"},
+					},
+				},
+				{
+					Type:    "library",
+					Group:   "@scope",
+					Name:    "rightpad",
+					Version: "1.0.0",
+				},
+			},
+		}
+	})
+
+	context("Entries", func() {
+		it("converts the components into BOM entries", func() {
+			entries, err := bom.Entries()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(Equal([]packit.BOMEntry{
+				{
+					Name: "leftpad",
+					Metadata: paketosbom.BOMMetadata{
+						Version: "0.0.1",
+						PURL:    "pkg:npm/leftpad@0.0.1",
+						Checksum: paketosbom.BOMChecksum{
+							Algorithm: paketosbom.SHA1,
+							Hash:      "86b1a4de4face180ac545a83f1503523d8fed115",
+						},
+						Licenses: []string{"BSD-3-Clause", "(MIT OR Apache-2.0)"},
+					},
+				},
+				{
+					Name: "rightpad",
+					Metadata: paketosbom.BOMMetadata{
+						Version: "1.0.0",
+					},
+				},
+			}))
+		})
+
+		context("failure cases", func() {
+			context("when a component has an unsupported checksum algorithm", func() {
+				it.Before(func() {
+					bom.Components[0].Hashes[0].Algorithm = "randomAlgorithm"
+				})
+
+				it("returns an error", func() {
+					_, err := bom.Entries()
+					Expect(err).To(MatchError("failed to get supported BOM checksum algorithm: randomAlgorithm is not valid"))
+				})
+			})
+		})
+	})
+
+	context("Subgraph", func() {
+		it.Before(func() {
+			bom.Components[0].BOMRef = "pkg:npm/leftpad@0.0.1"
+			bom.Components[1].BOMRef = "pkg:npm/%40scope/rightpad@1.0.0"
+			bom.Components = append(bom.Components, nodemodulebom.Component{
+				BOMRef:  "pkg:npm/unrelated@1.0.0",
+				Type:    "library",
+				Name:    "unrelated",
+				Version: "1.0.0",
+			})
+			bom.Dependencies = []nodemodulebom.Dependency{
+				{Ref: "pkg:npm/%40scope/rightpad@1.0.0", DependsOn: []string{"pkg:npm/leftpad@0.0.1"}},
+				{Ref: "pkg:npm/leftpad@0.0.1", DependsOn: []string{"pkg:npm/%40scope/rightpad@1.0.0"}},
+				{Ref: "pkg:npm/unrelated@1.0.0"},
+			}
+		})
+
+		it("returns the component and its transitive dependencies", func() {
+			subgraph := bom.Subgraph("pkg:npm/%40scope/rightpad@1.0.0")
+			Expect(subgraph.Metadata.Component).To(Equal(&bom.Components[1]))
+			Expect(subgraph.Metadata.Properties).To(Equal(bom.Metadata.Properties))
+			Expect(subgraph.Components).To(Equal([]nodemodulebom.Component{bom.Components[0]}))
+			Expect(subgraph.Dependencies).To(Equal(bom.Dependencies[:2]))
+		})
+	})
+
+	context("LaunchBOM", func() {
+		it.Before(func() {
+			bom.Metadata.Properties = append(bom.Metadata.Properties, nodemodulebom.Property{
+				Name:  "paketo:node-module-bom:not-installed",
+				Value: "pkg:npm/%40esbuild/darwin-arm64@0.19.0",
+			})
+		})
+
+		it("removes the packages that were not installed", func() {
+			launch := bom.LaunchBOM()
+			Expect(launch.Metadata.Properties).To(Equal([]nodemodulebom.Property{
+				{Name: "paketo:node-module-bom:lockfile", Value: "package-lock.json"},
+			}))
+			Expect(launch.Components).To(Equal(bom.Components))
+			Expect(bom.Metadata.Properties).To(HaveLen(2))
+		})
+
+		it("removes the downloaded artifacts that are not part of the image", func() {
+			bom.Components = append(bom.Components,
+				nodemodulebom.Component{BOMRef: "pkg:generic/chrome@121.0.6167.85", Type: "application", Name: "chrome", Properties: []nodemodulebom.Property{{Name: "paketo:node-module-bom:location", Value: "/home/cnb/.cache/puppeteer/chrome/linux-121.0.6167.85"}}},
+				nodemodulebom.Component{BOMRef: "pkg:generic/firefox@1438", Type: "application", Name: "firefox", Properties: []nodemodulebom.Property{{Name: "paketo:node-module-bom:location", Value: "/layers/playwright/browsers/firefox-1438"}}},
+				nodemodulebom.Component{BOMRef: "pkg:generic/chromium@1097", Type: "application", Name: "chromium", Properties: []nodemodulebom.Property{{Name: "paketo:node-module-bom:location", Value: ".cache/puppeteer/chromium/linux-1097"}}},
+			)
+			bom.Dependencies = []nodemodulebom.Dependency{
+				{Ref: "pkg:npm/puppeteer@21.0.0", DependsOn: []string{"pkg:generic/chrome@121.0.6167.85", "pkg:generic/chromium@1097"}},
+				{Ref: "pkg:generic/chrome@121.0.6167.85"},
+				{Ref: "pkg:generic/chromium@1097"},
+			}
+
+			launch := bom.LaunchBOM("/layers")
+			Expect(launch.Components).To(Equal(append(append([]nodemodulebom.Component{}, bom.Components[:2]...), bom.Components[3:]...)))
+			Expect(launch.Dependencies).To(Equal([]nodemodulebom.Dependency{
+				{Ref: "pkg:npm/puppeteer@21.0.0", DependsOn: []string{"pkg:generic/chromium@1097"}},
+				{Ref: "pkg:generic/chromium@1097"},
+			}))
+			Expect(bom.Components).To(HaveLen(5))
+		})
+	})
+
+	context("Incomplete", func() {
+		it("marks the BOM as incomplete", func() {
+			incomplete := bom.Incomplete("generation timed out after 1m0s")
+			Expect(incomplete.Compositions).To(Equal([]nodemodulebom.Composition{{Aggregate: "incomplete"}}))
+			Expect(incomplete.Metadata.Properties).To(ContainElement(nodemodulebom.Property{
+				Name:  "paketo:node-module-bom:incomplete",
+				Value: "generation timed out after 1m0s",
+			}))
+			Expect(incomplete.Components).To(Equal(bom.Components))
+		})
+
+		it("gives an empty BOM the CycloneDX format", func() {
+			incomplete := nodemodulebom.BOM{}.Incomplete("generation timed out after 1m0s")
+			Expect(incomplete.BOMFormat).To(Equal("CycloneDX"))
+			Expect(incomplete.SpecVersion).To(Equal("1.5"))
+			Expect(incomplete.Version).To(Equal(1))
+		})
+	})
+
+	context("SBOMFormats", func() {
+		it("renders the BOM as CycloneDX and SPDX documents", func() {
+			formats, err := bom.SBOMFormats()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(formats).To(HaveLen(2))
+			Expect(formats[0].Extension).To(Equal("cdx.json"))
+			Expect(formats[1].Extension).To(Equal("spdx.json"))
+
+			content, err := io.ReadAll(formats[0].Content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{
+				"bomFormat": "CycloneDX",
+				"specVersion": "1.5",
+				"version": 1,
+				"metadata": {
+					"properties": [
+						{"name": "paketo:node-module-bom:lockfile", "value": "package-lock.json"}
+					]
+				},
+				"components": [
+					{
+						"type": "library",
+						"name": "leftpad",
+						"version": "0.0.1",
+						"purl": "pkg:npm/leftpad@0.0.1",
+						"hashes": [
+							{"alg": "SHA-1", "content": "86b1a4de4face180ac545a83f1503523d8fed115"},
+							{"alg": "SHA-512", "content": "abcdef"}
+						],
+						"licenses": [
+							{"license": {"id": "BSD-3-Clause"}},
+							{"expression": "(MIT OR Apache-2.0)"}
+						]
+					},
+					{
+						"type": "library",
+						"group": "@scope",
+						"name": "rightpad",
+						"version": "1.0.0"
+					}
+				]
+			}`))
+		})
+	})
+}
@@ -1,10 +1,12 @@
 package nodemodulebom
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/paketo-buildpacks/packit/v2"
@@ -22,10 +24,16 @@ type DependencyManager interface {
 
 //go:generate faux --interface NodeModuleBOM --output fakes/node_module_bom.go
 type NodeModuleBOM interface {
-	Generate(workingDir string) ([]packit.BOMEntry, error)
+	Generate(ctx context.Context, workingDir, binDir string) (BOM, error)
+	GenerateProjects(ctx context.Context, workingDir string, projectPaths []string, binDir string) (BOM, error)
 }
 
-func Build(dependencyManager DependencyManager, nodeModuleBOM NodeModuleBOM, clock chronos.Clock, logger scribe.Emitter) packit.BuildFunc {
+//go:generate faux --interface DriftDetector --output fakes/drift_detector.go
+type DriftDetector interface {
+	Detect(ctx context.Context, workingDir string) (Drift, error)
+}
+
+func Build(dependencyManager DependencyManager, nodeModuleBOM NodeModuleBOM, driftDetector DriftDetector, clock chronos.Clock, logger scribe.Emitter) packit.BuildFunc {
 	return func(context packit.BuildContext) (packit.BuildResult, error) {
 		logger.Title("%s %s", context.BuildpackInfo.Name, context.BuildpackInfo.Version)
 
@@ -76,18 +84,73 @@ func Build(dependencyManager DependencyManager, nodeModuleBOM NodeModuleBOM, clo
 
 		cycloneDXNodeModuleLayer.Cache = true
 
-		logger.Process("Configuring environment")
-		logger.Subprocess("Appending %s onto PATH", dependency.Name)
-		logger.Break()
+		binDir := filepath.Join(cycloneDXNodeModuleLayer.Path, "bin")
+
+		sbomDisabled, err := lookupBoolEnv("BP_DISABLE_SBOM")
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		workspaceSBOMs, err := lookupBoolEnv("BP_NODE_MODULE_BOM_WORKSPACE_SBOMS")
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		failOnDrift, err := lookupBoolEnv("BP_NODE_MODULE_BOM_FAIL_ON_DRIFT")
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
 
-		os.Setenv("PATH", fmt.Sprint(os.Getenv("PATH"), string(os.PathListSeparator), filepath.Join(cycloneDXNodeModuleLayer.Path, "bin")))
+		planConfig, err := ReadPlanConfig(context.Plan.Entries)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
 
-		sbomDisabled, err := checkSbomDisabled()
+		config, err := LoadConfig(context.WorkingDir)
 		if err != nil {
 			return packit.BuildResult{}, err
 		}
 
-		var toolBOM, moduleBOM []packit.BOMEntry
+		if len(config.Files) > 0 {
+			logger.Process("Reading configuration from %s", strings.Join(config.Files, ", "))
+			logger.Break()
+		}
+
+		if len(config.Formats) > 0 {
+			planConfig.Formats = config.Formats
+		}
+
+		projectPaths, err := LoadProjectPaths(context.WorkingDir, config, planConfig.ProjectPaths)
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		timeoutPolicy, err := LoadTimeoutPolicy()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		componentFilter, err := LoadComponentFilter()
+		if err != nil {
+			return packit.BuildResult{}, err
+		}
+
+		if _, ok := os.LookupEnv(IncludeEnv); !ok {
+			componentFilter.Include = config.Include
+		}
+
+		if _, ok := os.LookupEnv(ExcludeEnv); !ok {
+			componentFilter.Exclude = config.Exclude
+		}
+
+		layers := []packit.Layer{cycloneDXNodeModuleLayer}
+
+		var (
+			toolBOM, moduleBOM []packit.BOMEntry
+			launchBOM          []packit.BOMEntry
+			buildSBOM          packit.SBOMFormatter
+			launchSBOM         packit.SBOMFormatter
+		)
 
 		if sbomDisabled {
 			logger.Subprocess("Skipping Node Module BOM generation")
@@ -95,39 +158,435 @@ func Build(dependencyManager DependencyManager, nodeModuleBOM NodeModuleBOM, clo
 		} else {
 			toolBOM = dependencyManager.GenerateBillOfMaterials(dependency)
 
-			logger.Process("Running %s", dependency.Name)
+			// The time budget covers every walk of node_modules: the
+			// fingerprint, the generation of the SBOM and drift detection.
+			ctx, cancel := timeoutPolicy.Context()
+			defer cancel()
 
-			duration, err := clock.Measure(func() error {
-				moduleBOM, err = nodeModuleBOM.Generate(context.WorkingDir)
-				return err
-			})
+			// Once the fingerprint runs out of time, the generation of the
+			// SBOM does too, which applies the timeout policy.
+			fingerprint, err := Fingerprint(ctx, context.Layers.Path, context.WorkingDir, projectPaths, dependency, context.BuildpackInfo.Version)
+			if err != nil && !IsTimeout(err) {
+				return packit.BuildResult{}, err
+			}
+
+			sbomCacheLayer, err := context.Layers.Get(SBOMCacheLayer)
 			if err != nil {
 				return packit.BuildResult{}, err
 			}
 
-			logger.Action("Completed in %s", duration.Round(time.Millisecond))
+			var (
+				bom    BOM
+				cached bool
+			)
+			if cachedFingerprint, ok := sbomCacheLayer.Metadata[FingerprintMetadataKey].(string); ok && fingerprint != "" && cachedFingerprint == fingerprint {
+				bom, cached, err = ReadCachedBOM(sbomCacheLayer.Path)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+			}
+
+			rootDir := context.WorkingDir
+			if len(projectPaths) == 1 {
+				rootDir = projectPaths[0]
+			}
+
+			if cached {
+				logger.Process("Reusing cached SBOM")
+				logger.Subprocess("Fingerprint %s", fingerprint)
+				logger.Break()
+
+				application, ok, err := ReadApplication(rootDir)
+				if err != nil {
+					return packit.BuildResult{}, fmt.Errorf("failed to read application: %w", err)
+				}
+
+				if ok {
+					refreshGitMetadata(&bom, application)
+				}
+			} else {
+				logger.Process("Running %s", dependency.Name)
+				if len(projectPaths) > 1 {
+					logger.Subprocess("Generating the BOMs of %d projects", len(projectPaths))
+				} else if projectPaths[0] != context.WorkingDir {
+					logger.Subprocess("Using project path %s", projectPaths[0])
+				}
+
+				duration, err := clock.Measure(func() error {
+					if len(projectPaths) > 1 {
+						bom, err = nodeModuleBOM.GenerateProjects(ctx, context.WorkingDir, projectPaths, binDir)
+						return err
+					}
+
+					bom, err = nodeModuleBOM.Generate(ctx, projectPaths[0], binDir)
+					return err
+				})
+
+				incomplete := false
+				if err != nil {
+					if !IsTimeout(err) {
+						return packit.BuildResult{}, err
+					}
+
+					if timeoutPolicy.OnTimeout != OnTimeoutWarn {
+						return packit.BuildResult{}, fmt.Errorf("generating the SBOM took longer than %s, set %s to allow more time: %w", timeoutPolicy.Timeout, TimeoutEnv, err)
+					}
+
+					logger.Subprocess("Warning: generating the SBOM took longer than %s, continuing with an incomplete SBOM", timeoutPolicy.Timeout)
+					logger.Action("%s", err)
+
+					bom = bom.Incomplete(fmt.Sprintf("generation timed out after %s", timeoutPolicy.Timeout))
+					if bom.Metadata.Component == nil {
+						application, ok, err := ReadApplication(rootDir)
+						if err != nil {
+							return packit.BuildResult{}, fmt.Errorf("failed to read application: %w", err)
+						}
+
+						if ok {
+							component := application.Component()
+							bom.Metadata.Component = &component
+						}
+					}
+					incomplete = true
+				}
+
+				logger.Action("Completed in %s", duration.Round(time.Millisecond))
+				logger.Break()
+
+				if !incomplete {
+					sbomCacheLayer, err = sbomCacheLayer.Reset()
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+
+					err = WriteCachedBOM(sbomCacheLayer.Path, bom)
+					if err != nil {
+						return packit.BuildResult{}, err
+					}
+
+					sbomCacheLayer.Metadata = map[string]interface{}{
+						FingerprintMetadataKey: fingerprint,
+					}
+				}
+			}
+
+			sbomCacheLayer.Cache = true
+			layers = append(layers, sbomCacheLayer)
+
+			manifestPath := ManifestFile
+			if config.ComponentsManifest != "" {
+				manifestPath = config.ComponentsManifest
+			}
+
+			manifest, ok, err := LoadManifest(context.WorkingDir, manifestPath)
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			if ok {
+				logger.Process("Adding the components declared in %s", manifest.Path)
+				declared, err := manifest.Verify(context.WorkingDir)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				var skipped []Component
+				bom, skipped = AddDeclaredComponents(bom, declared)
+				logDeclared(logger, declared, skipped)
+				logger.Break()
+			}
+
+			if len(config.LicenseOverrides) > 0 {
+				var overridden int
+				bom, overridden = OverrideLicenses(bom, config.LicenseOverrides)
+
+				logger.Process("Overriding licenses")
+				logger.Subprocess("Overrode the licenses of %d components", overridden)
+				logger.Break()
+			}
+
+			var excluded []ExcludedComponent
+			if !componentFilter.Empty() {
+				logger.Process("Filtering components")
+				bom, excluded = componentFilter.Apply(bom)
+				logExclusions(logger, excluded)
+				logger.Break()
+			}
+
+			if len(config.Policies) > 0 {
+				logger.Process("Checking licenses against the policy files")
+				var violations []PolicyViolation
+				for _, policy := range config.Policies {
+					violations = append(violations, policy.Evaluate(bom)...)
+				}
+				logViolations(logger, violations)
+				logger.Break()
+
+				if len(violations) > 0 {
+					return packit.BuildResult{}, fmt.Errorf("%d components violate the license policy of %s", len(violations), strings.Join(config.PolicyFiles, ", "))
+				}
+			}
+
+			moduleBOM, err = bom.Entries()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+
+			formats, err := bom.SBOMFormats()
+			if err != nil {
+				return packit.BuildResult{}, err
+			}
+			buildSBOM = planConfig.Select(formats)
+
+			if planConfig.Launch {
+				// The layers of every buildpack are exported alongside the
+				// application, so downloads inside of any of them are shipped.
+				launch := bom.LaunchBOM(filepath.Dir(context.Layers.Path))
+				if removed := len(bom.Components) - len(launch.Components); removed > 0 {
+					logger.Process("Leaving %d downloaded artifacts outside of the application image out of the launch SBOM", removed)
+					logger.Break()
+				}
+
+				launchBOM, err = launch.Entries()
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				formats, err = launch.SBOMFormats()
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+				launchSBOM = planConfig.Select(formats)
+			} else {
+				logger.Process("Skipping the launch SBOM, the build plan does not require it")
+				logger.Break()
+			}
+
+			logger.Process("Checking installed packages against the lockfile")
+			var (
+				drifts        []Drift
+				driftTimedOut bool
+			)
+			for _, projectPath := range projectPaths {
+				drift, err := driftDetector.Detect(ctx, projectPath)
+				if err != nil {
+					if !IsTimeout(err) {
+						return packit.BuildResult{}, err
+					}
+
+					if timeoutPolicy.OnTimeout != OnTimeoutWarn {
+						return packit.BuildResult{}, fmt.Errorf("checking installed packages against the lockfile took longer than %s, set %s to allow more time: %w", timeoutPolicy.Timeout, TimeoutEnv, err)
+					}
+
+					drifts, driftTimedOut = nil, true
+					break
+				}
+
+				rel, err := filepath.Rel(context.WorkingDir, projectPath)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+				drifts = append(drifts, drift.InProject(filepath.ToSlash(rel)))
+			}
+			drift := MergeDrifts(drifts...)
+
+			switch {
+			case driftTimedOut:
+				logger.Subprocess("Warning: checking installed packages against the lockfile took longer than %s, skipping drift detection", timeoutPolicy.Timeout)
+			case drift.Lockfile == "":
+				logger.Subprocess("Skipping drift detection, no lockfile or node_modules found")
+			default:
+				logDrift(logger, drift)
+			}
 			logger.Break()
+
+			// The drift and exclusion reports are build-time diagnostics, so
+			// they are written to a build-only layer and do not ship in the
+			// application image.
+			var reportsLayer packit.Layer
+			if !drift.Empty() || len(excluded) > 0 {
+				reportsLayer, err = context.Layers.Get("node-module-bom-reports")
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				reportsLayer, err = reportsLayer.Reset()
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+				reportsLayer.Build = true
+
+				layers = append(layers, reportsLayer)
+			}
+
+			if !drift.Empty() {
+				path := filepath.Join(reportsLayer.Path, "drift.json")
+				err = drift.WriteReport(path)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+				logger.Subprocess("Wrote drift report to %s", path)
+				logger.Break()
+
+				if failOnDrift {
+					return packit.BuildResult{}, fmt.Errorf("installed packages have drifted from %s: %d missing, %d extra, %d mismatched", drift.Lockfile, len(drift.Missing), len(drift.Extra), len(drift.Mismatched))
+				}
+			}
+
+			if len(excluded) > 0 {
+				path := filepath.Join(reportsLayer.Path, "exclusions.json")
+				err = WriteExclusionReport(path, excluded)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+				logger.Subprocess("Wrote exclusion report to %s", path)
+				logger.Break()
+			}
+
+			if workspaceSBOMs {
+				nodeModuleBOMLayer, err := context.Layers.Get("node-module-bom")
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				nodeModuleBOMLayer, err = nodeModuleBOMLayer.Reset()
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+				nodeModuleBOMLayer.Launch = true
+
+				layers = append(layers, nodeModuleBOMLayer)
+
+				logger.Process("Writing workspace SBOMs")
+				paths, err := WriteWorkspaceSBOMs(filepath.Join(nodeModuleBOMLayer.Path, "workspaces"), bom)
+				if err != nil {
+					return packit.BuildResult{}, err
+				}
+
+				for _, path := range paths {
+					logger.Subprocess("%s", path)
+				}
+				logger.Break()
+			}
 		}
 
 		return packit.BuildResult{
-			Layers: []packit.Layer{cycloneDXNodeModuleLayer},
+			Layers: layers,
 			Build: packit.BuildMetadata{
-				BOM: append(toolBOM, moduleBOM...),
+				BOM:  append(toolBOM, moduleBOM...),
+				SBOM: buildSBOM,
 			},
 			Launch: packit.LaunchMetadata{
-				BOM: moduleBOM,
+				BOM:  launchBOM,
+				SBOM: launchSBOM,
 			},
 		}, nil
 	}
 }
 
-func checkSbomDisabled() (bool, error) {
-	if disableStr, ok := os.LookupEnv("BP_DISABLE_SBOM"); ok {
-		disable, err := strconv.ParseBool(disableStr)
+func logDrift(logger scribe.Emitter, drift Drift) {
+	if drift.Empty() {
+		logger.Subprocess("Installed packages match %s", drift.Lockfile)
+		return
+	}
+
+	logger.Subprocess("Installed packages have drifted from %s", drift.Lockfile)
+	for _, pkg := range drift.Missing {
+		logger.Action("Missing: %s@%s (%s)", pkg.Name, pkg.LockfileVersion, pkg.Path)
+	}
+	for _, pkg := range drift.Extra {
+		logger.Action("Extra: %s@%s (%s)", pkg.Name, pkg.InstalledVersion, pkg.Path)
+	}
+	for _, pkg := range drift.Mismatched {
+		logger.Action("Mismatched: %s %s in lockfile, %s installed (%s)", pkg.Name, pkg.LockfileVersion, pkg.InstalledVersion, pkg.Path)
+	}
+}
+
+func logExclusions(logger scribe.Emitter, excluded []ExcludedComponent) {
+	if len(excluded) == 0 {
+		logger.Subprocess("No components match the exclude patterns")
+		return
+	}
+
+	logger.Subprocess("Excluded %d components", len(excluded))
+	for _, component := range excluded {
+		name := component.Name
+		if component.Version != "" {
+			name += "@" + component.Version
+		}
+
+		switch {
+		case component.Partial:
+			logger.Action("%s at %s (%s), other occurrences are kept", name, strings.Join(component.Locations, ", "), component.Pattern)
+		case len(component.Locations) > 0:
+			logger.Action("%s at %s (%s)", name, strings.Join(component.Locations, ", "), component.Pattern)
+		default:
+			logger.Action("%s (%s)", name, component.Pattern)
+		}
+	}
+}
+
+func logDeclared(logger scribe.Emitter, declared, skipped []Component) {
+	logger.Subprocess("Added %d declared components", len(declared)-len(skipped))
+	for _, component := range declared {
+		name := component.Name
+		if component.Version != "" {
+			name += "@" + component.Version
+		}
+
+		var locations []string
+		for _, occurrence := range component.Evidence.Occurrences {
+			locations = append(locations, occurrence.Location)
+		}
+
+		if containsComponent(skipped, component.BOMRef) {
+			logger.Action("%s (%s), skipped as it is already part of the SBOM", name, strings.Join(locations, ", "))
+			continue
+		}
+
+		logger.Action("%s (%s)", name, strings.Join(locations, ", "))
+	}
+}
+
+func containsComponent(components []Component, ref string) bool {
+	for _, component := range components {
+		if component.BOMRef == ref {
+			return true
+		}
+	}
+
+	return false
+}
+
+func logViolations(logger scribe.Emitter, violations []PolicyViolation) {
+	if len(violations) == 0 {
+		logger.Subprocess("All components comply with the license policy")
+		return
+	}
+
+	logger.Subprocess("%d components violate the license policy", len(violations))
+	for _, violation := range violations {
+		name := violation.Name
+		if violation.Version != "" {
+			name += "@" + violation.Version
+		}
+
+		licenses := strings.Join(violation.Licenses, ", ")
+		if licenses == "" {
+			licenses = "no license"
+		}
+
+		logger.Action("%s (%s): %s (%s)", name, licenses, violation.Reason, violation.Policy)
+	}
+}
+
+func lookupBoolEnv(name string) (bool, error) {
+	if str, ok := os.LookupEnv(name); ok {
+		value, err := strconv.ParseBool(str)
 		if err != nil {
-			return false, fmt.Errorf("failed to parse BP_DISABLE_SBOM value %s: %w", disableStr, err)
+			return false, fmt.Errorf("failed to parse %s value %s: %w", name, str, err)
 		}
-		return disable, nil
+		return value, nil
 	}
 	return false, nil
 }
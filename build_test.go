@@ -2,7 +2,11 @@ package nodemodulebom_test
 
 import (
 	"bytes"
+	gocontext "context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -31,6 +35,7 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		workingDir        string
 		dependencyManager *fakes.DependencyManager
 		nodeModuleBOM     *fakes.NodeModuleBOM
+		driftDetector     *fakes.DriftDetector
 		buffer            *bytes.Buffer
 
 		build packit.BuildFunc
@@ -75,24 +80,31 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		}
 
 		nodeModuleBOM = &fakes.NodeModuleBOM{}
-		nodeModuleBOM.GenerateCall.Returns.BOMEntrySlice = []packit.BOMEntry{
-			{
-				Name: "leftpad",
-				Metadata: paketosbom.BOMMetadata{
+		nodeModuleBOM.GenerateCall.Returns.BOM = nodemodulebom.BOM{
+			BOMFormat:   "CycloneDX",
+			SpecVersion: "1.5",
+			Version:     1,
+			Components: []nodemodulebom.Component{
+				{
+					Type:    "library",
+					Name:    "leftpad",
 					Version: "leftpad-dependency-version",
-					Checksum: paketosbom.BOMChecksum{
-						Algorithm: algorithm,
-						Hash:      "leftpad-dependency-sha",
+					Hashes: []nodemodulebom.Hash{
+						{
+							Algorithm: "SHA-256",
+							Content:   "leftpad-dependency-sha",
+						},
 					},
-					URI: "leftpad-dependency-uri",
 				},
 			},
 		}
 
+		driftDetector = &fakes.DriftDetector{}
+
 		buffer = bytes.NewBuffer(nil)
 		logEmitter := scribe.NewEmitter(buffer)
 
-		build = nodemodulebom.Build(dependencyManager, nodeModuleBOM, chronos.DefaultClock, logEmitter)
+		build = nodemodulebom.Build(dependencyManager, nodeModuleBOM, driftDetector, chronos.DefaultClock, logEmitter)
 	})
 
 	it.After(func() {
@@ -115,9 +127,42 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		})
 		Expect(err).NotTo(HaveOccurred())
 
+		for _, formatter := range []packit.SBOMFormatter{result.Build.SBOM, result.Launch.SBOM} {
+			Expect(formatter.Formats()).To(HaveLen(2))
+			Expect(formatter.Formats()[0].Extension).To(Equal("cdx.json"))
+			Expect(formatter.Formats()[1].Extension).To(Equal("spdx.json"))
+
+			content, err := io.ReadAll(formatter.Formats()[0].Content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{
+				"bomFormat": "CycloneDX",
+				"specVersion": "1.5",
+				"version": 1,
+				"metadata": {},
+				"components": [
+					{
+						"type": "library",
+						"name": "leftpad",
+						"version": "leftpad-dependency-version",
+						"hashes": [
+							{
+								"alg": "SHA-256",
+								"content": "leftpad-dependency-sha"
+							}
+						]
+					}
+				]
+			}`))
+		}
+		result.Build.SBOM = nil
+		result.Launch.SBOM = nil
+
 		algorithm, err := paketosbom.GetBOMChecksumAlgorithm("SHA-256")
 		Expect(err).NotTo(HaveOccurred())
 
+		fingerprint, err := nodemodulebom.Fingerprint(gocontext.Background(), layersDir, workingDir, []string{workingDir}, dependencyManager.ResolveCall.Returns.Dependency, "some-version")
+		Expect(err).NotTo(HaveOccurred())
+
 		Expect(result).To(Equal(packit.BuildResult{
 			Layers: []packit.Layer{
 				{
@@ -134,6 +179,20 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 						"dependency-sha": "cyclonedx-node-module-dependency-sha",
 					},
 				},
+				{
+					Name:             "node-module-bom-cache",
+					Path:             filepath.Join(layersDir, "node-module-bom-cache"),
+					SharedEnv:        packit.Environment{},
+					BuildEnv:         packit.Environment{},
+					LaunchEnv:        packit.Environment{},
+					ProcessLaunchEnv: map[string]packit.Environment{},
+					Build:            false,
+					Launch:           false,
+					Cache:            true,
+					Metadata: map[string]interface{}{
+						"fingerprint": fingerprint,
+					},
+				},
 			},
 			Build: packit.BuildMetadata{
 				BOM: []packit.BOMEntry{
@@ -156,7 +215,6 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 								Algorithm: algorithm,
 								Hash:      "leftpad-dependency-sha",
 							},
-							URI: "leftpad-dependency-uri",
 						},
 					},
 				},
@@ -171,7 +229,6 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 								Algorithm: algorithm,
 								Hash:      "leftpad-dependency-sha",
 							},
-							URI: "leftpad-dependency-uri",
 						},
 					},
 				},
@@ -207,6 +264,11 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		}))
 
 		Expect(nodeModuleBOM.GenerateCall.Receives.WorkingDir).To(Equal(workingDir))
+		Expect(nodeModuleBOM.GenerateCall.Receives.BinDir).To(Equal(filepath.Join(layersDir, "cyclonedx-node-module", "bin")))
+		Expect(os.Getenv("PATH")).NotTo(ContainSubstring(layersDir))
+		Expect(driftDetector.DetectCall.Receives.WorkingDir).To(Equal(workingDir))
+
+		Expect(buffer.String()).To(ContainSubstring("Skipping drift detection, no lockfile or node_modules found"))
 	})
 
 	context("when there is a dependency cache match to reuse", func() {
@@ -241,9 +303,17 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			})
 			Expect(err).NotTo(HaveOccurred())
 
+			Expect(result.Build.SBOM).NotTo(BeNil())
+			Expect(result.Launch.SBOM).NotTo(BeNil())
+			result.Build.SBOM = nil
+			result.Launch.SBOM = nil
+
 			algorithm, err := paketosbom.GetBOMChecksumAlgorithm("SHA-256")
 			Expect(err).NotTo(HaveOccurred())
 
+			fingerprint, err := nodemodulebom.Fingerprint(gocontext.Background(), layersDir, workingDir, []string{workingDir}, dependencyManager.ResolveCall.Returns.Dependency, "some-version")
+			Expect(err).NotTo(HaveOccurred())
+
 			Expect(result).To(Equal(packit.BuildResult{
 				Layers: []packit.Layer{
 					{
@@ -260,6 +330,20 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 							"dependency-sha": "cyclonedx-node-module-dependency-sha",
 						},
 					},
+					{
+						Name:             "node-module-bom-cache",
+						Path:             filepath.Join(layersDir, "node-module-bom-cache"),
+						SharedEnv:        packit.Environment{},
+						BuildEnv:         packit.Environment{},
+						LaunchEnv:        packit.Environment{},
+						ProcessLaunchEnv: map[string]packit.Environment{},
+						Build:            false,
+						Launch:           false,
+						Cache:            true,
+						Metadata: map[string]interface{}{
+							"fingerprint": fingerprint,
+						},
+					},
 				},
 				Build: packit.BuildMetadata{
 					BOM: []packit.BOMEntry{
@@ -282,7 +366,6 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 									Algorithm: algorithm,
 									Hash:      "leftpad-dependency-sha",
 								},
-								URI: "leftpad-dependency-uri",
 							},
 						},
 					},
@@ -297,7 +380,6 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 									Algorithm: algorithm,
 									Hash:      "leftpad-dependency-sha",
 								},
-								URI: "leftpad-dependency-uri",
 							},
 						},
 					},
@@ -322,52 +404,178 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
-	context("failure cases", func() {
-		context("the dependency cannot be resolved", func() {
+	context("when the SBOM cache matches the fingerprint of the application", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"name": "some-app", "version": "1.0.0"}`), 0600)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(workingDir, ".git"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, ".git", "HEAD"), []byte("fedcba9876543210fedcba9876543210fedcba98\n"), 0600)).To(Succeed())
+
+			fingerprint, err := nodemodulebom.Fingerprint(gocontext.Background(), layersDir, workingDir, []string{workingDir}, dependencyManager.ResolveCall.Returns.Dependency, "some-version")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.WriteFile(filepath.Join(layersDir, "node-module-bom-cache.toml"), []byte(fmt.Sprintf(`
+			[metadata]
+			fingerprint = %q
+			`, fingerprint)), 0600)).To(Succeed())
+
+			Expect(nodemodulebom.WriteCachedBOM(filepath.Join(layersDir, "node-module-bom-cache"), nodemodulebom.BOM{
+				BOMFormat:   "CycloneDX",
+				SpecVersion: "1.5",
+				Version:     1,
+				Metadata: nodemodulebom.Metadata{
+					Component: &nodemodulebom.Component{
+						BOMRef: "pkg:npm/some-app@1.0.0",
+						Type:   "application",
+						Name:   "some-app",
+						Properties: []nodemodulebom.Property{
+							{Name: "paketo:node-module-bom:git:commit", Value: "0123456789abcdef0123456789abcdef01234567"},
+						},
+					},
+				},
+				Components: []nodemodulebom.Component{
+					{Type: "library", Name: "cached", Version: "1.0.0"},
+				},
+			})).To(Succeed())
+		})
+
+		it("reuses the cached SBOM with the current commit", func() {
+			result, err := build(packit.BuildContext{
+				BuildpackInfo: packit.BuildpackInfo{
+					Name:    "Some Buildpack",
+					Version: "some-version",
+				},
+				CNBPath:    cnbDir,
+				Platform:   packit.Platform{Path: "platform"},
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(nodeModuleBOM.GenerateCall.CallCount).To(Equal(0))
+			Expect(result.Layers).To(HaveLen(2))
+			Expect(result.Layers[1].Name).To(Equal("node-module-bom-cache"))
+			Expect(result.Layers[1].Cache).To(BeTrue())
+
+			Expect(result.Launch.BOM).To(HaveLen(1))
+			Expect(result.Launch.BOM[0].Name).To(Equal("cached"))
+
+			content, err := io.ReadAll(result.Build.SBOM.Formats()[0].Content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring("fedcba9876543210fedcba9876543210fedcba98"))
+			Expect(string(content)).NotTo(ContainSubstring("0123456789abcdef0123456789abcdef01234567"))
+
+			Expect(buffer.String()).To(ContainSubstring("Reusing cached SBOM"))
+			Expect(buffer.String()).NotTo(ContainSubstring("Running cyclonedx-node-module-dependency-name"))
+		})
+
+		context("when the lockfile changes", func() {
 			it.Before(func() {
-				dependencyManager.ResolveCall.Returns.Error = errors.New("failed to resolve dependency")
+				Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), []byte(`{"lockfileVersion": 3}`), 0600)).To(Succeed())
 			})
-			it("returns an error", func() {
-				_, err := build(packit.BuildContext{
+
+			it("generates the SBOM again and caches it", func() {
+				result, err := build(packit.BuildContext{
+					BuildpackInfo: packit.BuildpackInfo{
+						Name:    "Some Buildpack",
+						Version: "some-version",
+					},
 					CNBPath:    cnbDir,
 					Platform:   packit.Platform{Path: "platform"},
 					Layers:     packit.Layers{Path: layersDir},
 					Stack:      "some-stack",
 					WorkingDir: workingDir,
 				})
-				Expect(err).To(MatchError(ContainSubstring("failed to resolve dependency")))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(nodeModuleBOM.GenerateCall.CallCount).To(Equal(1))
+				Expect(result.Launch.BOM[0].Name).To(Equal("leftpad"))
+
+				fingerprint, err := nodemodulebom.Fingerprint(gocontext.Background(), layersDir, workingDir, []string{workingDir}, dependencyManager.ResolveCall.Returns.Dependency, "some-version")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Layers[1].Metadata).To(Equal(map[string]interface{}{"fingerprint": fingerprint}))
+
+				bom, ok, err := nodemodulebom.ReadCachedBOM(result.Layers[1].Path)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeTrue())
+				Expect(bom).To(Equal(nodeModuleBOM.GenerateCall.Returns.BOM))
+
+				Expect(buffer.String()).NotTo(ContainSubstring("Reusing cached SBOM"))
 			})
 		})
+	})
+
+	context("when the installed packages match the lockfile", func() {
+		it.Before(func() {
+			driftDetector.DetectCall.Returns.Drift = nodemodulebom.Drift{Lockfile: "package-lock.json"}
+		})
 
-		context("when the cyclonedx-node-module layer cannot be retrieved", func() {
-			it.Before(func() {
-				err := os.WriteFile(filepath.Join(layersDir, "cyclonedx-node-module.toml"), nil, 0000)
-				Expect(err).NotTo(HaveOccurred())
+		it("does not write a drift report", func() {
+			result, err := build(packit.BuildContext{
+				CNBPath:    cnbDir,
+				Platform:   packit.Platform{Path: "platform"},
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				WorkingDir: workingDir,
 			})
+			Expect(err).NotTo(HaveOccurred())
 
-			it("returns an error", func() {
-				_, err := build(packit.BuildContext{
-					CNBPath:    cnbDir,
-					Platform:   packit.Platform{Path: "platform"},
-					Layers:     packit.Layers{Path: layersDir},
-					Stack:      "some-stack",
-					WorkingDir: workingDir,
-				})
-				Expect(err).To(MatchError(ContainSubstring("failed to parse layer content metadata")))
+			Expect(result.Layers).To(HaveLen(2))
+			Expect(buffer.String()).To(ContainSubstring("Installed packages match package-lock.json"))
+		})
+	})
+
+	context("when the installed packages have drifted from the lockfile", func() {
+		it.Before(func() {
+			driftDetector.DetectCall.Returns.Drift = nodemodulebom.Drift{
+				Lockfile:   "package-lock.json",
+				Missing:    []nodemodulebom.DriftedPackage{{Name: "leftpad", Path: "node_modules/leftpad", LockfileVersion: "0.0.1"}},
+				Extra:      []nodemodulebom.DriftedPackage{{Name: "vendored", Path: "node_modules/vendored", InstalledVersion: "1.0.0"}},
+				Mismatched: []nodemodulebom.DriftedPackage{{Name: "rightpad", Path: "node_modules/rightpad", LockfileVersion: "1.0.0", InstalledVersion: "1.0.1"}},
+			}
+		})
+
+		it("logs the drift and writes a drift report into a launch layer", func() {
+			result, err := build(packit.BuildContext{
+				CNBPath:    cnbDir,
+				Platform:   packit.Platform{Path: "platform"},
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				WorkingDir: workingDir,
 			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(3))
+			Expect(result.Layers[2].Name).To(Equal("node-module-bom-reports"))
+			Expect(result.Layers[2].Build).To(BeTrue())
+			Expect(result.Layers[2].Launch).To(BeFalse())
+
+			content, err := os.ReadFile(filepath.Join(layersDir, "node-module-bom-reports", "drift.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{
+				"lockfile": "package-lock.json",
+				"missing": [{"name": "leftpad", "path": "node_modules/leftpad", "lockfileVersion": "0.0.1"}],
+				"extra": [{"name": "vendored", "path": "node_modules/vendored", "installedVersion": "1.0.0"}],
+				"mismatched": [{"name": "rightpad", "path": "node_modules/rightpad", "lockfileVersion": "1.0.0", "installedVersion": "1.0.1"}]
+			}`))
+
+			Expect(buffer.String()).To(ContainSubstring("Installed packages have drifted from package-lock.json"))
+			Expect(buffer.String()).To(ContainSubstring("Missing: leftpad@0.0.1 (node_modules/leftpad)"))
+			Expect(buffer.String()).To(ContainSubstring("Extra: vendored@1.0.0 (node_modules/vendored)"))
+			Expect(buffer.String()).To(ContainSubstring("Mismatched: rightpad 1.0.0 in lockfile, 1.0.1 installed (node_modules/rightpad)"))
+			Expect(buffer.String()).To(ContainSubstring(filepath.Join(layersDir, "node-module-bom-reports", "drift.json")))
 		})
 
-		context("when the cyclonedx-node-module layer cannot be reset", func() {
+		context("when BP_NODE_MODULE_BOM_FAIL_ON_DRIFT is true", func() {
 			it.Before(func() {
-				Expect(os.MkdirAll(filepath.Join(layersDir, "cyclonedx-node-module", "something"), os.ModePerm)).To(Succeed())
-				Expect(os.Chmod(filepath.Join(layersDir, "cyclonedx-node-module"), 0500)).To(Succeed())
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_FAIL_ON_DRIFT", "true")).To(Succeed())
 			})
 
 			it.After(func() {
-				Expect(os.Chmod(filepath.Join(layersDir, "cyclonedx-node-module"), os.ModePerm)).To(Succeed())
+				Expect(os.Unsetenv("BP_NODE_MODULE_BOM_FAIL_ON_DRIFT")).To(Succeed())
 			})
 
-			it("returns an error", func() {
+			it("fails the build", func() {
 				_, err := build(packit.BuildContext{
 					CNBPath:    cnbDir,
 					Platform:   packit.Platform{Path: "platform"},
@@ -375,29 +583,660 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 					Stack:      "some-stack",
 					WorkingDir: workingDir,
 				})
-				Expect(err).To(MatchError(ContainSubstring("could not remove file")))
+				Expect(err).To(MatchError("installed packages have drifted from package-lock.json: 1 missing, 1 extra, 1 mismatched"))
+				Expect(filepath.Join(layersDir, "node-module-bom-reports", "drift.json")).To(BeARegularFile())
 			})
 		})
+	})
+
+	context("when the BOM records packages that were not installed", func() {
+		it.Before(func() {
+			nodeModuleBOM.GenerateCall.Returns.BOM.Metadata.Properties = []nodemodulebom.Property{
+				{Name: "paketo:node-module-bom:not-installed", Value: "pkg:npm/%40esbuild/darwin-arm64@0.19.0"},
+			}
+		})
 
-		context("when the dependency cannot be installed", func() {
+		it("only includes them in the build SBOM", func() {
+			result, err := build(packit.BuildContext{
+				CNBPath:    cnbDir,
+				Platform:   packit.Platform{Path: "platform"},
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			content, err := io.ReadAll(result.Build.SBOM.Formats()[0].Content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring("paketo:node-module-bom:not-installed"))
+
+			content, err = io.ReadAll(result.Launch.SBOM.Formats()[0].Content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).NotTo(ContainSubstring("paketo:node-module-bom:not-installed"))
+		})
+	})
+
+	context("when BP_NODE_MODULE_BOM_WORKSPACE_SBOMS is true", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_NODE_MODULE_BOM_WORKSPACE_SBOMS", "true")).To(Succeed())
+
+			nodeModuleBOM.GenerateCall.Returns.BOM.Components = append(nodeModuleBOM.GenerateCall.Returns.BOM.Components, nodemodulebom.Component{
+				BOMRef:  "pkg:npm/%40acme/api@1.0.0",
+				Type:    "library",
+				Group:   "@acme",
+				Name:    "api",
+				Version: "1.0.0",
+				Properties: []nodemodulebom.Property{
+					{Name: "paketo:node-module-bom:first-party", Value: "true"},
+					{Name: "paketo:node-module-bom:workspace", Value: "packages/api"},
+				},
+			})
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_NODE_MODULE_BOM_WORKSPACE_SBOMS")).To(Succeed())
+		})
+
+		it("writes an SBOM for each workspace package into a launch layer", func() {
+			result, err := build(packit.BuildContext{
+				CNBPath:    cnbDir,
+				Platform:   packit.Platform{Path: "platform"},
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Layers).To(HaveLen(3))
+			Expect(result.Layers[2].Name).To(Equal("node-module-bom"))
+			Expect(result.Layers[2].Launch).To(BeTrue())
+			Expect(filepath.Join(layersDir, "node-module-bom", "workspaces", "@acme%2Fapi.cdx.json")).To(BeARegularFile())
+
+			Expect(buffer.String()).To(ContainSubstring("Writing workspace SBOMs"))
+			Expect(buffer.String()).To(ContainSubstring(filepath.Join(layersDir, "node-module-bom", "workspaces", "@acme%2Fapi.cdx.json")))
+		})
+	})
+
+	context("when BP_NODE_PROJECT_PATH is set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_NODE_PROJECT_PATH", "apps/api")).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(workingDir, "apps", "api"), os.ModePerm)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_NODE_PROJECT_PATH")).To(Succeed())
+		})
+
+		it("generates the BOM of the project in that directory", func() {
+			_, err := build(packit.BuildContext{
+				CNBPath:    cnbDir,
+				Platform:   packit.Platform{Path: "platform"},
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(nodeModuleBOM.GenerateCall.Receives.WorkingDir).To(Equal(filepath.Join(workingDir, "apps", "api")))
+			Expect(driftDetector.DetectCall.Receives.WorkingDir).To(Equal(filepath.Join(workingDir, "apps", "api")))
+
+			Expect(buffer.String()).To(ContainSubstring(fmt.Sprintf("Using project path %s", filepath.Join(workingDir, "apps", "api"))))
+		})
+	})
+
+	context("when BP_NODE_MODULE_BOM_PROJECT_PATHS lists several projects", func() {
+		var detected []string
+
+		it.Before(func() {
+			Expect(os.Setenv("BP_NODE_MODULE_BOM_PROJECT_PATHS", "api:worker")).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(workingDir, "api"), os.ModePerm)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(workingDir, "worker"), os.ModePerm)).To(Succeed())
+
+			nodeModuleBOM.GenerateProjectsCall.Returns.BOM = nodeModuleBOM.GenerateCall.Returns.BOM
+
+			detected = nil
+			driftDetector.DetectCall.Stub = func(ctx gocontext.Context, workingDir string) (nodemodulebom.Drift, error) {
+				detected = append(detected, workingDir)
+				if filepath.Base(workingDir) == "worker" {
+					return nodemodulebom.Drift{
+						Lockfile: "package-lock.json",
+						Missing:  []nodemodulebom.DriftedPackage{{Name: "leftpad", Path: "node_modules/leftpad", LockfileVersion: "1.0.0"}},
+					}, nil
+				}
+
+				return nodemodulebom.Drift{Lockfile: "package-lock.json"}, nil
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_NODE_MODULE_BOM_PROJECT_PATHS")).To(Succeed())
+		})
+
+		it("generates a merged BOM and checks the drift of every project", func() {
+			_, err := build(packit.BuildContext{
+				CNBPath:    cnbDir,
+				Platform:   packit.Platform{Path: "platform"},
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(nodeModuleBOM.GenerateCall.CallCount).To(Equal(0))
+			Expect(nodeModuleBOM.GenerateProjectsCall.Receives.WorkingDir).To(Equal(workingDir))
+			Expect(nodeModuleBOM.GenerateProjectsCall.Receives.BinDir).To(Equal(filepath.Join(layersDir, "cyclonedx-node-module", "bin")))
+			Expect(nodeModuleBOM.GenerateProjectsCall.Receives.ProjectPaths).To(Equal([]string{
+				filepath.Join(workingDir, "api"),
+				filepath.Join(workingDir, "worker"),
+			}))
+			Expect(detected).To(Equal([]string{
+				filepath.Join(workingDir, "api"),
+				filepath.Join(workingDir, "worker"),
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring("Generating the BOMs of 2 projects"))
+			Expect(buffer.String()).To(ContainSubstring("Installed packages have drifted from api/package-lock.json, worker/package-lock.json"))
+			Expect(buffer.String()).To(ContainSubstring("Missing: leftpad@1.0.0 (worker/node_modules/leftpad)"))
+		})
+	})
+
+	context("when a node-module-bom requirement of the build plan configures the SBOM", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(workingDir, "api"), os.ModePerm)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(workingDir, "worker"), os.ModePerm)).To(Succeed())
+
+			nodeModuleBOM.GenerateProjectsCall.Returns.BOM = nodeModuleBOM.GenerateCall.Returns.BOM
+		})
+
+		it("writes the requested formats of the build SBOM only, for the projects of the plan", func() {
+			result, err := build(packit.BuildContext{
+				CNBPath:  cnbDir,
+				Platform: packit.Platform{Path: "platform"},
+				Layers:   packit.Layers{Path: layersDir},
+				Plan: packit.BuildpackPlan{
+					Entries: []packit.BuildpackPlanEntry{
+						{Name: "node-module-bom"},
+						{
+							Name: "node-module-bom",
+							Metadata: map[string]interface{}{
+								"formats":       []interface{}{"spdx"},
+								"launch":        false,
+								"project-paths": "api, worker",
+							},
+						},
+					},
+				},
+				Stack:      "some-stack",
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Build.SBOM.Formats()).To(HaveLen(1))
+			Expect(result.Build.SBOM.Formats()[0].Extension).To(Equal("spdx.json"))
+			Expect(result.Build.BOM).NotTo(BeEmpty())
+
+			Expect(result.Launch.SBOM).To(BeNil())
+			Expect(result.Launch.BOM).To(BeEmpty())
+
+			Expect(nodeModuleBOM.GenerateProjectsCall.Receives.ProjectPaths).To(Equal([]string{
+				filepath.Join(workingDir, "api"),
+				filepath.Join(workingDir, "worker"),
+			}))
+
+			Expect(buffer.String()).To(ContainSubstring("Skipping the launch SBOM, the build plan does not require it"))
+		})
+
+		context("when BP_NODE_MODULE_BOM_PROJECT_PATHS is set", func() {
 			it.Before(func() {
-				dependencyManager.DeliverCall.Returns.Error = errors.New("failed to install dependency")
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_PROJECT_PATHS", "api")).To(Succeed())
 			})
 
-			it("returns an error", func() {
+			it.After(func() {
+				Expect(os.Unsetenv("BP_NODE_MODULE_BOM_PROJECT_PATHS")).To(Succeed())
+			})
+
+			it("takes precedence over the project paths of the plan", func() {
 				_, err := build(packit.BuildContext{
-					CNBPath:    cnbDir,
-					Platform:   packit.Platform{Path: "platform"},
-					Layers:     packit.Layers{Path: layersDir},
+					CNBPath:  cnbDir,
+					Platform: packit.Platform{Path: "platform"},
+					Layers:   packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{
+								Name:     "node-module-bom",
+								Metadata: map[string]interface{}{"project-paths": []interface{}{"api", "worker"}},
+							},
+						},
+					},
 					Stack:      "some-stack",
 					WorkingDir: workingDir,
 				})
-				Expect(err).To(MatchError(ContainSubstring("failed to install dependency")))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(nodeModuleBOM.GenerateProjectsCall.CallCount).To(Equal(0))
+				Expect(nodeModuleBOM.GenerateCall.Receives.WorkingDir).To(Equal(filepath.Join(workingDir, "api")))
 			})
 		})
+	})
 
-		context("when the node module BOM cannot be generated", func() {
-			it.Before(func() {
+	context("when .node-module-bom.toml configures the SBOM", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom.toml"), []byte(`
+formats = ["cyclonedx"]
+exclude = ["rightpad"]
+
+[license-overrides]
+leftpad = "MIT"
+`), 0600)).To(Succeed())
+
+			nodeModuleBOM.GenerateCall.Returns.BOM.Components = append(nodeModuleBOM.GenerateCall.Returns.BOM.Components, nodemodulebom.Component{
+				Type: "library", Name: "rightpad", Version: "1.0.0",
+			})
+		})
+
+		it("writes the configured formats, with the license overrides and without the excluded components", func() {
+			result, err := build(packit.BuildContext{
+				CNBPath:  cnbDir,
+				Platform: packit.Platform{Path: "platform"},
+				Layers:   packit.Layers{Path: layersDir},
+				Plan: packit.BuildpackPlan{
+					Entries: []packit.BuildpackPlanEntry{
+						{Name: "node-module-bom", Metadata: map[string]interface{}{"formats": "spdx"}},
+					},
+				},
+				Stack:      "some-stack",
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Build.SBOM.Formats()).To(HaveLen(1))
+			Expect(result.Build.SBOM.Formats()[0].Extension).To(Equal("cdx.json"))
+
+			content, err := io.ReadAll(result.Build.SBOM.Formats()[0].Content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`"id": "MIT"`))
+			Expect(string(content)).NotTo(ContainSubstring(`"name": "rightpad"`))
+
+			Expect(buffer.String()).To(ContainSubstring("Reading configuration from .node-module-bom.toml"))
+			Expect(buffer.String()).To(ContainSubstring("Overrode the licenses of 1 components"))
+			Expect(buffer.String()).To(ContainSubstring("rightpad@1.0.0 (rightpad)"))
+		})
+
+		context("when BP_NODE_MODULE_BOM_EXCLUDE is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_EXCLUDE", "leftpad")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_NODE_MODULE_BOM_EXCLUDE")).To(Succeed())
+			})
+
+			it("takes precedence over the configuration file", func() {
+				result, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := io.ReadAll(result.Build.SBOM.Formats()[0].Content)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(ContainSubstring(`"name": "rightpad"`))
+				Expect(string(content)).NotTo(ContainSubstring(`"name": "leftpad"`))
+			})
+		})
+
+		context("when a policy file denies a license", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom.toml"), []byte(`policy-files = ["licenses.toml"]`), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "licenses.toml"), []byte(`allow-licenses = ["MIT"]`), 0600)).To(Succeed())
+			})
+
+			it("logs the violations and fails the build", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError("2 components violate the license policy of licenses.toml"))
+
+				Expect(buffer.String()).To(ContainSubstring("Checking licenses against the policy files"))
+				Expect(buffer.String()).To(ContainSubstring("leftpad@leftpad-dependency-version (no license): license is unknown (licenses.toml)"))
+			})
+		})
+	})
+
+	context("when the application declares components in a manifest", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(workingDir, "public", "vendor"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "public", "vendor", "jquery.min.js"), []byte("/*! jQuery v3.7.1 */"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom-components.toml"), []byte(`
+[[components]]
+name = "jquery"
+version = "3.7.1"
+purl = "pkg:npm/jquery@3.7.1"
+license = "MIT"
+files = [{ path = "public/vendor/jquery.min.js" }]
+`), 0600)).To(Succeed())
+		})
+
+		it("adds them to the SBOM", func() {
+			result, err := build(packit.BuildContext{
+				CNBPath:    cnbDir,
+				Platform:   packit.Platform{Path: "platform"},
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			content, err := io.ReadAll(result.Launch.SBOM.Formats()[0].Content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring(`"purl": "pkg:npm/jquery@3.7.1"`))
+			Expect(string(content)).To(ContainSubstring(`"location": "public/vendor/jquery.min.js"`))
+
+			Expect(buffer.String()).To(ContainSubstring("Adding the components declared in .node-module-bom-components.toml"))
+			Expect(buffer.String()).To(ContainSubstring("jquery@3.7.1 (public/vendor/jquery.min.js)"))
+		})
+
+		context("when a declared file is missing", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(workingDir, "public", "vendor", "jquery.min.js"))).To(Succeed())
+			})
+
+			it("fails the build", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError("failed to verify .node-module-bom-components.toml components[0] (jquery@3.7.1): file public/vendor/jquery.min.js does not exist"))
+			})
+		})
+	})
+
+	context("when BP_NODE_MODULE_BOM_EXCLUDE is set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_NODE_MODULE_BOM_EXCLUDE", "left*")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_NODE_MODULE_BOM_EXCLUDE")).To(Succeed())
+		})
+
+		it("leaves the excluded components out of the SBOM, and logs and reports them", func() {
+			result, err := build(packit.BuildContext{
+				CNBPath:    cnbDir,
+				Platform:   packit.Platform{Path: "platform"},
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Launch.BOM).To(BeEmpty())
+
+			content, err := io.ReadAll(result.Launch.SBOM.Formats()[0].Content)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).NotTo(ContainSubstring(`"name": "leftpad"`))
+			Expect(string(content)).To(ContainSubstring(`"value": "left*"`))
+
+			Expect(result.Layers).To(HaveLen(3))
+			Expect(result.Layers[2].Name).To(Equal("node-module-bom-reports"))
+			Expect(result.Layers[2].Build).To(BeTrue())
+			Expect(result.Layers[2].Launch).To(BeFalse())
+
+			report, err := os.ReadFile(filepath.Join(layersDir, "node-module-bom-reports", "exclusions.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(report)).To(MatchJSON(`[{"name": "leftpad", "version": "leftpad-dependency-version", "pattern": "left*"}]`))
+
+			Expect(buffer.String()).To(ContainSubstring("Filtering components"))
+			Expect(buffer.String()).To(ContainSubstring("Excluded 1 components"))
+			Expect(buffer.String()).To(ContainSubstring("leftpad@leftpad-dependency-version (left*)"))
+			Expect(buffer.String()).To(ContainSubstring("Wrote exclusion report to"))
+		})
+	})
+
+	context("when generating the SBOM times out", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_NODE_MODULE_BOM_TIMEOUT", "50ms")).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"name": "some-app", "version": "1.0.0"}`), 0600)).To(Succeed())
+
+			nodeModuleBOM.GenerateCall.Stub = func(ctx gocontext.Context, workingDir, binDir string) (nodemodulebom.BOM, error) {
+				<-ctx.Done()
+				return nodemodulebom.BOM{}, fmt.Errorf("failed to run cyclonedx-bom: %w", ctx.Err())
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_NODE_MODULE_BOM_TIMEOUT")).To(Succeed())
+			Expect(os.Unsetenv("BP_NODE_MODULE_BOM_ON_TIMEOUT")).To(Succeed())
+		})
+
+		it("fails the build", func() {
+			_, err := build(packit.BuildContext{
+				CNBPath:    cnbDir,
+				Platform:   packit.Platform{Path: "platform"},
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				WorkingDir: workingDir,
+			})
+			Expect(err).To(MatchError("generating the SBOM took longer than 50ms, set BP_NODE_MODULE_BOM_TIMEOUT to allow more time: failed to run cyclonedx-bom: context deadline exceeded"))
+		})
+
+		context("when BP_NODE_MODULE_BOM_ON_TIMEOUT is warn", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_ON_TIMEOUT", "warn")).To(Succeed())
+			})
+
+			it("continues with an SBOM that is marked as incomplete and does not cache it", func() {
+				result, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := io.ReadAll(result.Launch.SBOM.Formats()[0].Content)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(content)).To(MatchJSON(`{
+					"bomFormat": "CycloneDX",
+					"specVersion": "1.5",
+					"version": 1,
+					"metadata": {
+						"component": {
+							"bom-ref": "pkg:npm/some-app@1.0.0",
+							"type": "application",
+							"name": "some-app",
+							"version": "1.0.0",
+							"purl": "pkg:npm/some-app@1.0.0"
+						},
+						"properties": [
+							{"name": "paketo:node-module-bom:incomplete", "value": "generation timed out after 50ms"}
+						]
+					},
+					"components": [],
+					"compositions": [
+						{"aggregate": "incomplete"}
+					]
+				}`))
+
+				Expect(result.Layers[1].Name).To(Equal("node-module-bom-cache"))
+				Expect(result.Layers[1].Metadata).To(BeEmpty())
+				Expect(filepath.Join(layersDir, "node-module-bom-cache", "bom.json")).NotTo(BeAnExistingFile())
+
+				Expect(buffer.String()).To(ContainSubstring("Warning: generating the SBOM took longer than 50ms, continuing with an incomplete SBOM"))
+			})
+
+			context("when part of the SBOM was generated before the timeout", func() {
+				it.Before(func() {
+					nodeModuleBOM.GenerateCall.Stub = func(ctx gocontext.Context, workingDir, binDir string) (nodemodulebom.BOM, error) {
+						<-ctx.Done()
+						return nodemodulebom.BOM{
+							BOMFormat:   "CycloneDX",
+							SpecVersion: "1.5",
+							Version:     1,
+							Components: []nodemodulebom.Component{
+								{BOMRef: "pkg:npm/leftpad@0.0.1", Type: "library", Name: "leftpad", Version: "0.0.1", PURL: "pkg:npm/leftpad@0.0.1"},
+							},
+						}, fmt.Errorf("failed to read installed tree: %w", ctx.Err())
+					}
+				})
+
+				it("continues with the part that was generated", func() {
+					result, err := build(packit.BuildContext{
+						CNBPath:    cnbDir,
+						Platform:   packit.Platform{Path: "platform"},
+						Layers:     packit.Layers{Path: layersDir},
+						Stack:      "some-stack",
+						WorkingDir: workingDir,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					content, err := io.ReadAll(result.Launch.SBOM.Formats()[0].Content)
+					Expect(err).NotTo(HaveOccurred())
+
+					var bom nodemodulebom.BOM
+					Expect(json.Unmarshal(content, &bom)).To(Succeed())
+					Expect(bom.Components).To(HaveLen(1))
+					Expect(bom.Components[0].Name).To(Equal("leftpad"))
+					Expect(bom.Compositions).To(Equal([]nodemodulebom.Composition{{Aggregate: "incomplete"}}))
+				})
+			})
+		})
+	})
+
+	context("when checking installed packages against the lockfile times out", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_NODE_MODULE_BOM_TIMEOUT", "50ms")).To(Succeed())
+
+			driftDetector.DetectCall.Stub = func(ctx gocontext.Context, workingDir string) (nodemodulebom.Drift, error) {
+				<-ctx.Done()
+				return nodemodulebom.Drift{}, ctx.Err()
+			}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_NODE_MODULE_BOM_TIMEOUT")).To(Succeed())
+			Expect(os.Unsetenv("BP_NODE_MODULE_BOM_ON_TIMEOUT")).To(Succeed())
+		})
+
+		it("fails the build", func() {
+			_, err := build(packit.BuildContext{
+				CNBPath:    cnbDir,
+				Platform:   packit.Platform{Path: "platform"},
+				Layers:     packit.Layers{Path: layersDir},
+				Stack:      "some-stack",
+				WorkingDir: workingDir,
+			})
+			Expect(err).To(MatchError("checking installed packages against the lockfile took longer than 50ms, set BP_NODE_MODULE_BOM_TIMEOUT to allow more time: context deadline exceeded"))
+		})
+
+		context("when BP_NODE_MODULE_BOM_ON_TIMEOUT is warn", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_ON_TIMEOUT", "warn")).To(Succeed())
+			})
+
+			it("skips drift detection with a warning", func() {
+				result, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Layers).To(HaveLen(2))
+
+				Expect(buffer.String()).To(ContainSubstring("Warning: checking installed packages against the lockfile took longer than 50ms, skipping drift detection"))
+			})
+		})
+	})
+
+	context("failure cases", func() {
+		context("the dependency cannot be resolved", func() {
+			it.Before(func() {
+				dependencyManager.ResolveCall.Returns.Error = errors.New("failed to resolve dependency")
+			})
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to resolve dependency")))
+			})
+		})
+
+		context("when the cyclonedx-node-module layer cannot be retrieved", func() {
+			it.Before(func() {
+				err := os.WriteFile(filepath.Join(layersDir, "cyclonedx-node-module.toml"), nil, 0000)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse layer content metadata")))
+			})
+		})
+
+		context("when the cyclonedx-node-module layer cannot be reset", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(layersDir, "cyclonedx-node-module", "something"), os.ModePerm)).To(Succeed())
+				Expect(os.Chmod(filepath.Join(layersDir, "cyclonedx-node-module"), 0500)).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Chmod(filepath.Join(layersDir, "cyclonedx-node-module"), os.ModePerm)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("could not remove file")))
+			})
+		})
+
+		context("when the dependency cannot be installed", func() {
+			it.Before(func() {
+				dependencyManager.DeliverCall.Returns.Error = errors.New("failed to install dependency")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to install dependency")))
+			})
+		})
+
+		context("when the node module BOM cannot be generated", func() {
+			it.Before(func() {
 				nodeModuleBOM.GenerateCall.Returns.Error = errors.New("failed to generate node module BOM")
 			})
 
@@ -413,6 +1252,183 @@ func testBuild(t *testing.T, context spec.G, it spec.S) {
 			})
 		})
 
+		context("when the node module BOM contains an unsupported checksum algorithm", func() {
+			it.Before(func() {
+				nodeModuleBOM.GenerateCall.Returns.BOM.Components[0].Hashes[0].Algorithm = "randomAlgorithm"
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError("failed to get supported BOM checksum algorithm: randomAlgorithm is not valid"))
+			})
+		})
+
+		context("when the drift cannot be detected", func() {
+			it.Before(func() {
+				driftDetector.DetectCall.Returns.Error = errors.New("failed to detect drift")
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError("failed to detect drift"))
+			})
+		})
+
+		context("when the configuration file is invalid", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte("[node-module-bom]\nformats = [\"syft\"]\n"), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(`failed to parse project.toml key node-module-bom.formats: unknown format "syft", must be "cyclonedx" or "spdx"`))
+			})
+		})
+
+		context("when the build plan asks for an unknown SBOM format", func() {
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:  cnbDir,
+					Platform: packit.Platform{Path: "platform"},
+					Layers:   packit.Layers{Path: layersDir},
+					Plan: packit.BuildpackPlan{
+						Entries: []packit.BuildpackPlanEntry{
+							{
+								Name:     "node-module-bom",
+								Metadata: map[string]interface{}{"formats": "syft"},
+							},
+						},
+					},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(`failed to parse node-module-bom build plan metadata formats: unknown format "syft", must be "cyclonedx" or "spdx"`))
+			})
+		})
+
+		context("when BP_NODE_MODULE_BOM_EXCLUDE is malformed", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_EXCLUDE", "[")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_NODE_MODULE_BOM_EXCLUDE")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError("failed to parse BP_NODE_MODULE_BOM_EXCLUDE pattern [: syntax error in pattern"))
+			})
+		})
+
+		context("when BP_NODE_MODULE_BOM_ON_TIMEOUT is set incorrectly", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_ON_TIMEOUT", "ignore")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_NODE_MODULE_BOM_ON_TIMEOUT")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(`failed to parse BP_NODE_MODULE_BOM_ON_TIMEOUT value ignore: must be "fail" or "warn"`))
+			})
+		})
+
+		context("when BP_NODE_MODULE_BOM_FAIL_ON_DRIFT is set incorrectly", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_FAIL_ON_DRIFT", "not-a-bool")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_NODE_MODULE_BOM_FAIL_ON_DRIFT")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_NODE_MODULE_BOM_FAIL_ON_DRIFT")))
+			})
+		})
+
+		context("when BP_NODE_MODULE_BOM_WORKSPACE_SBOMS is set incorrectly", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_WORKSPACE_SBOMS", "not-a-bool")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_NODE_MODULE_BOM_WORKSPACE_SBOMS")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_NODE_MODULE_BOM_WORKSPACE_SBOMS")))
+			})
+		})
+
+		context("when BP_NODE_PROJECT_PATH does not exist", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_NODE_PROJECT_PATH", "missing")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_NODE_PROJECT_PATH")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := build(packit.BuildContext{
+					CNBPath:    cnbDir,
+					Platform:   packit.Platform{Path: "platform"},
+					Layers:     packit.Layers{Path: layersDir},
+					Stack:      "some-stack",
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError("expected value derived from BP_NODE_PROJECT_PATH [missing] to be an existing directory"))
+			})
+		})
+
 		context("when BP_DISABLE_SBOM is set incorrectly", func() {
 			it.Before(func() {
 				os.Setenv("BP_DISABLE_SBOM", "not-a-bool")
@@ -0,0 +1,220 @@
+package nodemodulebom
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readBunLockfile parses the text lockfile written by Bun into the packages
+// that Bun installs into node_modules.
+//
+// Each entry of the "packages" object is keyed by the install path of the
+// package, with nested packages separated by "/" (e.g. "foo/bar" is installed
+// into node_modules/foo/node_modules/bar), and holds an array whose first
+// element is the resolved "name@version" of the package. Registry packages
+// are followed by the registry tarball URL, an object with the dependencies
+// of the package, and the integrity hash of the package.
+func readBunLockfile(path string) ([]InstalledPackage, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lockfile struct {
+		Packages map[string][]json.RawMessage `json:"packages"`
+	}
+
+	err = json.Unmarshal(stripJSONC(content), &lockfile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+	}
+
+	var packages []InstalledPackage
+	for key, entry := range lockfile.Packages {
+		if len(entry) == 0 {
+			continue
+		}
+
+		var ident string
+		err = json.Unmarshal(entry[0], &ident)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: package %q: %w", filepath.Base(path), key, err)
+		}
+
+		name, version := splitPackageIdent(ident)
+		if strings.HasPrefix(version, "workspace:") || strings.HasPrefix(version, "link:") {
+			continue
+		}
+
+		pkg := InstalledPackage{
+			Name:    name,
+			Version: version,
+			Path:    bunInstallPath(key),
+		}
+
+		for i, element := range entry[1:] {
+			var value string
+			if json.Unmarshal(element, &value) == nil {
+				switch {
+				case i == 0 && value != "" && !isIntegrity(value):
+					pkg.Resolved = value
+				case isIntegrity(value):
+					pkg.Integrity = value
+				}
+
+				continue
+			}
+
+			var info struct {
+				Dependencies         map[string]string `json:"dependencies"`
+				OptionalDependencies map[string]string `json:"optionalDependencies"`
+				OS                   json.RawMessage   `json:"os"`
+				CPU                  json.RawMessage   `json:"cpu"`
+			}
+			if json.Unmarshal(element, &info) == nil {
+				pkg.Dependencies = mergeDependencies(info.Dependencies, info.OptionalDependencies)
+				pkg.OS = stringOrList(info.OS)
+				pkg.CPU = stringOrList(info.CPU)
+			}
+		}
+
+		packages = append(packages, pkg)
+	}
+
+	sortInstalledPackages(packages)
+
+	return packages, nil
+}
+
+// splitPackageIdent splits a "name@version" package identifier at the first
+// "@" that follows the name, taking care of the leading "@" of scoped package
+// names. Versions such as "github:user/repo#ref" may themselves contain an
+// "@".
+func splitPackageIdent(ident string) (string, string) {
+	if ident == "" {
+		return "", ""
+	}
+
+	index := strings.Index(ident[1:], "@") + 1
+	if index == 0 {
+		return ident, ""
+	}
+
+	return ident[:index], ident[index+1:]
+}
+
+// bunInstallPath converts the key of a Bun lockfile package into its path in
+// the node_modules tree.
+func bunInstallPath(key string) string {
+	segments := strings.Split(key, "/")
+
+	var names []string
+	for i := 0; i < len(segments); i++ {
+		if strings.HasPrefix(segments[i], "@") && i+1 < len(segments) {
+			names = append(names, segments[i]+"/"+segments[i+1])
+			i++
+			continue
+		}
+
+		names = append(names, segments[i])
+	}
+
+	return "node_modules/" + strings.Join(names, "/node_modules/")
+}
+
+// stringOrList decodes a field that Bun writes as a single string when it
+// holds one value and as a list otherwise.
+func stringOrList(field json.RawMessage) []string {
+	var value string
+	if json.Unmarshal(field, &value) == nil {
+		if value == "" {
+			return nil
+		}
+
+		return []string{value}
+	}
+
+	var values []string
+	if json.Unmarshal(field, &values) == nil {
+		return values
+	}
+
+	return nil
+}
+
+func isIntegrity(value string) bool {
+	for _, prefix := range []string{"sha1-", "sha256-", "sha384-", "sha512-"} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stripJSONC removes the comments and trailing commas that are permitted in
+// JSONC documents so that the content can be parsed as JSON.
+func stripJSONC(content []byte) []byte {
+	var (
+		output   []byte
+		inString bool
+	)
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+
+		if inString {
+			output = append(output, c)
+			switch c {
+			case '\\':
+				if i+1 < len(content) {
+					i++
+					output = append(output, content[i])
+				}
+			case '"':
+				inString = false
+			}
+
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+			output = append(output, c)
+
+		case c == '/' && i+1 < len(content) && content[i+1] == '/':
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			i--
+
+		case c == '/' && i+1 < len(content) && content[i+1] == '*':
+			end := strings.Index(string(content[i+2:]), "*/")
+			if end < 0 {
+				i = len(content)
+			} else {
+				i += end + 3
+			}
+
+		case c == ']' || c == '}':
+			// Drop a trailing comma, ignoring any whitespace that follows it.
+			j := len(output) - 1
+			for j >= 0 && strings.ContainsRune(" \t\r\n", rune(output[j])) {
+				j--
+			}
+			if j >= 0 && output[j] == ',' {
+				output = append(output[:j], output[j+1:]...)
+			}
+			output = append(output, c)
+
+		default:
+			output = append(output, c)
+		}
+	}
+
+	return output
+}
@@ -0,0 +1,152 @@
+package nodemodulebom_test
+
+import (
+	gocontext "context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testBunLockfile(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.Mkdir(filepath.Join(workingDir, "node_modules"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, "bun.lock"), []byte(`{
+			// bun.lock is JSONC
+			"lockfileVersion": 1,
+			"workspaces": {
+				"": {
+					"name": "some-app",
+					"dependencies": {
+						"@scope/rightpad": "^1.0.0",
+						"leftpad": "^0.0.1", /* trailing commas are allowed */
+					},
+				},
+			},
+			"packages": {
+				"@scope/rightpad": ["@scope/rightpad@1.0.0", "", { "dependencies": { "leftpad": "^0.0.2" } }, "sha512-cmlnaHRwYWQ="],
+				"@scope/rightpad/leftpad": ["leftpad@0.0.2", "", {}, "sha512-bGVmdHBhZA=="],
+				"leftpad": ["leftpad@0.0.1", "https://npm.example.com/leftpad/-/leftpad-0.0.1.tgz", { "optionalDependencies": { "@scope/rightpad": "^1.0.0" } }, "sha1-hrGk3k+s4YCsVFqD8VA1I9j+0RU="],
+				"some-workspace": ["some-workspace@workspace:packages/some-workspace"],
+				"from-github": ["from-github@github:user/repo#abc123", { "dependencies": { "leftpad": "^0.0.1" } }, "user-repo-abc123"],
+			},
+		}`), 0600)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("when the application was installed with Bun", func() {
+		it("reads the installed tree from the bun.lock", func() {
+			tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tree).To(Equal(nodemodulebom.InstalledTree{
+				Source: "bun.lock",
+				Packages: []nodemodulebom.InstalledPackage{
+					{
+						Name:         "@scope/rightpad",
+						Version:      "1.0.0",
+						Path:         "node_modules/@scope/rightpad",
+						Integrity:    "sha512-cmlnaHRwYWQ=",
+						Dependencies: map[string]string{"leftpad": "^0.0.2"},
+					},
+					{
+						Name:      "leftpad",
+						Version:   "0.0.2",
+						Path:      "node_modules/@scope/rightpad/node_modules/leftpad",
+						Integrity: "sha512-bGVmdHBhZA==",
+					},
+					{
+						Name:         "from-github",
+						Version:      "github:user/repo#abc123",
+						Path:         "node_modules/from-github",
+						Dependencies: map[string]string{"leftpad": "^0.0.1"},
+					},
+					{
+						Name:         "leftpad",
+						Version:      "0.0.1",
+						Path:         "node_modules/leftpad",
+						Resolved:     "https://npm.example.com/leftpad/-/leftpad-0.0.1.tgz",
+						Integrity:    "sha1-hrGk3k+s4YCsVFqD8VA1I9j+0RU=",
+						Dependencies: map[string]string{"@scope/rightpad": "^1.0.0"},
+					},
+				},
+			}))
+		})
+
+		it("resolves dependencies through the nested node_modules", func() {
+			tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			pkg, ok := tree.Resolve("node_modules/@scope/rightpad", "leftpad")
+			Expect(ok).To(BeTrue())
+			Expect(pkg.Version).To(Equal("0.0.2"))
+
+			pkg, ok = tree.Resolve("node_modules/from-github", "leftpad")
+			Expect(ok).To(BeTrue())
+			Expect(pkg.Version).To(Equal("0.0.1"))
+
+			_, ok = tree.Resolve("node_modules/leftpad", "missing")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	context("when the bun.lock lists packages for other platforms", func() {
+		it.Before(func() {
+			Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules", "@esbuild", "linux-x64"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "@esbuild", "linux-x64", "package.json"), []byte(`{}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "bun.lock"), []byte(`{
+				"lockfileVersion": 1,
+				"packages": {
+					"@esbuild/darwin-arm64": ["@esbuild/darwin-arm64@0.19.0", "", { "os": "darwin", "cpu": "arm64" }, "sha512-ZGFyd2lu"],
+					"@esbuild/linux-x64": ["@esbuild/linux-x64@0.19.0", "", { "os": "linux", "cpu": "x64" }, "sha512-bGludXg="],
+				},
+			}`), 0600)).To(Succeed())
+		})
+
+		it("skips the packages that were not installed", func() {
+			tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tree.Packages).To(Equal([]nodemodulebom.InstalledPackage{
+				{
+					Name:      "@esbuild/linux-x64",
+					Version:   "0.19.0",
+					Path:      "node_modules/@esbuild/linux-x64",
+					Integrity: "sha512-bGludXg=",
+					OS:        []string{"linux"},
+					CPU:       []string{"x64"},
+				},
+			}))
+			Expect(tree.Skipped).To(HaveLen(1))
+			Expect(tree.Skipped[0].Name).To(Equal("@esbuild/darwin-arm64"))
+		})
+	})
+
+	context("failure cases", func() {
+		context("when the bun.lock cannot be parsed", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "bun.lock"), []byte(`{"packages": {"leftpad": [1]}}`), 0600)).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+				Expect(err).To(MatchError(ContainSubstring(`failed to parse bun.lock: package "leftpad"`)))
+			})
+		})
+	})
+}
@@ -0,0 +1,160 @@
+package nodemodulebom
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// BundledProperty marks components that were installed as part of the
+	// tarball of another package and cannot be upgraded independently.
+	BundledProperty = "cdx:npm:package:bundled"
+
+	// BundledByProperty records the bom-ref of the package whose tarball a
+	// bundled component was installed from.
+	BundledByProperty = "paketo:node-module-bom:bundled-by"
+)
+
+// discoverBundledDependencies adds the bundled dependencies that are
+// installed inside of the packages read from a lockfile. These ship in the
+// tarball of the bundling package and are therefore missing from the
+// lockfile of the application.
+func discoverBundledDependencies(ctx context.Context, workingDir string, packages []InstalledPackage) ([]InstalledPackage, error) {
+	paths := map[string]bool{}
+	for _, pkg := range packages {
+		paths[pkg.Path] = true
+	}
+
+	var discovered []InstalledPackage
+	for i, pkg := range packages {
+		err := ctx.Err()
+		if err != nil {
+			return nil, err
+		}
+
+		manifest, err := readPackageJSON(filepath.Join(workingDir, pkg.Path, "package.json"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		names := manifest.BundledNames()
+		if len(names) == 0 {
+			continue
+		}
+		packages[i].BundleDependencies = names
+
+		walker := nodeModulesWalker{
+			ctx:        ctx,
+			workingDir: workingDir,
+			visited:    map[string]bool{},
+		}
+
+		nested, err := walker.walk(pkg.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, bundled := range nested {
+			if !paths[bundled.Path] {
+				paths[bundled.Path] = true
+				discovered = append(discovered, bundled)
+			}
+		}
+	}
+
+	if len(discovered) == 0 {
+		return packages, nil
+	}
+
+	packages = append(packages, discovered...)
+	sortInstalledPackages(packages)
+
+	return packages, nil
+}
+
+// markBundled marks the bundled dependencies of every package that declares
+// bundleDependencies, along with the dependencies that they in turn resolve
+// to inside of the bundling package, and records the package that bundled
+// them.
+func markBundled(packages []InstalledPackage) {
+	index := map[string]InstalledPackage{}
+	positions := map[string]int{}
+	for i, pkg := range packages {
+		index[pkg.Path] = pkg
+		positions[pkg.Path] = i
+	}
+
+	for _, bundler := range packages {
+		if len(bundler.BundleDependencies) == 0 {
+			continue
+		}
+
+		prefix := bundler.Path + "/node_modules/"
+
+		type request struct{ path, name string }
+		var queue []request
+		for _, name := range bundler.BundleDependencies {
+			queue = append(queue, request{bundler.Path, name})
+		}
+
+		seen := map[string]bool{}
+		for len(queue) > 0 {
+			next := queue[0]
+			queue = queue[1:]
+
+			dependency, ok := resolvePackage(index, next.path, next.name)
+			if !ok || !strings.HasPrefix(dependency.Path, prefix) || seen[dependency.Path] {
+				continue
+			}
+			seen[dependency.Path] = true
+			packages[positions[dependency.Path]].Bundled = true
+
+			for name := range dependency.Dependencies {
+				queue = append(queue, request{dependency.Path, name})
+			}
+		}
+	}
+
+	for i, pkg := range packages {
+		if !pkg.Bundled {
+			continue
+		}
+
+		path := pkg.Path
+		for {
+			parent := strings.LastIndex(path, "/node_modules/")
+			if parent < 0 {
+				break
+			}
+			path = path[:parent]
+
+			position, ok := positions[path]
+			if ok && !packages[position].Bundled {
+				packages[i].BundledBy = packages[position].Ref()
+				break
+			}
+		}
+	}
+}
+
+// bundleProperties returns the CycloneDX properties that mark a bundled
+// package and the package that bundled it.
+func (p InstalledPackage) bundleProperties() []Property {
+	if !p.Bundled {
+		return nil
+	}
+
+	properties := []Property{{Name: BundledProperty, Value: "true"}}
+	if p.BundledBy != "" {
+		properties = append(properties, Property{Name: BundledByProperty, Value: p.BundledBy})
+	}
+
+	return properties
+}
@@ -0,0 +1,196 @@
+package nodemodulebom_test
+
+import (
+	gocontext "context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testBundled(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		for path, content := range map[string]string{
+			"node_modules/bundler/package.json": `{
+				"name": "bundler",
+				"version": "1.0.0",
+				"dependencies": {"leftpad": "^0.0.1", "rightpad": "^1.0.0"},
+				"bundleDependencies": ["leftpad"]
+			}`,
+			"node_modules/bundler/node_modules/leftpad/package.json": `{
+				"name": "leftpad",
+				"version": "0.0.1",
+				"dependencies": {"padding": "^2.0.0"}
+			}`,
+			"node_modules/bundler/node_modules/padding/package.json": `{
+				"name": "padding",
+				"version": "2.0.0"
+			}`,
+			"node_modules/bundler/node_modules/rightpad/package.json": `{
+				"name": "rightpad",
+				"version": "1.0.0"
+			}`,
+		} {
+			Expect(os.MkdirAll(filepath.Join(workingDir, filepath.Dir(path)), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, path), []byte(content), 0600)).To(Succeed())
+		}
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("when walking the node_modules directory", func() {
+		it("marks the bundled dependencies and their dependencies", func() {
+			tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tree.Packages).To(Equal([]nodemodulebom.InstalledPackage{
+				{
+					Name:               "bundler",
+					Version:            "1.0.0",
+					Path:               "node_modules/bundler",
+					Dependencies:       map[string]string{"leftpad": "^0.0.1", "rightpad": "^1.0.0"},
+					BundleDependencies: []string{"leftpad"},
+				},
+				{
+					Name:         "leftpad",
+					Version:      "0.0.1",
+					Path:         "node_modules/bundler/node_modules/leftpad",
+					Dependencies: map[string]string{"padding": "^2.0.0"},
+					Bundled:      true,
+					BundledBy:    "pkg:npm/bundler@1.0.0",
+				},
+				{
+					Name:      "padding",
+					Version:   "2.0.0",
+					Path:      "node_modules/bundler/node_modules/padding",
+					Bundled:   true,
+					BundledBy: "pkg:npm/bundler@1.0.0",
+				},
+				{
+					Name:    "rightpad",
+					Version: "1.0.0",
+					Path:    "node_modules/bundler/node_modules/rightpad",
+				},
+			}))
+		})
+
+		context("when every dependency is bundled", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "bundler", "package.json"), []byte(`{
+					"name": "bundler",
+					"version": "1.0.0",
+					"dependencies": {"leftpad": "^0.0.1", "rightpad": "^1.0.0"},
+					"bundledDependencies": true
+				}`), 0600)).To(Succeed())
+			})
+
+			it("marks all of the dependencies as bundled", func() {
+				tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+				Expect(err).NotTo(HaveOccurred())
+
+				var bundled []string
+				for _, pkg := range tree.Packages {
+					if pkg.Bundled {
+						bundled = append(bundled, pkg.Name)
+					}
+				}
+				Expect(bundled).To(Equal([]string{"leftpad", "padding", "rightpad"}))
+			})
+		})
+	})
+
+	context("when the bundled dependencies are missing from the lockfile", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), []byte(`{
+				"lockfileVersion": 3,
+				"packages": {
+					"node_modules/bundler": {
+						"version": "1.0.0",
+						"dependencies": {"leftpad": "^0.0.1", "rightpad": "^1.0.0"}
+					},
+					"node_modules/bundler/node_modules/rightpad": {
+						"version": "1.0.0"
+					}
+				}
+			}`), 0600)).To(Succeed())
+		})
+
+		it("discovers them inside of the bundling package", func() {
+			tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tree.Source).To(Equal("package-lock.json"))
+
+			var paths []string
+			for _, pkg := range tree.Packages {
+				paths = append(paths, pkg.Path)
+				if pkg.Bundled {
+					Expect(pkg.BundledBy).To(Equal("pkg:npm/bundler@1.0.0"))
+				}
+			}
+			Expect(paths).To(Equal([]string{
+				"node_modules/bundler",
+				"node_modules/bundler/node_modules/leftpad",
+				"node_modules/bundler/node_modules/padding",
+				"node_modules/bundler/node_modules/rightpad",
+			}))
+			Expect(tree.Packages[1].Bundled).To(BeTrue())
+			Expect(tree.Packages[2].Bundled).To(BeTrue())
+			Expect(tree.Packages[3].Bundled).To(BeFalse())
+		})
+	})
+
+	context("when the lockfile marks packages as in the bundle", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", ".package-lock.json"), []byte(`{
+				"lockfileVersion": 3,
+				"packages": {
+					"node_modules/other": {
+						"version": "1.0.0"
+					},
+					"node_modules/other/node_modules/leftpad": {
+						"version": "0.0.1",
+						"inBundle": true
+					}
+				}
+			}`), 0600)).To(Succeed())
+		})
+
+		it("marks them as bundled by their parent", func() {
+			tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tree.Packages[1].Bundled).To(BeTrue())
+			Expect(tree.Packages[1].BundledBy).To(Equal("pkg:npm/other@1.0.0"))
+		})
+	})
+
+	context("Component", func() {
+		it("marks bundled packages with the bundling package", func() {
+			pkg := nodemodulebom.InstalledPackage{
+				Name:      "leftpad",
+				Version:   "0.0.1",
+				Bundled:   true,
+				BundledBy: "pkg:npm/bundler@1.0.0",
+			}
+
+			Expect(pkg.Component().Properties).To(Equal([]nodemodulebom.Property{
+				{Name: "cdx:npm:package:bundled", Value: "true"},
+				{Name: "paketo:node-module-bom:bundled-by", Value: "pkg:npm/bundler@1.0.0"},
+			}))
+		})
+	})
+}
@@ -0,0 +1,246 @@
+package nodemodulebom
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	// ProjectDescriptorFile is the project descriptor of the application,
+	// whose ConfigSection table configures the SBOM.
+	ProjectDescriptorFile = "project.toml"
+
+	// ConfigFile is the configuration file of the buildpack in the
+	// application, whose top-level keys configure the SBOM. Its settings take
+	// precedence over those of the project descriptor.
+	ConfigFile = ".node-module-bom.toml"
+
+	// ConfigSection is the table of the project descriptor that configures the
+	// SBOM.
+	ConfigSection = "node-module-bom"
+)
+
+// Config is the SBOM configuration of the application, read from the project
+// descriptor and the configuration file. Environment variables take
+// precedence over it, and it takes precedence over the build plan.
+type Config struct {
+	// Formats lists the SBOM formats to write, "cyclonedx" and "spdx".
+	Formats []string `toml:"formats"`
+
+	// Include and Exclude are the patterns of the ComponentFilter.
+	Include []string `toml:"include"`
+	Exclude []string `toml:"exclude"`
+
+	// ProjectPaths lists the directories, or globs of directories, of the Node
+	// projects relative to the application.
+	ProjectPaths []string `toml:"project-paths"`
+
+	// LicenseOverrides maps package names, or package names and versions, to
+	// the licenses to record for them (see OverrideLicenses).
+	LicenseOverrides map[string]string `toml:"license-overrides"`
+
+	// PolicyFiles lists the license policy files relative to the application.
+	PolicyFiles []string `toml:"policy-files"`
+
+	// ComponentsManifest is the path of the Manifest of the declared
+	// components relative to the application, ManifestFile by default.
+	ComponentsManifest string `toml:"components-manifest"`
+
+	// Policies are the license policies of the PolicyFiles.
+	Policies []LicensePolicy `toml:"-"`
+
+	// Files lists the configuration files that were read.
+	Files []string `toml:"-"`
+
+	// Sources records where each setting was read from, such as "project.toml
+	// key node-module-bom.exclude", keyed by the name of the setting.
+	Sources map[string]string `toml:"-"`
+}
+
+// configKeys lists the settings of a Config.
+var configKeys = []string{"formats", "include", "exclude", "project-paths", "license-overrides", "policy-files", "components-manifest"}
+
+// LoadConfig reads the Config of the application in the given working
+// directory from the ConfigSection table of project.toml and from
+// .node-module-bom.toml, where every setting of .node-module-bom.toml replaces
+// that of project.toml. Unknown keys and invalid values are reported along
+// with the file and key they were found at.
+func LoadConfig(workingDir string) (Config, error) {
+	config := Config{Sources: map[string]string{}}
+
+	for _, file := range []struct {
+		name    string
+		section string
+	}{
+		{ProjectDescriptorFile, ConfigSection},
+		{ConfigFile, ""},
+	} {
+		path := filepath.Join(workingDir, file.name)
+		_, err := os.Stat(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return Config{}, fmt.Errorf("failed to stat %s: %w", file.name, err)
+		}
+
+		var (
+			fileConfig Config
+			metadata   toml.MetaData
+		)
+		if file.section != "" {
+			var descriptor struct {
+				Section Config `toml:"node-module-bom"`
+			}
+			metadata, err = toml.DecodeFile(path, &descriptor)
+			fileConfig = descriptor.Section
+		} else {
+			metadata, err = toml.DecodeFile(path, &fileConfig)
+		}
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse %s: %w", file.name, err)
+		}
+
+		if file.section != "" && !metadata.IsDefined(file.section) {
+			continue
+		}
+		config.Files = append(config.Files, file.name)
+
+		for _, key := range metadata.Undecoded() {
+			if file.section == "" || (len(key) > 1 && key[0] == file.section) {
+				return Config{}, fmt.Errorf("failed to parse %s: unknown key %q", file.name, key.String())
+			}
+		}
+
+		for _, key := range configKeys {
+			if !metadata.IsDefined(configKey(file.section, key)...) {
+				continue
+			}
+
+			source := fmt.Sprintf("%s key %s", file.name, strings.Join(configKey(file.section, key), "."))
+			err = config.set(workingDir, key, source, fileConfig)
+			if err != nil {
+				return Config{}, err
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// set validates the given setting of the file configuration and sets it.
+func (c *Config) set(workingDir, key, source string, file Config) error {
+	c.Sources[key] = source
+
+	switch key {
+	case "formats":
+		c.Formats = nil
+		for _, format := range file.Formats {
+			format = strings.ToLower(strings.TrimSpace(format))
+			if _, ok := formatExtensions[format]; !ok {
+				return fmt.Errorf("failed to parse %s: unknown format %q, must be %q or %q", source, format, FormatCycloneDX, FormatSPDX)
+			}
+			c.Formats = append(c.Formats, format)
+		}
+
+	case "include", "exclude":
+		values := file.Include
+		if key == "exclude" {
+			values = file.Exclude
+		}
+
+		patterns, err := parsePatterns(source, values)
+		if err != nil {
+			return err
+		}
+
+		if key == "exclude" {
+			c.Exclude = patterns
+		} else {
+			c.Include = patterns
+		}
+
+	case "project-paths":
+		c.ProjectPaths = nil
+		for _, path := range file.ProjectPaths {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				return fmt.Errorf("failed to parse %s: project paths must not be empty", source)
+			}
+			c.ProjectPaths = append(c.ProjectPaths, path)
+		}
+
+	case "license-overrides":
+		c.LicenseOverrides = map[string]string{}
+		for name, license := range file.LicenseOverrides {
+			license = strings.TrimSpace(license)
+			if license == "" {
+				return fmt.Errorf("failed to parse %s: license of %q must not be empty", source, name)
+			}
+			c.LicenseOverrides[strings.TrimSpace(name)] = license
+		}
+
+	case "policy-files":
+		c.PolicyFiles, c.Policies = nil, nil
+		for _, path := range file.PolicyFiles {
+			clean, err := applicationFile(workingDir, source, "policy file", path)
+			if err != nil {
+				return err
+			}
+
+			policy, err := LoadLicensePolicy(filepath.Join(workingDir, filepath.FromSlash(clean)), clean)
+			if err != nil {
+				return err
+			}
+
+			c.PolicyFiles = append(c.PolicyFiles, clean)
+			c.Policies = append(c.Policies, policy)
+		}
+
+	case "components-manifest":
+		clean, err := applicationFile(workingDir, source, "components manifest", file.ComponentsManifest)
+		if err != nil {
+			return err
+		}
+
+		c.ComponentsManifest = clean
+	}
+
+	return nil
+}
+
+// applicationFile checks that the file of the given setting exists inside of
+// the application, and returns its cleaned path relative to the application.
+func applicationFile(workingDir, source, description, path string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(strings.TrimSpace(path)))
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) || filepath.IsAbs(clean) {
+		return "", fmt.Errorf("failed to parse %s: %s %q must be inside of the application", source, description, path)
+	}
+
+	_, err := os.Stat(filepath.Join(workingDir, clean))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("failed to parse %s: %s %q does not exist", source, description, path)
+		}
+
+		return "", fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+
+	return filepath.ToSlash(clean), nil
+}
+
+// configKey returns the TOML key of the given setting inside of the given
+// table, or of the table itself.
+func configKey(section string, key ...string) []string {
+	if section == "" {
+		return key
+	}
+
+	return append([]string{section}, key...)
+}
@@ -0,0 +1,175 @@
+package nodemodulebom_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testConfig(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("LoadConfig", func() {
+		it("is empty without configuration files", func() {
+			config, err := nodemodulebom.LoadConfig(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.Files).To(BeEmpty())
+			Expect(config.Sources).To(BeEmpty())
+		})
+
+		it("ignores a project descriptor without a node-module-bom table", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte(`
+[_]
+schema-version = "0.2"
+
+[[io.buildpacks.build.env]]
+name = "BP_NODE_VERSION"
+value = "20.*"
+`), 0600)).To(Succeed())
+
+			config, err := nodemodulebom.LoadConfig(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.Files).To(BeEmpty())
+		})
+
+		context("when project.toml and .node-module-bom.toml configure the SBOM", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte(`
+[_]
+schema-version = "0.2"
+
+[node-module-bom]
+formats = ["CycloneDX", "spdx"]
+exclude = ["./test/**", "@acme/test-*"]
+project-paths = ["apps/*"]
+policy-files = ["policies/licenses.toml"]
+
+[node-module-bom.license-overrides]
+leftpad = "MIT"
+"@acme/fonts@1.0.0" = "OFL-1.1"
+`), 0600)).To(Succeed())
+
+				Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom.toml"), []byte(`
+formats = ["spdx"]
+include = ["@acme/test-runtime"]
+`), 0600)).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(workingDir, "policies"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "policies", "licenses.toml"), []byte(`deny-licenses = ["GPL-3.0-only"]`), 0600)).To(Succeed())
+			})
+
+			it("reads both, with .node-module-bom.toml taking precedence", func() {
+				config, err := nodemodulebom.LoadConfig(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(config.Files).To(Equal([]string{"project.toml", ".node-module-bom.toml"}))
+				Expect(config.Formats).To(Equal([]string{"spdx"}))
+				Expect(config.Include).To(Equal([]string{"@acme/test-runtime"}))
+				Expect(config.Exclude).To(Equal([]string{"test/**", "@acme/test-*"}))
+				Expect(config.ProjectPaths).To(Equal([]string{"apps/*"}))
+				Expect(config.LicenseOverrides).To(Equal(map[string]string{
+					"leftpad":           "MIT",
+					"@acme/fonts@1.0.0": "OFL-1.1",
+				}))
+				Expect(config.PolicyFiles).To(Equal([]string{"policies/licenses.toml"}))
+				Expect(config.Policies).To(Equal([]nodemodulebom.LicensePolicy{
+					{Path: "policies/licenses.toml", Deny: []string{"GPL-3.0-only"}},
+				}))
+
+				Expect(config.Sources).To(Equal(map[string]string{
+					"formats":           ".node-module-bom.toml key formats",
+					"include":           ".node-module-bom.toml key include",
+					"exclude":           "project.toml key node-module-bom.exclude",
+					"project-paths":     "project.toml key node-module-bom.project-paths",
+					"license-overrides": "project.toml key node-module-bom.license-overrides",
+					"policy-files":      "project.toml key node-module-bom.policy-files",
+				}))
+			})
+		})
+
+		context("failure cases", func() {
+			it("returns an error when a file is not valid TOML", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom.toml"), []byte(`formats = [`), 0600)).To(Succeed())
+
+				_, err := nodemodulebom.LoadConfig(workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse .node-module-bom.toml:")))
+			})
+
+			it("returns an error when a key has the wrong type", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte("[node-module-bom]\nformats = \"spdx\"\n"), 0600)).To(Succeed())
+
+				_, err := nodemodulebom.LoadConfig(workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse project.toml:")))
+				Expect(err).To(MatchError(ContainSubstring("node-module-bom.formats")))
+			})
+
+			it("returns an error for an unknown key", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte("[node-module-bom]\nformat = [\"spdx\"]\n"), 0600)).To(Succeed())
+
+				_, err := nodemodulebom.LoadConfig(workingDir)
+				Expect(err).To(MatchError(`failed to parse project.toml: unknown key "node-module-bom.format"`))
+			})
+
+			it("returns an error for an unknown format", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom.toml"), []byte(`formats = ["syft"]`), 0600)).To(Succeed())
+
+				_, err := nodemodulebom.LoadConfig(workingDir)
+				Expect(err).To(MatchError(`failed to parse .node-module-bom.toml key formats: unknown format "syft", must be "cyclonedx" or "spdx"`))
+			})
+
+			it("returns an error for a malformed pattern", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte("[node-module-bom]\nexclude = [\"**/[fixtures/**\"]\n"), 0600)).To(Succeed())
+
+				_, err := nodemodulebom.LoadConfig(workingDir)
+				Expect(err).To(MatchError("failed to parse project.toml key node-module-bom.exclude pattern **/[fixtures/**: syntax error in pattern"))
+			})
+
+			it("returns an error for an empty license override", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom.toml"), []byte("[license-overrides]\nleftpad = \" \"\n"), 0600)).To(Succeed())
+
+				_, err := nodemodulebom.LoadConfig(workingDir)
+				Expect(err).To(MatchError(`failed to parse .node-module-bom.toml key license-overrides: license of "leftpad" must not be empty`))
+			})
+
+			it("returns an error when a policy file does not exist", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom.toml"), []byte(`policy-files = ["policy.toml"]`), 0600)).To(Succeed())
+
+				_, err := nodemodulebom.LoadConfig(workingDir)
+				Expect(err).To(MatchError(`failed to parse .node-module-bom.toml key policy-files: policy file "policy.toml" does not exist`))
+			})
+
+			it("returns an error when the components manifest does not exist", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "project.toml"), []byte("[node-module-bom]\ncomponents-manifest = \"sbom/components.toml\"\n"), 0600)).To(Succeed())
+
+				_, err := nodemodulebom.LoadConfig(workingDir)
+				Expect(err).To(MatchError(`failed to parse project.toml key node-module-bom.components-manifest: components manifest "sbom/components.toml" does not exist`))
+			})
+
+			it("returns an error when a policy file is outside of the application", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom.toml"), []byte(`policy-files = ["../policy.toml"]`), 0600)).To(Succeed())
+
+				_, err := nodemodulebom.LoadConfig(workingDir)
+				Expect(err).To(MatchError(`failed to parse .node-module-bom.toml key policy-files: policy file "../policy.toml" must be inside of the application`))
+			})
+		})
+	})
+}
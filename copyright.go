@@ -0,0 +1,264 @@
+package nodemodulebom
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxCopyrightStatements caps the number of copyright statements that are
+	// recorded for a package.
+	maxCopyrightStatements = 20
+
+	// maxCopyrightLength caps the length of the copyright text of a package.
+	maxCopyrightLength = 4096
+
+	// maxStatementLength caps the length of a single copyright statement.
+	maxStatementLength = 300
+
+	// maxSourceHeaders caps the number of source files whose header comment is
+	// searched for copyright statements, and maxHeaderLines the number of
+	// lines of the header that are searched.
+	maxSourceHeaders = 200
+	maxHeaderLines   = 30
+)
+
+var (
+	// copyrightStatement matches a line that starts with a copyright notice,
+	// after any comment markers, and captures the notice.
+	copyrightStatement = regexp.MustCompile(`(?i)^[\s#*/;!<>-]*((?:copyright\b|\(c\)|©).*)$`)
+
+	// copyrightInline matches a copyright notice that follows other text on
+	// its line, such as "MIT © Jane Doe" or "Licensed MIT, (c) 2020 Foo",
+	// and captures the notice. A "(c)" in the middle of a line is only a
+	// copyright sign when a year follows it, as it otherwise tends to number
+	// a list item.
+	copyrightInline = regexp.MustCompile(`(?i)(?:^|[^a-z0-9])(copyright\b.*|©.*|\(c\)\s*(?:1[89]|20)\d\d\b.*)$`)
+
+	// copyrightEvidence tells a copyright statement apart from prose such as
+	// "copyright notice", by requiring a year or a copyright sign.
+	copyrightEvidence = regexp.MustCompile(`(?i)\b(1[89]|20)\d\d\b|\(c\)|©`)
+
+	// copyrightTrailer matches the comment terminators and punctuation that
+	// follow a copyright statement.
+	copyrightTrailer = regexp.MustCompile(`[\s*/.,;-]*(-->)?$`)
+
+	// errStopScan ends the search of the sources once a cap is reached.
+	errStopScan = errors.New("stop scan")
+)
+
+// ExtractCopyrights returns the copyright statements found in the license,
+// notice and readme files at the top of the given package directory, and in
+// the header comments of its JavaScript and TypeScript sources, without
+// descending into the packages nested in its node_modules directory.
+// Statements that only differ in case, whitespace or trailing punctuation are
+// reported once, in the order that they were found, up to a total size cap.
+// The error of the given context is returned when it is done before the
+// sources are searched.
+func ExtractCopyrights(ctx context.Context, dir string) ([]string, error) {
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to resolve package directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package directory: %w", err)
+	}
+
+	var statements copyrightStatements
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		name := strings.ToLower(entry.Name())
+		for _, prefix := range []string{"license", "licence", "copying", "notice", "readme"} {
+			if strings.HasPrefix(name, prefix) {
+				err = statements.scan(filepath.Join(dir, entry.Name()), -1)
+				if err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+	}
+
+	// The license field of a package.json sometimes carries the copyright,
+	// as in "MIT © Jane Doe".
+	pkg, err := readPackageJSON(filepath.Join(dir, "package.json"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	statements.add(pkg.LicenseID())
+
+	var headers int
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if entry.IsDir() {
+			if path != dir && (entry.Name() == "node_modules" || strings.HasPrefix(entry.Name(), ".")) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		switch filepath.Ext(path) {
+		case ".js", ".mjs", ".cjs", ".ts", ".mts", ".cts", ".jsx", ".tsx":
+		default:
+			return nil
+		}
+
+		if headers == maxSourceHeaders || statements.full() {
+			return errStopScan
+		}
+		headers++
+
+		return statements.scan(path, maxHeaderLines)
+	})
+	if err != nil && !errors.Is(err, errStopScan) {
+		return nil, fmt.Errorf("failed to search sources for copyright statements: %w", err)
+	}
+
+	return statements.list, nil
+}
+
+type copyrightStatements struct {
+	list   []string
+	seen   map[string]bool
+	length int
+}
+
+func (s *copyrightStatements) full() bool {
+	return len(s.list) == maxCopyrightStatements || s.length >= maxCopyrightLength
+}
+
+// scan adds the copyright statements of the given file, looking at no more
+// than the given number of lines when it is not negative.
+func (s *copyrightStatements) scan(path string, lines int) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024)
+	for i := 0; scanner.Scan() && (lines < 0 || i < lines); i++ {
+		if s.full() {
+			return nil
+		}
+
+		s.add(scanner.Text())
+	}
+
+	// Lines longer than the buffer, such as those of minified bundles, end
+	// the scan of the file without failing it.
+	if err := scanner.Err(); err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// add adds the copyright statement of the given line, if it has one.
+func (s *copyrightStatements) add(line string) {
+	match := copyrightStatement.FindStringSubmatch(line)
+	if match == nil {
+		match = copyrightInline.FindStringSubmatch(line)
+	}
+
+	if match == nil || !copyrightEvidence.MatchString(match[1]) || s.full() {
+		return
+	}
+
+	statement := strings.Join(strings.Fields(copyrightTrailer.ReplaceAllString(match[1], "")), " ")
+	if len(statement) > maxStatementLength {
+		// The statement is cut at the start of a rune, so that a multi-byte
+		// character such as "©" is never split.
+		n := maxStatementLength
+		for n > 0 && !utf8.RuneStart(statement[n]) {
+			n--
+		}
+		statement = statement[:n]
+	}
+
+	key := strings.ToLower(statement)
+	if s.seen[key] {
+		return
+	}
+
+	if s.length+len(statement)+1 > maxCopyrightLength {
+		s.length = maxCopyrightLength
+		return
+	}
+
+	if s.seen == nil {
+		s.seen = map[string]bool{}
+	}
+	s.seen[key] = true
+	s.list = append(s.list, statement)
+	s.length += len(statement) + 1
+}
+
+// addCopyrights records the copyright statements of every component of the
+// installed tree that has none, returning the number of components that
+// statements were found for.
+func addCopyrights(ctx context.Context, workingDir string, tree InstalledTree, components []Component) ([]Component, int, error) {
+	positions := map[string]int{}
+	for i, component := range components {
+		if component.BOMRef != "" {
+			positions[component.BOMRef] = i
+		}
+	}
+
+	var count int
+	for _, pkg := range tree.Packages {
+		position, ok := positions[pkg.Ref()]
+		if !ok {
+			continue
+		}
+		delete(positions, pkg.Ref())
+
+		if components[position].Copyright != "" {
+			continue
+		}
+
+		statements, err := ExtractCopyrights(ctx, filepath.Join(workingDir, pkg.Path))
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to extract copyright of %s: %w", pkg.Path, err)
+		}
+
+		if len(statements) == 0 {
+			continue
+		}
+
+		components[position].Copyright = strings.Join(statements, "\n")
+		count++
+	}
+
+	return components, count, nil
+}
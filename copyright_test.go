@@ -0,0 +1,124 @@
+package nodemodulebom_test
+
+import (
+	gocontext "context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testCopyright(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		dir string
+	)
+
+	it.Before(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "package")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	context("ExtractCopyrights", func() {
+		it.Before(func() {
+			for path, content := range map[string]string{
+				"LICENSE":   "MIT License\n\nCopyright (c) 2015 Some Author <author@example.com>\n\nThe above copyright notice and this permission notice shall be included in all\ncopyright notice copies or substantial portions of the Software.\n",
+				"README.md": "# leftpad\n\n## License\n\ncopyright (c) 2015   Some Author <author@example.com>.\n© 2018 Another Author\n",
+				"lib/index.js": "/*!\n * leftpad\n * Copyright 2016-2020 Contributors\n * Released under the MIT license\n */\n" +
+					strings.Repeat("// filler\n", 40) + "// Copyright 1999 Too Deep\n",
+				"lib/util.ts":                  "// (c) Util Author\nexport {}\n",
+				"node_modules/nested/index.js": "// Copyright 2021 Nested Author\n",
+				"node_modules/nested/LICENSE":  "Copyright 2021 Nested Author\n",
+				".git/hooks/pre-commit.js":     "// Copyright 2021 Git Hook\n",
+				"docs/notes.txt":               "Copyright 2021 Not A Source\n",
+				"dist/bundle.min.js":           strings.Repeat("x", 128*1024) + "\n// Copyright 2021 Minified\n",
+			} {
+				Expect(os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(dir, path), []byte(content), 0600)).To(Succeed())
+			}
+		})
+
+		it("returns the deduplicated statements of the license files, readme and source headers", func() {
+			statements, err := nodemodulebom.ExtractCopyrights(gocontext.Background(), dir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(statements).To(Equal([]string{
+				"Copyright (c) 2015 Some Author <author@example.com>",
+				"© 2018 Another Author",
+				"Copyright 2016-2020 Contributors",
+				"(c) Util Author",
+			}))
+		})
+
+		context("when the notices follow other text on their line", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name": "leftpad", "license": "MIT © Jane Doe"}`), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(dir, "NOTICE"), []byte("Licensed MIT, (c) 2020 Foo.\nSee (a) the license and (c) the notice.\n"), 0600)).To(Succeed())
+			})
+
+			it("returns the notices without the text that precedes them", func() {
+				statements, err := nodemodulebom.ExtractCopyrights(gocontext.Background(), dir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(statements).To(Equal([]string{
+					"Copyright (c) 2015 Some Author <author@example.com>",
+					"(c) 2020 Foo",
+					"© 2018 Another Author",
+					"© Jane Doe",
+					"Copyright 2016-2020 Contributors",
+					"(c) Util Author",
+				}))
+			})
+		})
+
+		context("when the package has many statements", func() {
+			it.Before(func() {
+				var content string
+				for i := 0; i < 30; i++ {
+					content += fmt.Sprintf("Copyright %d Author %d\n", 1990+i, i)
+				}
+				Expect(os.WriteFile(filepath.Join(dir, "NOTICE"), []byte(content), 0600)).To(Succeed())
+			})
+
+			it("caps the number of statements", func() {
+				statements, err := nodemodulebom.ExtractCopyrights(gocontext.Background(), dir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(statements).To(HaveLen(20))
+			})
+		})
+
+		context("when a statement is too long", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(dir, "NOTICE"), []byte("Copyright 2020 "+strings.Repeat("é", 200)+"\n"), 0600)).To(Succeed())
+			})
+
+			it("cuts it without splitting a character", func() {
+				statements, err := nodemodulebom.ExtractCopyrights(gocontext.Background(), dir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(statements).To(ContainElement("Copyright 2020 " + strings.Repeat("é", 142)))
+				for _, statement := range statements {
+					Expect(utf8.ValidString(statement)).To(BeTrue())
+				}
+			})
+		})
+
+		context("when the directory does not exist", func() {
+			it("returns nothing", func() {
+				statements, err := nodemodulebom.ExtractCopyrights(gocontext.Background(), filepath.Join(dir, "missing"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(statements).To(BeEmpty())
+			})
+		})
+	})
+}
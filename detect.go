@@ -4,14 +4,20 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
 )
 
-func Detect() packit.DetectFunc {
+func Detect(logger scribe.Emitter) packit.DetectFunc {
 	return func(context packit.DetectContext) (packit.DetectResult, error) {
 		plan := packit.BuildPlan{
+			Provides: []packit.BuildPlanProvision{
+				{Name: PlanEntryName},
+			},
 			Requires: []packit.BuildPlanRequirement{
+				{Name: PlanEntryName},
 				{
 					Name: "node",
 					Metadata: map[string]interface{}{
@@ -21,20 +27,64 @@ func Detect() packit.DetectFunc {
 			},
 		}
 
-		_, err := os.Stat(filepath.Join(context.WorkingDir, "node_modules"))
+		config, err := LoadConfig(context.WorkingDir)
 		if err != nil {
-			if errors.Is(err, os.ErrNotExist) {
-				nodeModulesRequirement := packit.BuildPlanRequirement{
-					Name: "node_modules",
-					Metadata: map[string]interface{}{
-						"build": true,
-					},
+			return packit.DetectResult{}, err
+		}
+
+		projectPaths, err := LoadProjectPaths(context.WorkingDir, config, nil)
+		if err != nil {
+			return packit.DetectResult{}, err
+		}
+
+		// Detection passes as long as one of the projects is a Node project,
+		// the build leaves the others out of the SBOM.
+		var (
+			managers        []PackageManager
+			notNodeProjects []string
+			needsModules    bool
+		)
+		for _, projectPath := range projectPaths {
+			packageManager, err := FindPackageManager(projectPath)
+			if err != nil {
+				if errors.Is(err, ErrNotNodeProject) {
+					notNodeProjects = append(notNodeProjects, projectPath)
+					continue
 				}
 
-				plan.Requires = append(plan.Requires, nodeModulesRequirement)
-			} else {
 				return packit.DetectResult{}, err
 			}
+
+			if packageManager.Ignored != "" {
+				logger.Subprocess("Warning: ignoring the packageManager field of %s: %s", filepath.Join(projectPath, "package.json"), packageManager.Ignored)
+			}
+			managers = append(managers, packageManager)
+
+			_, err = os.Stat(filepath.Join(projectPath, "node_modules"))
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					return packit.DetectResult{}, err
+				}
+
+				needsModules = true
+			}
+		}
+
+		if len(managers) == 0 {
+			if len(notNodeProjects) == 1 {
+				return packit.DetectResult{}, packit.Fail.WithMessage("%s is not a Node project: %s", notNodeProjects[0], ErrNotNodeProject)
+			}
+
+			return packit.DetectResult{}, packit.Fail.WithMessage("none of %s is a Node project: %s", strings.Join(notNodeProjects, ", "), ErrNotNodeProject)
+		}
+
+		if needsModules {
+			plan.Requires = append(plan.Requires, packit.BuildPlanRequirement{
+				Name: "node_modules",
+				Metadata: map[string]interface{}{
+					"build": true,
+				},
+			})
 		}
 
 		return packit.DetectResult{Plan: plan}, nil
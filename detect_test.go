@@ -1,6 +1,8 @@
 package nodemodulebom_test
 
 import (
+	"bytes"
+	"fmt"
 	"path/filepath"
 	"testing"
 
@@ -8,6 +10,7 @@ import (
 
 	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
 	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
 	"github.com/sclevine/spec"
 
 	. "github.com/onsi/gomega"
@@ -18,6 +21,7 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		Expect     = NewWithT(t).Expect
 		detect     packit.DetectFunc
 		workingDir string
+		buffer     *bytes.Buffer
 	)
 
 	it.Before(func() {
@@ -25,20 +29,29 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		workingDir, err = os.MkdirTemp("", "working-dir")
 		Expect(err).NotTo(HaveOccurred())
 
-		detect = nodemodulebom.Detect()
+		Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{}`), 0600)).To(Succeed())
+
+		buffer = bytes.NewBuffer(nil)
+		detect = nodemodulebom.Detect(scribe.NewEmitter(buffer))
 	})
 
 	it.After(func() {
 		Expect(os.RemoveAll(workingDir)).To(Succeed())
 	})
 
-	it("returns a plan that provides nothing and requires node and node_modules", func() {
+	it("returns a plan that provides node-module-bom and requires node and node_modules", func() {
 		result, err := detect(packit.DetectContext{
 			WorkingDir: workingDir,
 		})
 		Expect(err).NotTo(HaveOccurred())
 		Expect(result.Plan).To(Equal(packit.BuildPlan{
+			Provides: []packit.BuildPlanProvision{
+				{Name: "node-module-bom"},
+			},
 			Requires: []packit.BuildPlanRequirement{
+				{
+					Name: "node-module-bom",
+				},
 				{
 					Name: "node",
 					Metadata: map[string]interface{}{
@@ -60,13 +73,53 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 			Expect(os.Mkdir(filepath.Join(workingDir, "node_modules"), os.ModePerm)).To(Succeed())
 		})
 
-		it("returns a plan that provides nothing and only requires node", func() {
+		it("returns a plan that provides node-module-bom and only requires node", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan).To(Equal(packit.BuildPlan{
+				Provides: []packit.BuildPlanProvision{
+					{Name: "node-module-bom"},
+				},
+				Requires: []packit.BuildPlanRequirement{
+					{
+						Name: "node-module-bom",
+					},
+					{
+						Name: "node",
+						Metadata: map[string]interface{}{
+							"build": true,
+						},
+					},
+				},
+			}))
+		})
+	})
+
+	context("when BP_NODE_PROJECT_PATH is set", func() {
+		it.Before(func() {
+			Expect(os.Setenv("BP_NODE_PROJECT_PATH", "apps/api")).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(workingDir, "apps", "api", "node_modules"), os.ModePerm)).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_NODE_PROJECT_PATH")).To(Succeed())
+		})
+
+		it("looks for node_modules in the project directory", func() {
 			result, err := detect(packit.DetectContext{
 				WorkingDir: workingDir,
 			})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(result.Plan).To(Equal(packit.BuildPlan{
+				Provides: []packit.BuildPlanProvision{
+					{Name: "node-module-bom"},
+				},
 				Requires: []packit.BuildPlanRequirement{
+					{
+						Name: "node-module-bom",
+					},
 					{
 						Name: "node",
 						Metadata: map[string]interface{}{
@@ -78,7 +131,118 @@ func testDetect(t *testing.T, context spec.G, it spec.S) {
 		})
 	})
 
+	context("when the packageManager field of package.json is unsupported", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"packageManager": "deno@1.0.0"}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-lock.yaml"), []byte("lockfileVersion: '6.0'\n"), 0600)).To(Succeed())
+		})
+
+		it("falls back to the lockfile and logs the problem", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan.Provides).To(Equal([]packit.BuildPlanProvision{{Name: "node-module-bom"}}))
+
+			Expect(buffer.String()).To(ContainSubstring(`Warning: ignoring the packageManager field of %s: failed to parse package.json packageManager "deno@1.0.0": unsupported package manager "deno"`, filepath.Join(workingDir, "package.json")))
+		})
+	})
+
+	context("when the application has several projects", func() {
+		it.Before(func() {
+			Expect(os.Remove(filepath.Join(workingDir, "package.json"))).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(workingDir, "api", "node_modules"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "api", "package.json"), []byte(`{}`), 0600)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(workingDir, "docs"), os.ModePerm)).To(Succeed())
+
+			Expect(os.Setenv("BP_NODE_MODULE_BOM_PROJECT_PATHS", "api:docs")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_NODE_MODULE_BOM_PROJECT_PATHS")).To(Succeed())
+		})
+
+		it("passes when one of them is a Node project", func() {
+			result, err := detect(packit.DetectContext{
+				WorkingDir: workingDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Plan).To(Equal(packit.BuildPlan{
+				Provides: []packit.BuildPlanProvision{
+					{Name: "node-module-bom"},
+				},
+				Requires: []packit.BuildPlanRequirement{
+					{Name: "node-module-bom"},
+					{
+						Name: "node",
+						Metadata: map[string]interface{}{
+							"build": true,
+						},
+					},
+				},
+			}))
+		})
+
+		context("when they are listed by the configuration", func() {
+			it.Before(func() {
+				Expect(os.Unsetenv("BP_NODE_MODULE_BOM_PROJECT_PATHS")).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom.toml"), []byte(`project-paths = ["api", "docs"]`), 0600)).To(Succeed())
+			})
+
+			it("passes when one of them is a Node project", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		context("when none of them is a Node project", func() {
+			it.Before(func() {
+				Expect(os.RemoveAll(filepath.Join(workingDir, "api", "node_modules"))).To(Succeed())
+				Expect(os.Remove(filepath.Join(workingDir, "api", "package.json"))).To(Succeed())
+			})
+
+			it("fails detection", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(fmt.Sprintf("none of %s, %s is a Node project: no package.json, lockfile or node_modules found", filepath.Join(workingDir, "api"), filepath.Join(workingDir, "docs"))))
+			})
+		})
+	})
+
 	context("failure cases", func() {
+		context("the app is not a Node project", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(workingDir, "package.json"))).To(Succeed())
+			})
+
+			it("fails detection", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError(fmt.Sprintf("%s is not a Node project: no package.json, lockfile or node_modules found", workingDir)))
+			})
+		})
+
+		context("BP_NODE_PROJECT_PATH does not exist", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_NODE_PROJECT_PATH", "missing")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_NODE_PROJECT_PATH")).To(Succeed())
+			})
+
+			it("returns an error", func() {
+				_, err := detect(packit.DetectContext{
+					WorkingDir: workingDir,
+				})
+				Expect(err).To(MatchError("expected value derived from BP_NODE_PROJECT_PATH [missing] to be an existing directory"))
+			})
+		})
+
 		context("node_modules directory exists but cannot be stat", func() {
 			it.Before(func() {
 				Expect(os.Chmod(workingDir, 0000)).To(Succeed())
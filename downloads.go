@@ -0,0 +1,437 @@
+package nodemodulebom
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// DownloadedByProperty records the bom-ref of the package whose install
+	// script downloaded an artifact.
+	DownloadedByProperty = "paketo:node-module-bom:downloaded-by"
+
+	// LocationProperty records where a downloaded artifact was found. It is
+	// relative to the application directory when the artifact is inside of
+	// it.
+	LocationProperty = "paketo:node-module-bom:location"
+)
+
+// DownloadedArtifact is a binary that the install script of a package
+// downloaded outside of the node_modules tree, such as the browsers of
+// Puppeteer and Playwright.
+type DownloadedArtifact struct {
+	Name     string
+	Version  string
+	Location string
+	SHA256   string
+
+	// DownloadedBy is the bom-ref of the package that downloaded the
+	// artifact.
+	DownloadedBy string
+}
+
+// Component returns the CycloneDX component describing the artifact.
+func (a DownloadedArtifact) Component() Component {
+	purl := "pkg:generic/" + escapePURL(a.Name, "") + "@" + escapePURL(a.Version, "")
+
+	component := Component{
+		BOMRef:  purl,
+		Type:    "application",
+		Name:    a.Name,
+		Version: a.Version,
+		PURL:    purl,
+		Properties: []Property{
+			{Name: LocationProperty, Value: a.Location},
+		},
+	}
+
+	if a.SHA256 != "" {
+		component.Hashes = []Hash{{Algorithm: "SHA-256", Content: a.SHA256}}
+	}
+
+	if a.DownloadedBy != "" {
+		component.Properties = append(component.Properties, Property{Name: DownloadedByProperty, Value: a.DownloadedBy})
+	}
+
+	return component
+}
+
+// browserExecutables maps the browsers downloaded by Puppeteer and Playwright
+// to the glob, relative to the directory of a browser build, of their main
+// executable.
+var browserExecutables = map[string]string{
+	"chrome":                  "chrome-linux*/chrome",
+	"chrome-headless-shell":   "chrome-headless-shell-linux*/chrome-headless-shell",
+	"chromedriver":            "chromedriver-linux*/chromedriver",
+	"chromium":                "chrome-linux/chrome",
+	"chromium_headless_shell": "chrome-linux/headless_shell",
+	"firefox":                 "firefox/firefox",
+	"webkit":                  "minibrowser-*/MiniBrowser",
+	"ffmpeg":                  "ffmpeg-linux",
+}
+
+// FindDownloadedArtifacts inventories the artifacts that the install scripts
+// of Puppeteer, Playwright, Cypress and sharp download outside of the
+// node_modules tree. The caches of a tool are only searched when one of its
+// packages is installed. Cache locations are read from the same environment
+// variables that the tools honor (PUPPETEER_CACHE_DIR,
+// PLAYWRIGHT_BROWSERS_PATH and CYPRESS_CACHE_FOLDER), falling back to their
+// defaults in the home directory and the application directory. The error of
+// the given context is returned when it is done before the search completes.
+func FindDownloadedArtifacts(ctx context.Context, workingDir string, tree InstalledTree) ([]DownloadedArtifact, error) {
+	home, _ := os.UserHomeDir()
+
+	installed := map[string]InstalledPackage{}
+	for _, pkg := range tree.Packages {
+		if _, ok := installed[pkg.Name]; !ok {
+			installed[pkg.Name] = pkg
+		}
+	}
+
+	downloader := func(names ...string) (InstalledPackage, bool) {
+		for _, name := range names {
+			if pkg, ok := installed[name]; ok {
+				return pkg, true
+			}
+		}
+
+		return InstalledPackage{}, false
+	}
+
+	finder := artifactFinder{ctx: ctx, workingDir: workingDir, seen: map[string]bool{}}
+
+	if pkg, ok := downloader("puppeteer", "puppeteer-core", "@puppeteer/browsers"); ok {
+		for _, dir := range puppeteerCacheDirs(workingDir, home) {
+			err := finder.browsers(dir, pkg, func(browser, build string) (string, string) {
+				// Builds are stored in "<platform>-<buildId>" directories.
+				_, buildID, _ := strings.Cut(build, "-")
+				return browser, buildID
+			}, true)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Puppeteer before v19 downloaded Chromium into the package.
+		err := finder.browsers(filepath.Join(workingDir, pkg.Path, ".local-chromium"), pkg, func(_, build string) (string, string) {
+			_, revision, _ := strings.Cut(build, "-")
+			return "chromium", revision
+		}, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if pkg, ok := downloader("playwright-core", "playwright", "@playwright/test"); ok {
+		dirs := append(playwrightCacheDirs(home), filepath.Join(workingDir, pkg.Path, ".local-browsers"))
+
+		for _, dir := range cacheDirs(dirs...) {
+			err := finder.browsers(dir, pkg, func(_, build string) (string, string) {
+				// Browsers are stored in "<browser>-<revision>" directories.
+				index := strings.LastIndex(build, "-")
+				if index < 0 {
+					return "", ""
+				}
+				return build[:index], build[index+1:]
+			}, false)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if pkg, ok := downloader("cypress"); ok {
+		for _, dir := range cypressCacheDirs(home) {
+			err := finder.cypress(dir, pkg)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, pkg := range tree.Packages {
+		if pkg.Name == "sharp" {
+			err := finder.libvips(pkg)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return finder.artifacts, nil
+}
+
+type artifactFinder struct {
+	ctx        context.Context
+	workingDir string
+	seen       map[string]bool
+	artifacts  []DownloadedArtifact
+}
+
+// browsers adds the browser builds in the given cache directory. The builds
+// are either grouped into a directory per browser, or stored directly in the
+// cache directory, and identify will name the browser and version of each.
+func (f *artifactFinder) browsers(dir string, pkg InstalledPackage, identify func(browser, build string) (string, string), grouped bool) error {
+	groups := map[string]string{"": dir}
+	if grouped {
+		entries, err := readDirNames(dir)
+		if err != nil {
+			return err
+		}
+
+		groups = map[string]string{}
+		for _, entry := range entries {
+			groups[entry] = filepath.Join(dir, entry)
+		}
+	}
+
+	var browsers []string
+	for browser := range groups {
+		browsers = append(browsers, browser)
+	}
+	sort.Strings(browsers)
+
+	for _, browser := range browsers {
+		builds, err := readDirNames(groups[browser])
+		if err != nil {
+			return err
+		}
+
+		for _, build := range builds {
+			name, version := identify(browser, build)
+			if name == "" || version == "" {
+				continue
+			}
+
+			location := filepath.Join(groups[browser], build)
+
+			var executable string
+			if pattern, ok := browserExecutables[name]; ok {
+				matches, _ := filepath.Glob(filepath.Join(location, pattern))
+				if len(matches) > 0 {
+					executable = matches[0]
+				}
+			}
+
+			err = f.add(name, version, location, executable, pkg)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// cypress adds the Cypress binaries in the given cache directory, which are
+// stored in a directory per version.
+func (f *artifactFinder) cypress(dir string, pkg InstalledPackage) error {
+	versions, err := readDirNames(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		location := filepath.Join(dir, version)
+
+		executable := filepath.Join(location, "Cypress", "Cypress")
+		if _, err := os.Stat(executable); err != nil {
+			continue
+		}
+
+		err = f.add("cypress", version, location, executable, pkg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// libvips adds the libvips that sharp before v0.33 downloaded into its
+// vendor directory, described by a versions.json manifest.
+func (f *artifactFinder) libvips(pkg InstalledPackage) error {
+	manifests, err := filepath.Glob(filepath.Join(f.workingDir, pkg.Path, "vendor", "*", "*", "versions.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, manifest := range manifests {
+		content, err := os.ReadFile(manifest)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", manifest, err)
+		}
+
+		var versions struct {
+			Vips string `json:"vips"`
+		}
+		err = json.Unmarshal(content, &versions)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", manifest, err)
+		}
+
+		if versions.Vips == "" {
+			continue
+		}
+
+		location := filepath.Dir(manifest)
+
+		var executable string
+		libraries, _ := filepath.Glob(filepath.Join(location, "lib", "libvips-cpp.*"))
+		if len(libraries) > 0 {
+			executable = libraries[0]
+		}
+
+		err = f.add("libvips", versions.Vips, location, executable, pkg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *artifactFinder) add(name, version, location, executable string, pkg InstalledPackage) error {
+	err := f.ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	if f.seen[name+"@"+version] {
+		return nil
+	}
+	f.seen[name+"@"+version] = true
+
+	artifact := DownloadedArtifact{
+		Name:         name,
+		Version:      version,
+		Location:     location,
+		DownloadedBy: pkg.Ref(),
+	}
+
+	if rel, err := filepath.Rel(f.workingDir, location); err == nil && !strings.HasPrefix(rel, "..") {
+		artifact.Location = filepath.ToSlash(rel)
+	}
+
+	if executable != "" {
+		sum, err := sha256File(executable)
+		if err != nil {
+			return err
+		}
+		artifact.SHA256 = sum
+	}
+
+	f.artifacts = append(f.artifacts, artifact)
+
+	return nil
+}
+
+func sha256File(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// readDirNames returns the sorted names of the directories inside of the
+// given directory, ignoring hidden directories, or nothing when it does not
+// exist.
+func readDirNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// downloadCacheEnv are the environment variables that move the caches that
+// the install scripts of Puppeteer, Playwright and Cypress download into.
+var downloadCacheEnv = []string{"PUPPETEER_CACHE_DIR", "PLAYWRIGHT_BROWSERS_PATH", "CYPRESS_CACHE_FOLDER"}
+
+// downloadCacheDirs returns the cache directories outside of node_modules
+// that the install scripts of Puppeteer, Playwright and Cypress download
+// into for the application in the given working directory.
+func downloadCacheDirs(workingDir string) []string {
+	home, _ := os.UserHomeDir()
+
+	dirs := puppeteerCacheDirs(workingDir, home)
+	dirs = append(dirs, playwrightCacheDirs(home)...)
+	dirs = append(dirs, cypressCacheDirs(home)...)
+
+	return cacheDirs(dirs...)
+}
+
+func puppeteerCacheDirs(workingDir, home string) []string {
+	return cacheDirs(os.Getenv("PUPPETEER_CACHE_DIR"), filepath.Join(workingDir, ".cache", "puppeteer"), joinHome(home, ".cache", "puppeteer"))
+}
+
+// playwrightCacheDirs leaves out the directory inside of the package, which
+// a PLAYWRIGHT_BROWSERS_PATH of "0" selects, as it depends on where the
+// package is installed.
+func playwrightCacheDirs(home string) []string {
+	var dirs []string
+	if path := os.Getenv("PLAYWRIGHT_BROWSERS_PATH"); path != "" && path != "0" {
+		dirs = append(dirs, path)
+	}
+
+	return cacheDirs(append(dirs, joinHome(home, ".cache", "ms-playwright"))...)
+}
+
+func cypressCacheDirs(home string) []string {
+	return cacheDirs(os.Getenv("CYPRESS_CACHE_FOLDER"), joinHome(home, ".cache", "Cypress"))
+}
+
+// cacheDirs returns the given directories without the empty ones and
+// duplicates.
+func cacheDirs(dirs ...string) []string {
+	seen := map[string]bool{}
+
+	var result []string
+	for _, dir := range dirs {
+		if dir == "" || seen[filepath.Clean(dir)] {
+			continue
+		}
+		seen[filepath.Clean(dir)] = true
+		result = append(result, dir)
+	}
+
+	return result
+}
+
+func joinHome(home string, elem ...string) string {
+	if home == "" {
+		return ""
+	}
+
+	return filepath.Join(append([]string{home}, elem...)...)
+}
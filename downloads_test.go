@@ -0,0 +1,205 @@
+package nodemodulebom_test
+
+import (
+	gocontext "context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testDownloads(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+		homeDir    string
+		cypressDir string
+
+		originalHome string
+		tree         nodemodulebom.InstalledTree
+	)
+
+	sum := func(content string) string {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	}
+
+	write := func(path, content string) {
+		Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0600)).To(Succeed())
+	}
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		homeDir, err = os.MkdirTemp("", "home")
+		Expect(err).NotTo(HaveOccurred())
+
+		cypressDir, err = os.MkdirTemp("", "cypress")
+		Expect(err).NotTo(HaveOccurred())
+
+		originalHome = os.Getenv("HOME")
+		Expect(os.Setenv("HOME", homeDir)).To(Succeed())
+		Expect(os.Setenv("CYPRESS_CACHE_FOLDER", cypressDir)).To(Succeed())
+
+		write(filepath.Join(homeDir, ".cache", "puppeteer", "chrome", "linux-120.0.6099.109", "chrome-linux64", "chrome"), "chrome")
+		write(filepath.Join(homeDir, ".cache", "puppeteer", "chrome-headless-shell", "linux-120.0.6099.109", "chrome-headless-shell-linux64", "chrome-headless-shell"), "headless")
+		write(filepath.Join(workingDir, "node_modules", "playwright-core", ".local-browsers", "chromium-1091", "chrome-linux", "chrome"), "chromium")
+		write(filepath.Join(workingDir, "node_modules", "playwright-core", ".local-browsers", "ffmpeg-1009", "ffmpeg-linux"), "ffmpeg")
+		write(filepath.Join(cypressDir, "13.6.0", "Cypress", "Cypress"), "cypress")
+		write(filepath.Join(workingDir, "node_modules", "sharp", "vendor", "8.14.5", "linux-x64", "versions.json"), `{"vips": "8.14.5"}`)
+		write(filepath.Join(workingDir, "node_modules", "sharp", "vendor", "8.14.5", "linux-x64", "lib", "libvips-cpp.so.42"), "libvips")
+
+		tree = nodemodulebom.InstalledTree{
+			Source: "node_modules",
+			Packages: []nodemodulebom.InstalledPackage{
+				{Name: "cypress", Version: "13.6.0", Path: "node_modules/cypress"},
+				{Name: "playwright-core", Version: "1.40.0", Path: "node_modules/playwright-core"},
+				{Name: "puppeteer", Version: "21.6.0", Path: "node_modules/puppeteer"},
+				{Name: "sharp", Version: "0.32.6", Path: "node_modules/sharp"},
+			},
+		}
+	})
+
+	it.After(func() {
+		Expect(os.Setenv("HOME", originalHome)).To(Succeed())
+		Expect(os.Unsetenv("CYPRESS_CACHE_FOLDER")).To(Succeed())
+
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+		Expect(os.RemoveAll(homeDir)).To(Succeed())
+		Expect(os.RemoveAll(cypressDir)).To(Succeed())
+	})
+
+	context("FindDownloadedArtifacts", func() {
+		it("finds the artifacts downloaded by the installed packages", func() {
+			artifacts, err := nodemodulebom.FindDownloadedArtifacts(gocontext.Background(), workingDir, tree)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(artifacts).To(Equal([]nodemodulebom.DownloadedArtifact{
+				{
+					Name:         "chrome",
+					Version:      "120.0.6099.109",
+					Location:     filepath.Join(homeDir, ".cache", "puppeteer", "chrome", "linux-120.0.6099.109"),
+					SHA256:       sum("chrome"),
+					DownloadedBy: "pkg:npm/puppeteer@21.6.0",
+				},
+				{
+					Name:         "chrome-headless-shell",
+					Version:      "120.0.6099.109",
+					Location:     filepath.Join(homeDir, ".cache", "puppeteer", "chrome-headless-shell", "linux-120.0.6099.109"),
+					SHA256:       sum("headless"),
+					DownloadedBy: "pkg:npm/puppeteer@21.6.0",
+				},
+				{
+					Name:         "chromium",
+					Version:      "1091",
+					Location:     "node_modules/playwright-core/.local-browsers/chromium-1091",
+					SHA256:       sum("chromium"),
+					DownloadedBy: "pkg:npm/playwright-core@1.40.0",
+				},
+				{
+					Name:         "ffmpeg",
+					Version:      "1009",
+					Location:     "node_modules/playwright-core/.local-browsers/ffmpeg-1009",
+					SHA256:       sum("ffmpeg"),
+					DownloadedBy: "pkg:npm/playwright-core@1.40.0",
+				},
+				{
+					Name:         "cypress",
+					Version:      "13.6.0",
+					Location:     filepath.Join(cypressDir, "13.6.0"),
+					SHA256:       sum("cypress"),
+					DownloadedBy: "pkg:npm/cypress@13.6.0",
+				},
+				{
+					Name:         "libvips",
+					Version:      "8.14.5",
+					Location:     "node_modules/sharp/vendor/8.14.5/linux-x64",
+					SHA256:       sum("libvips"),
+					DownloadedBy: "pkg:npm/sharp@0.32.6",
+				},
+			}))
+		})
+
+		context("when the downloading packages are not installed", func() {
+			it("ignores their caches", func() {
+				artifacts, err := nodemodulebom.FindDownloadedArtifacts(gocontext.Background(), workingDir, nodemodulebom.InstalledTree{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(artifacts).To(BeEmpty())
+			})
+		})
+
+		context("when PUPPETEER_CACHE_DIR is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("PUPPETEER_CACHE_DIR", filepath.Join(workingDir, "browsers"))).To(Succeed())
+				write(filepath.Join(workingDir, "browsers", "chrome", "linux-121.0.6167.85", "chrome-linux64", "chrome"), "newer")
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("PUPPETEER_CACHE_DIR")).To(Succeed())
+			})
+
+			it("also searches that cache", func() {
+				artifacts, err := nodemodulebom.FindDownloadedArtifacts(gocontext.Background(), workingDir, tree)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(artifacts[0]).To(Equal(nodemodulebom.DownloadedArtifact{
+					Name:         "chrome",
+					Version:      "121.0.6167.85",
+					Location:     "browsers/chrome/linux-121.0.6167.85",
+					SHA256:       sum("newer"),
+					DownloadedBy: "pkg:npm/puppeteer@21.6.0",
+				}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the sharp versions.json cannot be parsed", func() {
+				it.Before(func() {
+					write(filepath.Join(workingDir, "node_modules", "sharp", "vendor", "8.14.5", "linux-x64", "versions.json"), `%%%`)
+				})
+
+				it("returns an error", func() {
+					_, err := nodemodulebom.FindDownloadedArtifacts(gocontext.Background(), workingDir, tree)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse")))
+					Expect(err).To(MatchError(ContainSubstring("versions.json")))
+				})
+			})
+		})
+	})
+
+	context("DownloadedArtifact", func() {
+		context("Component", func() {
+			it("returns an application component", func() {
+				artifact := nodemodulebom.DownloadedArtifact{
+					Name:         "chrome",
+					Version:      "120.0.6099.109",
+					Location:     "/home/cnb/.cache/puppeteer/chrome/linux-120.0.6099.109",
+					SHA256:       "abcdef",
+					DownloadedBy: "pkg:npm/puppeteer@21.6.0",
+				}
+
+				Expect(artifact.Component()).To(Equal(nodemodulebom.Component{
+					BOMRef:  "pkg:generic/chrome@120.0.6099.109",
+					Type:    "application",
+					Name:    "chrome",
+					Version: "120.0.6099.109",
+					PURL:    "pkg:generic/chrome@120.0.6099.109",
+					Hashes: []nodemodulebom.Hash{
+						{Algorithm: "SHA-256", Content: "abcdef"},
+					},
+					Properties: []nodemodulebom.Property{
+						{Name: "paketo:node-module-bom:location", Value: "/home/cnb/.cache/puppeteer/chrome/linux-120.0.6099.109"},
+						{Name: "paketo:node-module-bom:downloaded-by", Value: "pkg:npm/puppeteer@21.6.0"},
+					},
+				}))
+			})
+		})
+	})
+}
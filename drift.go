@@ -0,0 +1,226 @@
+package nodemodulebom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Drift lists the differences between the lockfile of an application and the
+// packages that are installed in its node_modules directory.
+type Drift struct {
+	Lockfile   string           `json:"lockfile"`
+	Missing    []DriftedPackage `json:"missing"`
+	Extra      []DriftedPackage `json:"extra"`
+	Mismatched []DriftedPackage `json:"mismatched"`
+}
+
+// DriftedPackage is a package that is missing from node_modules, installed
+// without being in the lockfile, or installed at a different version than the
+// one in the lockfile.
+type DriftedPackage struct {
+	Name             string `json:"name"`
+	Path             string `json:"path"`
+	LockfileVersion  string `json:"lockfileVersion,omitempty"`
+	InstalledVersion string `json:"installedVersion,omitempty"`
+}
+
+// Empty reports whether the installed packages match the lockfile.
+func (d Drift) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.Mismatched) == 0
+}
+
+// WriteReport writes the drift as a JSON report to the given path.
+func (d Drift) WriteReport(path string) error {
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create drift report directory: %w", err)
+	}
+
+	for _, packages := range []*[]DriftedPackage{&d.Missing, &d.Extra, &d.Mismatched} {
+		if *packages == nil {
+			*packages = []DriftedPackage{}
+		}
+	}
+
+	content, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode drift report: %w", err)
+	}
+
+	err = os.WriteFile(path, content, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write drift report: %w", err)
+	}
+
+	return nil
+}
+
+// InProject returns the drift of the project in the given directory, relative
+// to the application, with the lockfile and package paths made relative to
+// the application.
+func (d Drift) InProject(dir string) Drift {
+	if dir == "." || dir == "" {
+		return d
+	}
+
+	result := Drift{}
+	if d.Lockfile != "" {
+		result.Lockfile = path.Join(dir, d.Lockfile)
+	}
+
+	for _, pair := range []struct {
+		from []DriftedPackage
+		to   *[]DriftedPackage
+	}{
+		{d.Missing, &result.Missing},
+		{d.Extra, &result.Extra},
+		{d.Mismatched, &result.Mismatched},
+	} {
+		for _, pkg := range pair.from {
+			pkg.Path = path.Join(dir, pkg.Path)
+			*pair.to = append(*pair.to, pkg)
+		}
+	}
+
+	return result
+}
+
+// MergeDrifts combines the drift of several projects into one, listing their
+// lockfiles separated by commas.
+func MergeDrifts(drifts ...Drift) Drift {
+	var merged Drift
+	var lockfiles []string
+	for _, drift := range drifts {
+		if drift.Lockfile != "" {
+			lockfiles = append(lockfiles, drift.Lockfile)
+		}
+
+		merged.Missing = append(merged.Missing, drift.Missing...)
+		merged.Extra = append(merged.Extra, drift.Extra...)
+		merged.Mismatched = append(merged.Mismatched, drift.Mismatched...)
+	}
+	merged.Lockfile = strings.Join(lockfiles, ", ")
+
+	return merged
+}
+
+type LockfileDrift struct{}
+
+func NewLockfileDrift() LockfileDrift {
+	return LockfileDrift{}
+}
+
+// Detect compares the lockfile of the application in the given working
+// directory (see FindLockfile) against the packages installed on disk.
+// Development packages that were pruned, optional packages that were not
+// installed, and bundled dependencies, which are never in the lockfile, are
+// not reported. An empty Drift is returned when the application has no
+// lockfile or no node_modules directory. The walk of node_modules stops with
+// the error of the context once the context is done.
+func (LockfileDrift) Detect(ctx context.Context, workingDir string) (Drift, error) {
+	lockfile, err := FindLockfile(workingDir)
+	if err != nil {
+		return Drift{}, err
+	}
+
+	if lockfile.Path == "" {
+		return Drift{}, nil
+	}
+
+	_, err = os.Stat(filepath.Join(workingDir, "node_modules"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Drift{}, nil
+		}
+
+		return Drift{}, fmt.Errorf("failed to stat node_modules: %w", err)
+	}
+
+	read := readNPMLockfile
+	if lockfile.Path == BunLockfile {
+		read = readBunLockfile
+	}
+
+	locked, err := read(filepath.Join(workingDir, lockfile.Path))
+	if err != nil {
+		return Drift{}, err
+	}
+
+	drift := Drift{Lockfile: lockfile.Path}
+	lockedDirs := map[string]bool{}
+	for _, pkg := range locked {
+		dir := filepath.Join(workingDir, pkg.Path)
+
+		manifest, err := readPackageJSON(filepath.Join(dir, "package.json"))
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return Drift{}, err
+			}
+
+			if pkg.Dev || pkg.Optional || len(pkg.OS) > 0 || len(pkg.CPU) > 0 {
+				continue
+			}
+
+			drift.Missing = append(drift.Missing, DriftedPackage{
+				Name:            pkg.Name,
+				Path:            pkg.Path,
+				LockfileVersion: pkg.Version,
+			})
+			continue
+		}
+
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			lockedDirs[real] = true
+		}
+
+		// Dependencies that are not from a registry may record a dependency
+		// specifier in place of their version.
+		if isSpecifier(pkg.Version) {
+			continue
+		}
+
+		if (manifest.Name != "" && manifest.Name != pkg.Name) || manifest.Version != pkg.Version {
+			drift.Mismatched = append(drift.Mismatched, DriftedPackage{
+				Name:             pkg.Name,
+				Path:             pkg.Path,
+				LockfileVersion:  pkg.Version,
+				InstalledVersion: manifest.Version,
+			})
+		}
+	}
+
+	walker := nodeModulesWalker{
+		ctx:        ctx,
+		workingDir: workingDir,
+		visited:    map[string]bool{},
+	}
+
+	installed, err := walker.walk("")
+	if err != nil {
+		return Drift{}, err
+	}
+	markBundled(installed)
+
+	// Packages are matched by the directory that they are installed into, so
+	// that the packages of symlinked workspaces match their lockfile entries.
+	for _, pkg := range installed {
+		real, err := filepath.EvalSymlinks(filepath.Join(workingDir, pkg.Path))
+		if err != nil || lockedDirs[real] || pkg.Bundled {
+			continue
+		}
+
+		drift.Extra = append(drift.Extra, DriftedPackage{
+			Name:             pkg.Name,
+			Path:             pkg.Path,
+			InstalledVersion: pkg.Version,
+		})
+	}
+
+	return drift, nil
+}
@@ -0,0 +1,201 @@
+package nodemodulebom_test
+
+import (
+	gocontext "context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testDrift(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+		detector   nodemodulebom.LockfileDrift
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		for path, content := range map[string]string{
+			"node_modules/leftpad/package.json":  `{"name": "leftpad", "version": "0.0.1"}`,
+			"node_modules/rightpad/package.json": `{"name": "rightpad", "version": "1.0.1"}`,
+			"node_modules/vendored/package.json": `{"name": "vendored", "version": "1.0.0"}`,
+			"packages/app/package.json":          `{"name": "app", "version": "1.0.0"}`,
+		} {
+			Expect(os.MkdirAll(filepath.Join(workingDir, filepath.Dir(path)), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, path), []byte(content), 0600)).To(Succeed())
+		}
+		Expect(os.Symlink(filepath.Join("..", "packages", "app"), filepath.Join(workingDir, "node_modules", "app"))).To(Succeed())
+
+		Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), []byte(`{
+			"lockfileVersion": 3,
+			"packages": {
+				"node_modules/app": {
+					"resolved": "packages/app",
+					"link": true
+				},
+				"packages/app": {
+					"name": "app",
+					"version": "1.0.0"
+				},
+				"node_modules/leftpad": {
+					"version": "0.0.1"
+				},
+				"node_modules/rightpad": {
+					"version": "1.0.0"
+				},
+				"node_modules/missing": {
+					"version": "2.0.0"
+				},
+				"node_modules/pruned": {
+					"version": "1.0.0",
+					"dev": true
+				},
+				"node_modules/fsevents": {
+					"version": "2.3.3",
+					"optional": true,
+					"os": ["darwin"]
+				}
+			}
+		}`), 0600)).To(Succeed())
+
+		detector = nodemodulebom.NewLockfileDrift()
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("Detect", func() {
+		it("reports the packages that are missing, extra or version-mismatched", func() {
+			drift, err := detector.Detect(gocontext.Background(), workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drift).To(Equal(nodemodulebom.Drift{
+				Lockfile: "package-lock.json",
+				Missing: []nodemodulebom.DriftedPackage{
+					{Name: "missing", Path: "node_modules/missing", LockfileVersion: "2.0.0"},
+				},
+				Extra: []nodemodulebom.DriftedPackage{
+					{Name: "vendored", Path: "node_modules/vendored", InstalledVersion: "1.0.0"},
+				},
+				Mismatched: []nodemodulebom.DriftedPackage{
+					{Name: "rightpad", Path: "node_modules/rightpad", LockfileVersion: "1.0.0", InstalledVersion: "1.0.1"},
+				},
+			}))
+			Expect(drift.Empty()).To(BeFalse())
+		})
+
+		context("when the installed packages match the lockfile", func() {
+			it.Before(func() {
+				Expect(os.RemoveAll(filepath.Join(workingDir, "node_modules", "vendored"))).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "rightpad", "package.json"), []byte(`{"name": "rightpad", "version": "1.0.0"}`), 0600)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules", "missing"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "missing", "package.json"), []byte(`{"name": "missing", "version": "2.0.0"}`), 0600)).To(Succeed())
+			})
+
+			it("reports no drift", func() {
+				drift, err := detector.Detect(gocontext.Background(), workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(drift.Lockfile).To(Equal("package-lock.json"))
+				Expect(drift.Empty()).To(BeTrue())
+			})
+		})
+
+		context("when there is no lockfile", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(workingDir, "package-lock.json"))).To(Succeed())
+			})
+
+			it("returns an empty drift", func() {
+				drift, err := detector.Detect(gocontext.Background(), workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(drift).To(Equal(nodemodulebom.Drift{}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the context is done", func() {
+				it("returns the context error", func() {
+					ctx, cancel := gocontext.WithCancel(gocontext.Background())
+					cancel()
+
+					_, err := detector.Detect(ctx, workingDir)
+					Expect(err).To(MatchError(gocontext.Canceled))
+				})
+			})
+
+			context("when the lockfile cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), []byte(`%%%`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := detector.Detect(gocontext.Background(), workingDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse package-lock.json")))
+				})
+			})
+		})
+	})
+
+	context("WriteReport", func() {
+		it("writes the drift as JSON", func() {
+			path := filepath.Join(workingDir, "reports", "drift.json")
+			Expect(nodemodulebom.Drift{Lockfile: "package-lock.json"}.WriteReport(path)).To(Succeed())
+
+			content, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`{
+				"lockfile": "package-lock.json",
+				"missing": [],
+				"extra": [],
+				"mismatched": []
+			}`))
+		})
+	})
+
+	context("InProject", func() {
+		it("makes the lockfile and package paths relative to the application", func() {
+			drift := nodemodulebom.Drift{
+				Lockfile:   "package-lock.json",
+				Missing:    []nodemodulebom.DriftedPackage{{Name: "leftpad", Path: "node_modules/leftpad", LockfileVersion: "1.0.0"}},
+				Mismatched: []nodemodulebom.DriftedPackage{{Name: "rightpad", Path: "node_modules/rightpad", LockfileVersion: "1.0.0", InstalledVersion: "1.0.1"}},
+			}
+
+			Expect(drift.InProject("apps/api")).To(Equal(nodemodulebom.Drift{
+				Lockfile:   "apps/api/package-lock.json",
+				Missing:    []nodemodulebom.DriftedPackage{{Name: "leftpad", Path: "apps/api/node_modules/leftpad", LockfileVersion: "1.0.0"}},
+				Mismatched: []nodemodulebom.DriftedPackage{{Name: "rightpad", Path: "apps/api/node_modules/rightpad", LockfileVersion: "1.0.0", InstalledVersion: "1.0.1"}},
+			}))
+			Expect(drift.InProject(".")).To(Equal(drift))
+		})
+	})
+
+	context("MergeDrifts", func() {
+		it("combines the drift of several projects", func() {
+			Expect(nodemodulebom.MergeDrifts(
+				nodemodulebom.Drift{
+					Lockfile: "api/package-lock.json",
+					Missing:  []nodemodulebom.DriftedPackage{{Name: "leftpad", Path: "api/node_modules/leftpad"}},
+				},
+				nodemodulebom.Drift{},
+				nodemodulebom.Drift{
+					Lockfile: "worker/package-lock.json",
+					Extra:    []nodemodulebom.DriftedPackage{{Name: "rightpad", Path: "worker/node_modules/rightpad"}},
+				},
+			)).To(Equal(nodemodulebom.Drift{
+				Lockfile: "api/package-lock.json, worker/package-lock.json",
+				Missing:  []nodemodulebom.DriftedPackage{{Name: "leftpad", Path: "api/node_modules/leftpad"}},
+				Extra:    []nodemodulebom.DriftedPackage{{Name: "rightpad", Path: "worker/node_modules/rightpad"}},
+			}))
+		})
+	})
+}
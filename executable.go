@@ -0,0 +1,120 @@
+package nodemodulebom
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+)
+
+// ToolExecutable extends pexec.Executable with a context: the program runs in
+// a process group of its own, which is killed when the context is done, so
+// that no process it started keeps running either. The program is looked up
+// and run with the arguments, directory, environment and output streams of
+// the execution just as pexec.Executable does.
+type ToolExecutable struct {
+	name string
+}
+
+// NewToolExecutable returns a ToolExecutable for the program of the given
+// name, or at the given path.
+func NewToolExecutable(name string) ToolExecutable {
+	return ToolExecutable{
+		name: name,
+	}
+}
+
+// Execute runs the program of the given execution, returning the error of
+// the context when the context is done before the program exits.
+func (e ToolExecutable) Execute(ctx context.Context, execution pexec.Execution) error {
+	path, err := lookPath(e.name, execution.Env)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(path, execution.Args...)
+	cmd.Dir = execution.Dir
+	cmd.Env = execution.Env
+	cmd.Stdout = execution.Stdout
+	cmd.Stderr = execution.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-done:
+		}
+	}()
+
+	err = cmd.Wait()
+	close(done)
+
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// processPath is the PATH of the buildpack process, read once at startup so
+// that looking up a program never depends on the environment of the process
+// changing underneath it.
+var processPath = os.Getenv("PATH")
+
+// lookPath finds the program as pexec.Executable does: on the PATH of the
+// given environment when it sets one, and on the PATH of the buildpack process
+// otherwise. Unlike exec.LookPath, it reads the PATH from the environment
+// rather than from the process, which it never changes, so it is safe to call
+// concurrently. Relative directories of the PATH are ignored.
+func lookPath(name string, env []string) (string, error) {
+	if strings.Contains(name, string(filepath.Separator)) {
+		if isExecutable(name) {
+			return name, nil
+		}
+
+		return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+
+	path := processPath
+	for _, variable := range env {
+		if strings.HasPrefix(variable, "PATH=") && variable != "PATH=" {
+			path = strings.TrimPrefix(variable, "PATH=")
+		}
+	}
+
+	for _, dir := range filepath.SplitList(path) {
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+
+		candidate := filepath.Join(dir, name)
+		if isExecutable(candidate) {
+			return candidate, nil
+		}
+	}
+
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// isExecutable returns whether the path is a regular file with one of its
+// execute permission bits set, as exec.LookPath requires.
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
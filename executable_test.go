@@ -0,0 +1,122 @@
+package nodemodulebom_test
+
+import (
+	"bytes"
+	gocontext "context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testExecutable(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect     = NewWithT(t).Expect
+		Eventually = NewWithT(t).Eventually
+
+		binDir     string
+		workingDir string
+		path       string
+	)
+
+	it.Before(func() {
+		var err error
+		binDir, err = os.MkdirTemp("", "bin")
+		Expect(err).NotTo(HaveOccurred())
+
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(binDir, "some-tool"), []byte("#!/bin/sh\necho \"args=$*\"\necho \"dir=$(pwd)\"\necho \"secret=$SOME_SECRET\"\n"), 0700)).To(Succeed())
+		Expect(os.Setenv("SOME_SECRET", "some-value")).To(Succeed())
+
+		path = os.Getenv("PATH")
+	})
+
+	it.After(func() {
+		Expect(os.Unsetenv("SOME_SECRET")).To(Succeed())
+		Expect(os.RemoveAll(binDir)).To(Succeed())
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	it("runs the tool from the PATH of the execution with only its environment", func() {
+		buffer := bytes.NewBuffer(nil)
+		err := nodemodulebom.NewToolExecutable("some-tool").Execute(gocontext.Background(), pexec.Execution{
+			Args:   []string{"-o", "some-file"},
+			Dir:    workingDir,
+			Env:    []string{"PATH=" + binDir + string(os.PathListSeparator) + path},
+			Stdout: buffer,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		workingDir, err = filepath.EvalSymlinks(workingDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(buffer.String()).To(Equal("args=-o some-file\ndir=" + workingDir + "\nsecret=\n"))
+		Expect(os.Getenv("PATH")).To(Equal(path))
+	})
+
+	context("when the context is done before the tool exits", func() {
+		it.Before(func() {
+			Expect(os.WriteFile(filepath.Join(binDir, "some-tool"), []byte("#!/bin/sh\nsleep 30 &\necho \"$!\" > "+filepath.Join(workingDir, "child.pid")+"\nwait\n"), 0700)).To(Succeed())
+		})
+
+		it("kills the tool along with the processes it started", func() {
+			ctx, cancel := gocontext.WithTimeout(gocontext.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			err := nodemodulebom.NewToolExecutable("some-tool").Execute(ctx, pexec.Execution{
+				Env:    []string{"PATH=" + binDir + string(os.PathListSeparator) + path},
+				Stdout: bytes.NewBuffer(nil),
+			})
+			Expect(err).To(MatchError(gocontext.DeadlineExceeded))
+			Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+
+			content, err := os.ReadFile(filepath.Join(workingDir, "child.pid"))
+			Expect(err).NotTo(HaveOccurred())
+
+			pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(func() bool {
+				stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+				if err != nil {
+					return false
+				}
+
+				// A killed process that has not been reaped yet is a zombie.
+				fields := strings.Fields(string(stat)[strings.LastIndex(string(stat), ")")+1:])
+				return len(fields) > 0 && fields[0] != "Z"
+			}).Should(BeFalse())
+		})
+	})
+
+	context("failure cases", func() {
+		it("returns an error when the tool is not on the PATH of the execution", func() {
+			err := nodemodulebom.NewToolExecutable("some-tool").Execute(gocontext.Background(), pexec.Execution{
+				Env: []string{"PATH=" + path},
+			})
+			Expect(err).To(MatchError(ContainSubstring(`exec: "some-tool": executable file not found in $PATH`)))
+			Expect(err).To(MatchError(exec.ErrNotFound))
+		})
+
+		it("returns an error when the tool on the PATH of the execution is not executable", func() {
+			Expect(os.Chmod(filepath.Join(binDir, "some-tool"), 0600)).To(Succeed())
+
+			err := nodemodulebom.NewToolExecutable("some-tool").Execute(gocontext.Background(), pexec.Execution{
+				Env: []string{"PATH=" + binDir + string(os.PathListSeparator) + path},
+			})
+			Expect(err).To(MatchError(exec.ErrNotFound))
+		})
+	})
+}
@@ -0,0 +1,36 @@
+package fakes
+
+import (
+	"context"
+	"sync"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+)
+
+type DriftDetector struct {
+	DetectCall struct {
+		sync.Mutex
+		CallCount int
+		Receives  struct {
+			Ctx        context.Context
+			WorkingDir string
+		}
+		Returns struct {
+			Drift nodemodulebom.Drift
+			Error error
+		}
+		Stub func(context.Context, string) (nodemodulebom.Drift, error)
+	}
+}
+
+func (f *DriftDetector) Detect(param1 context.Context, param2 string) (nodemodulebom.Drift, error) {
+	f.DetectCall.Lock()
+	defer f.DetectCall.Unlock()
+	f.DetectCall.CallCount++
+	f.DetectCall.Receives.Ctx = param1
+	f.DetectCall.Receives.WorkingDir = param2
+	if f.DetectCall.Stub != nil {
+		return f.DetectCall.Stub(param1, param2)
+	}
+	return f.DetectCall.Returns.Drift, f.DetectCall.Returns.Error
+}
@@ -1,6 +1,7 @@
 package fakes
 
 import (
+	"context"
 	"sync"
 
 	"github.com/paketo-buildpacks/packit/v2/pexec"
@@ -11,22 +12,24 @@ type Executable struct {
 		sync.Mutex
 		CallCount int
 		Receives  struct {
+			Ctx       context.Context
 			Execution pexec.Execution
 		}
 		Returns struct {
 			Error error
 		}
-		Stub func(pexec.Execution) error
+		Stub func(context.Context, pexec.Execution) error
 	}
 }
 
-func (f *Executable) Execute(param1 pexec.Execution) error {
+func (f *Executable) Execute(param1 context.Context, param2 pexec.Execution) error {
 	f.ExecuteCall.Lock()
 	defer f.ExecuteCall.Unlock()
 	f.ExecuteCall.CallCount++
-	f.ExecuteCall.Receives.Execution = param1
+	f.ExecuteCall.Receives.Ctx = param1
+	f.ExecuteCall.Receives.Execution = param2
 	if f.ExecuteCall.Stub != nil {
-		return f.ExecuteCall.Stub(param1)
+		return f.ExecuteCall.Stub(param1, param2)
 	}
 	return f.ExecuteCall.Returns.Error
 }
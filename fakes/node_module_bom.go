@@ -1,9 +1,10 @@
 package fakes
 
 import (
+	"context"
 	"sync"
 
-	"github.com/paketo-buildpacks/packit/v2"
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
 )
 
 type NodeModuleBOM struct {
@@ -11,23 +12,55 @@ type NodeModuleBOM struct {
 		sync.Mutex
 		CallCount int
 		Receives  struct {
+			Ctx        context.Context
 			WorkingDir string
+			BinDir     string
 		}
 		Returns struct {
-			BOMEntrySlice []packit.BOMEntry
-			Error         error
+			BOM   nodemodulebom.BOM
+			Error error
 		}
-		Stub func(string) ([]packit.BOMEntry, error)
+		Stub func(context.Context, string, string) (nodemodulebom.BOM, error)
+	}
+	GenerateProjectsCall struct {
+		sync.Mutex
+		CallCount int
+		Receives  struct {
+			Ctx          context.Context
+			WorkingDir   string
+			ProjectPaths []string
+			BinDir       string
+		}
+		Returns struct {
+			BOM   nodemodulebom.BOM
+			Error error
+		}
+		Stub func(context.Context, string, []string, string) (nodemodulebom.BOM, error)
 	}
 }
 
-func (f *NodeModuleBOM) Generate(param1 string) ([]packit.BOMEntry, error) {
+func (f *NodeModuleBOM) Generate(param1 context.Context, param2 string, param3 string) (nodemodulebom.BOM, error) {
 	f.GenerateCall.Lock()
 	defer f.GenerateCall.Unlock()
 	f.GenerateCall.CallCount++
-	f.GenerateCall.Receives.WorkingDir = param1
+	f.GenerateCall.Receives.Ctx = param1
+	f.GenerateCall.Receives.WorkingDir = param2
+	f.GenerateCall.Receives.BinDir = param3
 	if f.GenerateCall.Stub != nil {
-		return f.GenerateCall.Stub(param1)
+		return f.GenerateCall.Stub(param1, param2, param3)
+	}
+	return f.GenerateCall.Returns.BOM, f.GenerateCall.Returns.Error
+}
+func (f *NodeModuleBOM) GenerateProjects(param1 context.Context, param2 string, param3 []string, param4 string) (nodemodulebom.BOM, error) {
+	f.GenerateProjectsCall.Lock()
+	defer f.GenerateProjectsCall.Unlock()
+	f.GenerateProjectsCall.CallCount++
+	f.GenerateProjectsCall.Receives.Ctx = param1
+	f.GenerateProjectsCall.Receives.WorkingDir = param2
+	f.GenerateProjectsCall.Receives.ProjectPaths = param3
+	f.GenerateProjectsCall.Receives.BinDir = param4
+	if f.GenerateProjectsCall.Stub != nil {
+		return f.GenerateProjectsCall.Stub(param1, param2, param3, param4)
 	}
-	return f.GenerateCall.Returns.BOMEntrySlice, f.GenerateCall.Returns.Error
+	return f.GenerateProjectsCall.Returns.BOM, f.GenerateProjectsCall.Returns.Error
 }
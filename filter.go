@@ -0,0 +1,351 @@
+package nodemodulebom
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ExcludeEnv names the environment variable that lists the patterns of the
+	// components to leave out of the SBOM, separated by colons or commas.
+	ExcludeEnv = "BP_NODE_MODULE_BOM_EXCLUDE"
+
+	// IncludeEnv names the environment variable that lists the patterns of the
+	// components to keep in the SBOM even when they match an exclude pattern,
+	// separated by colons or commas.
+	IncludeEnv = "BP_NODE_MODULE_BOM_INCLUDE"
+
+	// FilterExcludeProperty records an exclude pattern that the components of
+	// the BOM were filtered with.
+	FilterExcludeProperty = "paketo:node-module-bom:filter:exclude"
+
+	// FilterIncludeProperty records an include pattern that the components of
+	// the BOM were filtered with.
+	FilterIncludeProperty = "paketo:node-module-bom:filter:include"
+)
+
+// ComponentFilter leaves the components that match its exclude patterns,
+// unless they also match one of its include patterns, out of a BOM.
+//
+// A pattern is either a name pattern or a path pattern. Name patterns, such as
+// "leftpad", "@acme/test-*" or the bare scope "@acme", are matched against the
+// package name of a component. Patterns with any other slash, such as
+// "**/fixtures/**", are path patterns, which are matched against the locations
+// of a component relative to the application: its evidence occurrences, the
+// path of a file component, or the location of a downloaded artifact. Patterns
+// use the syntax of path.Match, where a "**" segment matches any number of
+// path segments.
+type ComponentFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// ExcludedComponent is a component, or some of the locations of a component,
+// that a ComponentFilter left out of a BOM, along with the pattern that
+// matched it.
+type ExcludedComponent struct {
+	BOMRef    string   `json:"bom-ref,omitempty"`
+	Name      string   `json:"name"`
+	Version   string   `json:"version,omitempty"`
+	Pattern   string   `json:"pattern"`
+	Locations []string `json:"locations,omitempty"`
+
+	// Partial is true when only the listed locations of the component were
+	// left out, and the component itself is still part of the BOM.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// LoadComponentFilter reads the ComponentFilter from
+// BP_NODE_MODULE_BOM_INCLUDE and BP_NODE_MODULE_BOM_EXCLUDE.
+func LoadComponentFilter() (ComponentFilter, error) {
+	var filter ComponentFilter
+	for _, list := range []struct {
+		env      string
+		patterns *[]string
+	}{
+		{IncludeEnv, &filter.Include},
+		{ExcludeEnv, &filter.Exclude},
+	} {
+		patterns, err := parsePatterns(list.env, strings.FieldsFunc(os.Getenv(list.env), func(r rune) bool { return r == ':' || r == ',' }))
+		if err != nil {
+			return ComponentFilter{}, err
+		}
+		*list.patterns = patterns
+	}
+
+	return filter, nil
+}
+
+// parsePatterns trims the given patterns, drops the empty ones and checks that
+// the rest are valid. The source names where the patterns come from in errors.
+func parsePatterns(source string, values []string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range values {
+		pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "./")
+		if pattern == "" {
+			continue
+		}
+
+		for _, segment := range strings.Split(pattern, "/") {
+			_, err := path.Match(segment, "")
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s pattern %s: %w", source, pattern, err)
+			}
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, nil
+}
+
+// Empty reports whether the filter has no exclude patterns, in which case it
+// keeps every component.
+func (f ComponentFilter) Empty() bool {
+	return len(f.Exclude) == 0
+}
+
+// Apply returns the BOM without the excluded components, along with what was
+// excluded. A component that is excluded by a path pattern at only some of its
+// locations keeps its other occurrences. File components that only the
+// excluded components depend on, such as their native binaries, are excluded
+// along with them, and the dependency graph drops every excluded component.
+// The patterns are recorded in the metadata of the BOM.
+func (f ComponentFilter) Apply(bom BOM) (BOM, []ExcludedComponent) {
+	if f.Empty() {
+		return bom, nil
+	}
+
+	var (
+		components []Component
+		excluded   []ExcludedComponent
+	)
+	removed := map[string]string{}
+	for _, component := range bom.Components {
+		name := component.PackageName()
+		if component.Type != "file" && f.matchName(f.Include, name) != "" {
+			components = append(components, component)
+			continue
+		}
+
+		if pattern := f.matchName(f.Exclude, name); component.Type != "file" && pattern != "" {
+			excluded = append(excluded, ExcludedComponent{BOMRef: component.BOMRef, Name: name, Version: component.Version, Pattern: pattern})
+			removed[component.BOMRef] = pattern
+			continue
+		}
+
+		var kept, dropped []string
+		pattern := ""
+		locations := componentLocations(component)
+		for _, location := range locations {
+			match := ""
+			if f.matchPath(f.Include, location) == "" {
+				match = f.matchPath(f.Exclude, location)
+			}
+
+			if match == "" {
+				kept = append(kept, location)
+				continue
+			}
+
+			if pattern == "" {
+				pattern = match
+			}
+			dropped = append(dropped, location)
+		}
+
+		if len(dropped) == 0 {
+			components = append(components, component)
+			continue
+		}
+
+		if len(kept) == 0 {
+			excluded = append(excluded, ExcludedComponent{BOMRef: component.BOMRef, Name: name, Version: component.Version, Pattern: pattern, Locations: dropped})
+			removed[component.BOMRef] = pattern
+			continue
+		}
+
+		var occurrences []Occurrence
+		for _, occurrence := range component.Evidence.Occurrences {
+			if containsString(kept, occurrence.Location) {
+				occurrences = append(occurrences, occurrence)
+			}
+		}
+		component.Evidence = &Evidence{Occurrences: occurrences}
+		components = append(components, component)
+
+		excluded = append(excluded, ExcludedComponent{BOMRef: component.BOMRef, Name: name, Version: component.Version, Pattern: pattern, Locations: dropped, Partial: true})
+	}
+
+	// Components without a bom-ref are not part of the dependency graph.
+	delete(removed, "")
+
+	components, excluded = excludeOrphanedFiles(components, excluded, removed, bom.Dependencies)
+
+	var dependencies []Dependency
+	for _, dependency := range bom.Dependencies {
+		if _, ok := removed[dependency.Ref]; ok {
+			continue
+		}
+
+		var dependsOn []string
+		for _, ref := range dependency.DependsOn {
+			if _, ok := removed[ref]; !ok {
+				dependsOn = append(dependsOn, ref)
+			}
+		}
+		dependency.DependsOn = dependsOn
+		dependencies = append(dependencies, dependency)
+	}
+
+	bom.Components = components
+	bom.Dependencies = dependencies
+
+	var properties []Property
+	properties = append(properties, bom.Metadata.Properties...)
+	for _, pattern := range f.Include {
+		properties = append(properties, Property{Name: FilterIncludeProperty, Value: pattern})
+	}
+	for _, pattern := range f.Exclude {
+		properties = append(properties, Property{Name: FilterExcludeProperty, Value: pattern})
+	}
+	bom.Metadata.Properties = properties
+
+	return bom, excluded
+}
+
+// WriteExclusionReport writes the excluded components as a JSON report to the
+// given path.
+func WriteExclusionReport(path string, excluded []ExcludedComponent) error {
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create exclusion report directory: %w", err)
+	}
+
+	if excluded == nil {
+		excluded = []ExcludedComponent{}
+	}
+
+	content, err := json.MarshalIndent(excluded, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode exclusion report: %w", err)
+	}
+
+	err = os.WriteFile(path, content, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write exclusion report: %w", err)
+	}
+
+	return nil
+}
+
+// excludeOrphanedFiles excludes the file components that are only depended on
+// by components that were excluded, with the pattern of the first of them.
+func excludeOrphanedFiles(components []Component, excluded []ExcludedComponent, removed map[string]string, dependencies []Dependency) ([]Component, []ExcludedComponent) {
+	dependents := map[string][]string{}
+	for _, dependency := range dependencies {
+		for _, ref := range dependency.DependsOn {
+			dependents[ref] = append(dependents[ref], dependency.Ref)
+		}
+	}
+
+	var kept []Component
+	for _, component := range components {
+		pattern := ""
+		if component.Type == "file" && len(dependents[component.BOMRef]) > 0 {
+			for _, dependent := range dependents[component.BOMRef] {
+				p, ok := removed[dependent]
+				if !ok {
+					pattern = ""
+					break
+				}
+
+				if pattern == "" {
+					pattern = p
+				}
+			}
+		}
+
+		if pattern == "" {
+			kept = append(kept, component)
+			continue
+		}
+
+		excluded = append(excluded, ExcludedComponent{BOMRef: component.BOMRef, Name: component.Name, Version: component.Version, Pattern: pattern, Locations: componentLocations(component)})
+		removed[component.BOMRef] = pattern
+	}
+
+	return kept, excluded
+}
+
+// componentLocations returns the locations of a component relative to the
+// application: the path of a file component, the location of a downloaded
+// artifact, or its evidence occurrences.
+func componentLocations(component Component) []string {
+	if component.Type == "file" {
+		return []string{component.Name}
+	}
+
+	for _, property := range component.Properties {
+		if property.Name == LocationProperty {
+			return []string{property.Value}
+		}
+	}
+
+	var locations []string
+	if component.Evidence != nil {
+		for _, occurrence := range component.Evidence.Occurrences {
+			locations = append(locations, occurrence.Location)
+		}
+	}
+
+	return locations
+}
+
+// matchName returns the first of the name patterns that matches the package
+// name. A bare scope pattern, such as "@acme", matches every package of the
+// scope.
+func (f ComponentFilter) matchName(patterns []string, name string) string {
+	for _, pattern := range patterns {
+		if isPathPattern(pattern) {
+			continue
+		}
+
+		if matchGlob(pattern, name) {
+			return pattern
+		}
+
+		if strings.HasPrefix(pattern, "@") && !strings.Contains(pattern, "/") {
+			if i := strings.Index(name, "/"); i > 0 && matchGlob(pattern, name[:i]) {
+				return pattern
+			}
+		}
+	}
+
+	return ""
+}
+
+// matchPath returns the first of the path patterns that matches the location.
+func (f ComponentFilter) matchPath(patterns []string, location string) string {
+	for _, pattern := range patterns {
+		if isPathPattern(pattern) && matchGlob(pattern, strings.TrimPrefix(location, "./")) {
+			return pattern
+		}
+	}
+
+	return ""
+}
+
+// isPathPattern reports whether the pattern matches locations rather than
+// package names: it contains a slash other than the one of a scoped name.
+func isPathPattern(pattern string) bool {
+	if !strings.Contains(pattern, "/") {
+		return false
+	}
+
+	return !strings.HasPrefix(pattern, "@") || strings.Count(pattern, "/") > 1
+}
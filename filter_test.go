@@ -0,0 +1,198 @@
+package nodemodulebom_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testFilter(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("LoadComponentFilter", func() {
+		it.After(func() {
+			Expect(os.Unsetenv("BP_NODE_MODULE_BOM_INCLUDE")).To(Succeed())
+			Expect(os.Unsetenv("BP_NODE_MODULE_BOM_EXCLUDE")).To(Succeed())
+		})
+
+		it("reads the include and exclude patterns", func() {
+			Expect(os.Setenv("BP_NODE_MODULE_BOM_INCLUDE", "@acme/runtime")).To(Succeed())
+			Expect(os.Setenv("BP_NODE_MODULE_BOM_EXCLUDE", "@acme/test-*, ./**/fixtures/**:@internal")).To(Succeed())
+
+			filter, err := nodemodulebom.LoadComponentFilter()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(filter).To(Equal(nodemodulebom.ComponentFilter{
+				Include: []string{"@acme/runtime"},
+				Exclude: []string{"@acme/test-*", "**/fixtures/**", "@internal"},
+			}))
+			Expect(filter.Empty()).To(BeFalse())
+		})
+
+		it("is empty without exclude patterns", func() {
+			filter, err := nodemodulebom.LoadComponentFilter()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(filter.Empty()).To(BeTrue())
+		})
+
+		context("failure cases", func() {
+			it("returns an error when a pattern is malformed", func() {
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_EXCLUDE", "**/[fixtures/**")).To(Succeed())
+
+				_, err := nodemodulebom.LoadComponentFilter()
+				Expect(err).To(MatchError("failed to parse BP_NODE_MODULE_BOM_EXCLUDE pattern **/[fixtures/**: syntax error in pattern"))
+			})
+		})
+	})
+
+	context("Apply", func() {
+		var bom nodemodulebom.BOM
+
+		it.Before(func() {
+			bom = nodemodulebom.BOM{
+				Metadata: nodemodulebom.Metadata{
+					Component: &nodemodulebom.Component{BOMRef: "pkg:npm/some-app@1.0.0", Type: "application", Name: "some-app", Version: "1.0.0"},
+				},
+				Components: []nodemodulebom.Component{
+					{
+						BOMRef: "pkg:npm/leftpad@0.0.1", Type: "library", Name: "leftpad", Version: "0.0.1",
+						Evidence: &nodemodulebom.Evidence{Occurrences: []nodemodulebom.Occurrence{
+							{Location: "node_modules/leftpad"},
+							{Location: "test/fixtures/app/node_modules/leftpad"},
+						}},
+					},
+					{
+						BOMRef: "pkg:npm/%40acme/test-utils@1.0.0", Type: "library", Group: "@acme", Name: "test-utils", Version: "1.0.0",
+						Evidence: &nodemodulebom.Evidence{Occurrences: []nodemodulebom.Occurrence{{Location: "node_modules/@acme/test-utils"}}},
+					},
+					{
+						BOMRef: "file:node_modules/@acme/test-utils/build/addon.node", Type: "file", Name: "node_modules/@acme/test-utils/build/addon.node",
+					},
+					{
+						BOMRef: "pkg:npm/%40acme/test-runtime@1.0.0", Type: "library", Group: "@acme", Name: "test-runtime", Version: "1.0.0",
+						Evidence: &nodemodulebom.Evidence{Occurrences: []nodemodulebom.Occurrence{{Location: "node_modules/@acme/test-runtime"}}},
+					},
+					{
+						BOMRef: "pkg:npm/mock-server@2.0.0", Type: "library", Name: "mock-server", Version: "2.0.0",
+						Evidence: &nodemodulebom.Evidence{Occurrences: []nodemodulebom.Occurrence{{Location: "test/fixtures/app/node_modules/mock-server"}}},
+					},
+				},
+				Dependencies: []nodemodulebom.Dependency{
+					{Ref: "pkg:npm/some-app@1.0.0", DependsOn: []string{"pkg:npm/%40acme/test-runtime@1.0.0", "pkg:npm/%40acme/test-utils@1.0.0", "pkg:npm/leftpad@0.0.1"}},
+					{Ref: "pkg:npm/%40acme/test-utils@1.0.0", DependsOn: []string{"file:node_modules/@acme/test-utils/build/addon.node", "pkg:npm/leftpad@0.0.1"}},
+					{Ref: "pkg:npm/leftpad@0.0.1"},
+				},
+			}
+		})
+
+		it("leaves the excluded components and locations out, except for the included ones", func() {
+			filter := nodemodulebom.ComponentFilter{
+				Include: []string{"@acme/test-runtime"},
+				Exclude: []string{"@acme/test-*", "**/fixtures/**"},
+			}
+
+			filtered, excluded := filter.Apply(bom)
+
+			Expect(excluded).To(Equal([]nodemodulebom.ExcludedComponent{
+				{
+					BOMRef:    "pkg:npm/leftpad@0.0.1",
+					Name:      "leftpad",
+					Version:   "0.0.1",
+					Pattern:   "**/fixtures/**",
+					Locations: []string{"test/fixtures/app/node_modules/leftpad"},
+					Partial:   true,
+				},
+				{
+					BOMRef:  "pkg:npm/%40acme/test-utils@1.0.0",
+					Name:    "@acme/test-utils",
+					Version: "1.0.0",
+					Pattern: "@acme/test-*",
+				},
+				{
+					BOMRef:    "pkg:npm/mock-server@2.0.0",
+					Name:      "mock-server",
+					Version:   "2.0.0",
+					Pattern:   "**/fixtures/**",
+					Locations: []string{"test/fixtures/app/node_modules/mock-server"},
+				},
+				{
+					BOMRef:    "file:node_modules/@acme/test-utils/build/addon.node",
+					Name:      "node_modules/@acme/test-utils/build/addon.node",
+					Pattern:   "@acme/test-*",
+					Locations: []string{"node_modules/@acme/test-utils/build/addon.node"},
+				},
+			}))
+
+			var refs []string
+			for _, component := range filtered.Components {
+				refs = append(refs, component.BOMRef)
+			}
+			Expect(refs).To(Equal([]string{"pkg:npm/leftpad@0.0.1", "pkg:npm/%40acme/test-runtime@1.0.0"}))
+			Expect(filtered.Components[0].Evidence.Occurrences).To(Equal([]nodemodulebom.Occurrence{{Location: "node_modules/leftpad"}}))
+
+			Expect(filtered.Dependencies).To(Equal([]nodemodulebom.Dependency{
+				{Ref: "pkg:npm/some-app@1.0.0", DependsOn: []string{"pkg:npm/%40acme/test-runtime@1.0.0", "pkg:npm/leftpad@0.0.1"}},
+				{Ref: "pkg:npm/leftpad@0.0.1"},
+			}))
+
+			Expect(filtered.Metadata.Properties).To(Equal([]nodemodulebom.Property{
+				{Name: "paketo:node-module-bom:filter:include", Value: "@acme/test-runtime"},
+				{Name: "paketo:node-module-bom:filter:exclude", Value: "@acme/test-*"},
+				{Name: "paketo:node-module-bom:filter:exclude", Value: "**/fixtures/**"},
+			}))
+
+			Expect(bom.Components).To(HaveLen(5))
+		})
+
+		it("excludes every package of a bare scope", func() {
+			filtered, excluded := nodemodulebom.ComponentFilter{Exclude: []string{"@acme"}}.Apply(bom)
+
+			Expect(excluded).To(HaveLen(3))
+			Expect(filtered.Components).To(HaveLen(2))
+		})
+
+		it("keeps every component when nothing is excluded", func() {
+			filtered, excluded := nodemodulebom.ComponentFilter{Include: []string{"leftpad"}}.Apply(bom)
+
+			Expect(excluded).To(BeEmpty())
+			Expect(filtered).To(Equal(bom))
+		})
+	})
+
+	context("WriteExclusionReport", func() {
+		var dir string
+
+		it.Before(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "layer")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		it("writes the excluded components as JSON", func() {
+			path := filepath.Join(dir, "reports", "exclusions.json")
+			Expect(nodemodulebom.WriteExclusionReport(path, []nodemodulebom.ExcludedComponent{
+				{BOMRef: "pkg:npm/mock-server@2.0.0", Name: "mock-server", Version: "2.0.0", Pattern: "**/fixtures/**", Locations: []string{"test/fixtures/node_modules/mock-server"}},
+			})).To(Succeed())
+
+			content, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(MatchJSON(`[
+				{
+					"bom-ref": "pkg:npm/mock-server@2.0.0",
+					"name": "mock-server",
+					"version": "2.0.0",
+					"pattern": "**/fixtures/**",
+					"locations": ["test/fixtures/node_modules/mock-server"]
+				}
+			]`))
+		})
+	})
+}
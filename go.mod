@@ -15,4 +15,5 @@ require (
 	github.com/sclevine/spec v1.4.0
 	go.opencensus.io v0.24.0 // indirect
 	google.golang.org/genproto v0.0.0-20221027153422-115e99e71e1c // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
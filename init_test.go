@@ -9,8 +9,34 @@ import (
 
 func TestUnitNodeModuleBOM(t *testing.T) {
 	suite := spec.New("node-module-bom", spec.Report(report.Terminal{}))
+	suite("Application", testApplication)
+	suite("BOM", testBOM)
+	suite("Bundled", testBundled)
 	suite("Build", testBuild)
+	suite("BunLockfile", testBunLockfile)
+	suite("Config", testConfig)
+	suite("Copyright", testCopyright)
 	suite("Detect", testDetect)
+	suite("Downloads", testDownloads)
+	suite("Drift", testDrift)
+	suite("Executable", testExecutable)
+	suite("Filter", testFilter)
+	suite("InstalledTree", testInstalledTree)
+	suite("Licenses", testLicenses)
+	suite("Lockfile", testLockfile)
+	suite("Manifest", testManifest)
 	suite("ModuleBOM", testModuleBOM)
+	suite("Native", testNative)
+	suite("PackageManager", testPackageManager)
+	suite("Plan", testPlan)
+	suite("Policy", testPolicy)
+	suite("ProjectPath", testProjectPath)
+	suite("Projects", testProjects)
+	suite("PURL", testPURL)
+	suite("SBOMCache", testSBOMCache)
+	suite("SPDX", testSPDX)
+	suite("SRI", testSRI)
+	suite("Timeout", testTimeout)
+	suite("Workspaces", testWorkspaces)
 	suite.Run(t)
 }
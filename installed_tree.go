@@ -0,0 +1,830 @@
+package nodemodulebom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// HiddenLockfileSource is the lockfile that npm 7+ writes into node_modules
+	// to describe exactly what has been installed on disk.
+	HiddenLockfileSource = "node_modules/.package-lock.json"
+
+	// DirectoryWalkSource indicates that the installed tree was determined by
+	// walking the node_modules directory.
+	DirectoryWalkSource = "node_modules"
+
+	// PNPMStoreSource is the virtual store that pnpm installs every package
+	// into, and that the node_modules of a pnpm project links to.
+	PNPMStoreSource = "node_modules/.pnpm"
+)
+
+// InstalledPackage is a single package in the installed node_modules tree.
+type InstalledPackage struct {
+	Name         string
+	Version      string
+	Path         string
+	Resolved     string
+	Integrity    string
+	License      string
+	Dev          bool
+	Optional     bool
+	Dependencies map[string]string
+
+	// OS and CPU restrict the platforms that the package is installed on.
+	OS  []string
+	CPU []string
+
+	// BundleDependencies lists the dependencies that the package ships
+	// inside of its own tarball.
+	BundleDependencies []string
+
+	// Bundled is set for packages that were installed as part of the tarball
+	// of another package, and BundledBy is the bom-ref of that package.
+	Bundled   bool
+	BundledBy string
+}
+
+// InstalledTree is the set of packages installed into node_modules along with
+// the source that the set was read from.
+type InstalledTree struct {
+	Source   string
+	Packages []InstalledPackage
+
+	// Cycles lists the paths of symlinked packages that link back to one of
+	// their own ancestors, and which were therefore not descended into.
+	Cycles []string
+
+	// Skipped lists the optional and platform-specific packages of the
+	// lockfile that were not installed, such as the binaries of other
+	// operating systems.
+	Skipped []InstalledPackage
+
+	// Links maps the paths of the symlinks of a pnpm node_modules to the
+	// paths of the packages that they link to, both relative to the working
+	// directory.
+	Links map[string]string
+}
+
+// ReadInstalledTree determines the packages installed in the node_modules
+// directory of the given working directory by the package manager of the
+// project (see FindPackageManager). The virtual store of pnpm is read for
+// pnpm projects. Otherwise the hidden lockfile of npm is preferred as it
+// describes the tree on disk, followed by the application lockfile (see
+// FindLockfile), and finally a walk of the node_modules directory. An empty
+// tree is returned when there is no node_modules directory. The error of the
+// given context is returned when it is done before the tree is read.
+func ReadInstalledTree(ctx context.Context, workingDir string) (InstalledTree, error) {
+	manager, err := FindPackageManager(workingDir)
+	if err != nil && !errors.Is(err, ErrNotNodeProject) {
+		return InstalledTree{}, err
+	}
+
+	return readInstalledTree(ctx, workingDir, manager)
+}
+
+func readInstalledTree(ctx context.Context, workingDir string, manager PackageManager) (InstalledTree, error) {
+	err := ctx.Err()
+	if err != nil {
+		return InstalledTree{}, err
+	}
+
+	_, err = os.Stat(filepath.Join(workingDir, "node_modules"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return InstalledTree{}, nil
+		}
+
+		return InstalledTree{}, fmt.Errorf("failed to stat node_modules: %w", err)
+	}
+
+	if manager.Name == PackageManagerPNPM {
+		tree, ok, err := readPNPMStore(ctx, workingDir)
+		if err != nil || ok {
+			return tree, err
+		}
+	}
+
+	lockfile, err := FindLockfile(workingDir)
+	if err != nil {
+		return InstalledTree{}, err
+	}
+
+	// Only npm writes the hidden lockfile, so one that an earlier npm install
+	// left behind does not describe the node_modules of other package
+	// managers.
+	var sources []string
+	if manager.Name == "" || manager.Name == PackageManagerNPM {
+		sources = append(sources, HiddenLockfileSource)
+	}
+	if lockfile.Path != "" {
+		sources = append(sources, lockfile.Path)
+	}
+
+	for _, source := range sources {
+		read := readNPMLockfile
+		if source == BunLockfile {
+			read = readBunLockfile
+		}
+
+		packages, err := read(filepath.Join(workingDir, source))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return InstalledTree{}, err
+		}
+
+		packages, skipped := partitionInstalled(workingDir, packages)
+
+		packages, err = discoverBundledDependencies(ctx, workingDir, packages)
+		if err != nil {
+			return InstalledTree{}, err
+		}
+		markBundled(packages)
+
+		return InstalledTree{Source: source, Packages: packages, Skipped: skipped}, nil
+	}
+
+	walker := nodeModulesWalker{
+		ctx:        ctx,
+		workingDir: workingDir,
+		visited:    map[string]bool{},
+	}
+
+	packages, err := walker.walk("")
+	if err != nil {
+		return InstalledTree{}, err
+	}
+	sort.Strings(walker.cycles)
+	markBundled(packages)
+
+	return InstalledTree{Source: DirectoryWalkSource, Packages: packages, Cycles: walker.cycles}, nil
+}
+
+// readPNPMStore reads the packages of the virtual store of a pnpm project, in
+// which every package has a directory of its own and its dependencies are
+// symlinks next to it. The symlinks, including those at the top of
+// node_modules, are recorded as the links of the tree. The returned bool is
+// false when the project has no virtual store, as when pnpm installs a
+// hoisted node_modules.
+func readPNPMStore(ctx context.Context, workingDir string) (InstalledTree, bool, error) {
+	entries, err := os.ReadDir(filepath.Join(workingDir, PNPMStoreSource))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return InstalledTree{}, false, nil
+		}
+
+		return InstalledTree{}, false, fmt.Errorf("failed to read %s: %w", PNPMStoreSource, err)
+	}
+
+	root, err := filepath.EvalSymlinks(workingDir)
+	if err != nil {
+		return InstalledTree{}, false, fmt.Errorf("failed to resolve working directory: %w", err)
+	}
+
+	// The node_modules of the virtual store itself holds the links that pnpm
+	// hoists for packages with undeclared dependencies.
+	dirs := []string{"node_modules", PNPMStoreSource + "/node_modules"}
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != "node_modules" {
+			dirs = append(dirs, PNPMStoreSource+"/"+entry.Name()+"/node_modules")
+		}
+	}
+
+	tree := InstalledTree{Source: PNPMStoreSource, Links: map[string]string{}}
+	for _, dir := range dirs {
+		err := ctx.Err()
+		if err != nil {
+			return InstalledTree{}, false, err
+		}
+
+		packages, err := readNodeModulesDir(workingDir, filepath.FromSlash(dir))
+		if err != nil {
+			return InstalledTree{}, false, err
+		}
+
+		for _, entry := range packages {
+			path := dir + "/" + entry.Name()
+
+			if entry.Type()&os.ModeSymlink != 0 {
+				target, err := filepath.EvalSymlinks(filepath.Join(workingDir, filepath.FromSlash(path)))
+				if err != nil {
+					// Links to optional packages that were not installed
+					// dangle.
+					continue
+				}
+
+				if rel, err := filepath.Rel(root, target); err == nil {
+					tree.Links[path] = filepath.ToSlash(rel)
+				}
+				continue
+			}
+
+			if !entry.IsDir() {
+				continue
+			}
+
+			pkg, err := readPackageJSON(filepath.Join(workingDir, filepath.FromSlash(path), "package.json"))
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+
+				return InstalledTree{}, false, err
+			}
+
+			tree.Packages = append(tree.Packages, InstalledPackage{
+				Name:         pkg.Name,
+				Version:      pkg.Version,
+				Path:         path,
+				Resolved:     pkg.Resolved,
+				Integrity:    pkg.Integrity,
+				License:      pkg.LicenseID(),
+				Dependencies: mergeDependencies(pkg.Dependencies, pkg.OptionalDependencies),
+
+				BundleDependencies: pkg.BundledNames(),
+			})
+		}
+	}
+	sortInstalledPackages(tree.Packages)
+
+	tree.Packages, err = discoverBundledDependencies(ctx, workingDir, tree.Packages)
+	if err != nil {
+		return InstalledTree{}, false, err
+	}
+	markBundled(tree.Packages)
+
+	return tree, true, nil
+}
+
+type npmLockfilePackage struct {
+	Name                 string            `json:"name"`
+	Version              string            `json:"version"`
+	Resolved             string            `json:"resolved"`
+	Integrity            string            `json:"integrity"`
+	License              string            `json:"license"`
+	Dev                  bool              `json:"dev"`
+	Optional             bool              `json:"optional"`
+	InBundle             bool              `json:"inBundle"`
+	Link                 bool              `json:"link"`
+	Dependencies         map[string]string `json:"dependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	OS                   []string          `json:"os"`
+	CPU                  []string          `json:"cpu"`
+}
+
+type npmLockfileDependency struct {
+	Version      string                           `json:"version"`
+	Resolved     string                           `json:"resolved"`
+	Integrity    string                           `json:"integrity"`
+	Dev          bool                             `json:"dev"`
+	Optional     bool                             `json:"optional"`
+	Bundled      bool                             `json:"bundled"`
+	Requires     map[string]string                `json:"requires"`
+	Dependencies map[string]npmLockfileDependency `json:"dependencies"`
+}
+
+func readNPMLockfile(path string) ([]InstalledPackage, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lockfile struct {
+		Packages     map[string]npmLockfilePackage    `json:"packages"`
+		Dependencies map[string]npmLockfileDependency `json:"dependencies"`
+	}
+
+	err = json.Unmarshal(content, &lockfile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+	}
+
+	var packages []InstalledPackage
+	if lockfile.Packages != nil {
+		for path, pkg := range lockfile.Packages {
+			if !strings.Contains(path, "node_modules/") {
+				continue
+			}
+
+			resolved := pkg.Resolved
+
+			// Linked packages, such as those of "file:" directory dependencies
+			// and workspaces, are described by the entry of their target.
+			if pkg.Link {
+				target, ok := lockfile.Packages[pkg.Resolved]
+				if !ok {
+					continue
+				}
+
+				resolved = "file:" + pkg.Resolved
+				pkg = target
+			}
+
+			name := pkg.Name
+			if name == "" {
+				name = path[strings.LastIndex(path, "node_modules/")+len("node_modules/"):]
+			}
+
+			packages = append(packages, InstalledPackage{
+				Name:         name,
+				Version:      pkg.Version,
+				Path:         path,
+				Resolved:     resolved,
+				Integrity:    pkg.Integrity,
+				License:      pkg.License,
+				Dev:          pkg.Dev,
+				Optional:     pkg.Optional,
+				Bundled:      pkg.InBundle,
+				Dependencies: mergeDependencies(pkg.Dependencies, pkg.OptionalDependencies),
+				OS:           pkg.OS,
+				CPU:          pkg.CPU,
+			})
+		}
+	} else {
+		packages = flattenNPMLockfileDependencies("", lockfile.Dependencies)
+	}
+
+	sortInstalledPackages(packages)
+
+	return packages, nil
+}
+
+// partitionInstalled separates the optional and platform-specific packages
+// of a lockfile that are missing from node_modules, as package managers skip
+// those that fail to install or do not match the os and cpu of the build.
+func partitionInstalled(workingDir string, packages []InstalledPackage) ([]InstalledPackage, []InstalledPackage) {
+	var installed, skipped []InstalledPackage
+	for _, pkg := range packages {
+		if pkg.Optional || len(pkg.OS) > 0 || len(pkg.CPU) > 0 {
+			_, err := os.Stat(filepath.Join(workingDir, pkg.Path, "package.json"))
+			if errors.Is(err, os.ErrNotExist) {
+				skipped = append(skipped, pkg)
+				continue
+			}
+		}
+
+		installed = append(installed, pkg)
+	}
+
+	return installed, skipped
+}
+
+// flattenNPMLockfileDependencies converts the nested "dependencies" object of
+// a lockfileVersion 1 package-lock.json into a flat list of packages.
+func flattenNPMLockfileDependencies(parent string, dependencies map[string]npmLockfileDependency) []InstalledPackage {
+	var packages []InstalledPackage
+	for name, dependency := range dependencies {
+		path := filepath.ToSlash(filepath.Join(parent, "node_modules", name))
+
+		// Aliased dependencies are recorded with a version of the form
+		// "npm:<name>@<version>".
+		pkgName, version := name, dependency.Version
+		if strings.HasPrefix(version, "npm:") {
+			pkgName, version = splitPackageIdent(strings.TrimPrefix(version, "npm:"))
+		}
+
+		packages = append(packages, InstalledPackage{
+			Name:         pkgName,
+			Version:      version,
+			Path:         path,
+			Resolved:     dependency.Resolved,
+			Integrity:    dependency.Integrity,
+			Dev:          dependency.Dev,
+			Optional:     dependency.Optional,
+			Bundled:      dependency.Bundled,
+			Dependencies: dependency.Requires,
+		})
+
+		packages = append(packages, flattenNPMLockfileDependencies(path, dependency.Dependencies)...)
+	}
+
+	return packages
+}
+
+// Resolve finds the package that Node.js would load when the package at the
+// given path requires the named dependency, searching the node_modules
+// directory of the package and then those of its ancestors.
+func (t InstalledTree) Resolve(path, name string) (InstalledPackage, bool) {
+	index := map[string]InstalledPackage{}
+	for _, pkg := range t.Packages {
+		index[pkg.Path] = pkg
+	}
+
+	return resolvePackage(index, path, name)
+}
+
+func resolvePackage(index map[string]InstalledPackage, path, name string) (InstalledPackage, bool) {
+	for {
+		candidate := "node_modules/" + name
+		if path != "" {
+			candidate = path + "/" + candidate
+		}
+
+		if pkg, ok := index[candidate]; ok {
+			return pkg, true
+		}
+
+		if path == "" {
+			return InstalledPackage{}, false
+		}
+
+		parent := strings.LastIndex(path, "/node_modules/")
+		if parent < 0 {
+			parent = 0
+		}
+		path = path[:parent]
+	}
+}
+
+// Component returns the CycloneDX component describing the installed package.
+func (p InstalledPackage) Component() Component {
+	group, name := splitPackageName(p.Name)
+
+	component := Component{
+		BOMRef:  p.Ref(),
+		Type:    "library",
+		Group:   group,
+		Name:    name,
+		Version: p.resolvedVersion(),
+		PURL:    p.PURL(),
+		Hashes:  ParseIntegrity(p.Integrity),
+	}
+
+	component.Licenses = licenseChoices(p.License)
+
+	if p.Local() {
+		component.Properties = append(component.Properties, Property{Name: FirstPartyProperty, Value: "true"})
+	}
+
+	component.Properties = append(component.Properties, p.bundleProperties()...)
+
+	return component
+}
+
+// licenseChoices converts the license of a package.json into CycloneDX
+// licenses. An identifier of the SPDX License List is recorded as a license
+// ID and any other valid SPDX expression, such as "(MIT OR Apache-2.0)" or
+// "GPL-2.0+", as an expression. Anything else, such as "UNLICENSED" or a
+// license name, is recorded as a license name.
+func licenseChoices(license string) []LicenseChoice {
+	license = strings.TrimSpace(license)
+	if license == "" {
+		return nil
+	}
+
+	expression, err := parseLicenseExpression(license)
+	if err != nil {
+		return []LicenseChoice{{License: &License{Name: license}}}
+	}
+
+	if expression.Operator != "" || expression.Exception != "" || strings.HasSuffix(expression.License, "+") {
+		return []LicenseChoice{{Expression: license}}
+	}
+
+	if id, ok := listedLicenseID(expression.License); ok {
+		return []LicenseChoice{{License: &License{ID: id}}}
+	}
+
+	return []LicenseChoice{{License: &License{Name: license}}}
+}
+
+// Ref returns the CycloneDX bom-ref that identifies the package in the
+// dependency graph.
+func (p InstalledPackage) Ref() string {
+	return PackageURL(p.Name, p.resolvedVersion(), nil)
+}
+
+// PURL returns the purl of the package, qualified with the location that the
+// package was resolved from when that is not the default npm registry.
+func (p InstalledPackage) PURL() string {
+	return PackageURL(p.Name, p.resolvedVersion(), locatePackage(p.Name, p.location()).Qualifiers)
+}
+
+// Local reports whether the package was installed from the local file
+// system, such as with a "file:" dependency.
+func (p InstalledPackage) Local() bool {
+	return locatePackage(p.Name, p.location()).Local
+}
+
+// location returns where the package was resolved from. Some lockfiles
+// record the dependency specifier, such as "github:user/repo#sha", in place
+// of the version of packages that are not from a registry.
+func (p InstalledPackage) location() string {
+	if p.Resolved == "" && isSpecifier(p.Version) {
+		return p.Version
+	}
+
+	return p.Resolved
+}
+
+// resolvedVersion returns the version of the package, or the commit of git
+// dependencies whose version is a dependency specifier.
+func (p InstalledPackage) resolvedVersion() string {
+	if isSpecifier(p.Version) {
+		return locatePackage(p.Name, p.Version).Commit
+	}
+
+	return p.Version
+}
+
+func isSpecifier(version string) bool {
+	return strings.Contains(version, ":")
+}
+
+// splitPackageName splits a scoped package name into its scope and name.
+func splitPackageName(name string) (string, string) {
+	if strings.HasPrefix(name, "@") {
+		if index := strings.Index(name, "/"); index > 0 {
+			return name[:index], name[index+1:]
+		}
+	}
+
+	return "", name
+}
+
+type packageJSON struct {
+	Name                 string            `json:"name"`
+	Version              string            `json:"version"`
+	License              json.RawMessage   `json:"license"`
+	Resolved             string            `json:"_resolved"`
+	Integrity            string            `json:"_integrity"`
+	Dependencies         map[string]string `json:"dependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	BundleDependencies   json.RawMessage   `json:"bundleDependencies"`
+	BundledDependencies  json.RawMessage   `json:"bundledDependencies"`
+	Gypfile              bool              `json:"gypfile"`
+	Binary               json.RawMessage   `json:"binary"`
+	Repository           json.RawMessage   `json:"repository"`
+}
+
+// LicenseID returns the license of the package. Both the SPDX expression
+// string and the deprecated {"type": "..."} object forms are understood.
+func (p packageJSON) LicenseID() string {
+	var id string
+	if json.Unmarshal(p.License, &id) == nil {
+		return id
+	}
+
+	var object struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(p.License, &object) == nil {
+		return object.Type
+	}
+
+	return ""
+}
+
+// RepositoryURL returns the URL of the repository of the package. Both the
+// string and the {"type": "git", "url": "..."} object forms are understood,
+// and hosted git shorthands such as "github:user/repo" or "user/repo" are
+// expanded.
+func (p packageJSON) RepositoryURL() string {
+	var repository string
+	if json.Unmarshal(p.Repository, &repository) != nil {
+		var object struct {
+			URL string `json:"url"`
+		}
+		if json.Unmarshal(p.Repository, &object) != nil {
+			return ""
+		}
+		repository = object.URL
+	}
+
+	if repository != "" && !strings.Contains(repository, ":") && strings.Count(repository, "/") == 1 {
+		repository = "github:" + repository
+	}
+
+	for _, prefix := range []string{"github:", "gitlab:", "bitbucket:", "gist:"} {
+		if strings.HasPrefix(repository, prefix) {
+			return expandGitShorthand(repository)
+		}
+	}
+
+	return repository
+}
+
+// BundledNames returns the names of the dependencies that the package
+// bundles. Both spellings of the field are understood, and a value of true
+// bundles every dependency.
+func (p packageJSON) BundledNames() []string {
+	field := p.BundleDependencies
+	if len(field) == 0 {
+		field = p.BundledDependencies
+	}
+
+	var names []string
+	if json.Unmarshal(field, &names) == nil {
+		return names
+	}
+
+	var all bool
+	if json.Unmarshal(field, &all) == nil && all {
+		for name := range p.Dependencies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	return names
+}
+
+func readPackageJSON(path string) (packageJSON, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return packageJSON{}, err
+	}
+
+	var pkg packageJSON
+	err = json.Unmarshal(content, &pkg)
+	if err != nil {
+		return packageJSON{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return pkg, nil
+}
+
+// nodeModulesWalker reads the package.json of every package in a
+// node_modules directory, descending into the nested node_modules
+// directories of each package. Symlinked packages are followed, but every
+// directory is only descended into once, so that packages linked into
+// several places and symlinks that loop back to an ancestor do not repeat
+// the walk. The walk stops with the error of the context once it is done.
+type nodeModulesWalker struct {
+	ctx        context.Context
+	workingDir string
+	visited    map[string]bool
+	cycles     []string
+}
+
+func (w *nodeModulesWalker) walk(parent string) ([]InstalledPackage, error) {
+	nodeModules := filepath.Join(parent, "node_modules")
+
+	dirs, err := readNodeModulesDir(w.workingDir, nodeModules)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []InstalledPackage
+	for _, entry := range dirs {
+		err := w.ctx.Err()
+		if err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(nodeModules, entry.Name())
+
+		pkg, err := readPackageJSON(filepath.Join(w.workingDir, path, "package.json"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		installed := InstalledPackage{
+			Name:         pkg.Name,
+			Version:      pkg.Version,
+			Path:         filepath.ToSlash(path),
+			Resolved:     pkg.Resolved,
+			Integrity:    pkg.Integrity,
+			License:      pkg.LicenseID(),
+			Dependencies: mergeDependencies(pkg.Dependencies, pkg.OptionalDependencies),
+
+			BundleDependencies: pkg.BundledNames(),
+		}
+
+		target, err := filepath.EvalSymlinks(filepath.Join(w.workingDir, path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+
+		// Symlinked packages are reported as local packages. Those that link
+		// back to an ancestor directory are recorded as cycles and are not
+		// descended into.
+		if entry.Type()&os.ModeSymlink != 0 {
+			root, err := filepath.EvalSymlinks(w.workingDir)
+			if err == nil {
+				if rel, err := filepath.Rel(root, target); err == nil {
+					installed.Resolved = "file:" + filepath.ToSlash(rel)
+				}
+			}
+
+			dir, err := filepath.EvalSymlinks(filepath.Join(w.workingDir, nodeModules))
+			if err == nil && isAncestor(target, dir) {
+				w.cycles = append(w.cycles, filepath.ToSlash(path))
+				packages = append(packages, installed)
+				continue
+			}
+		}
+
+		packages = append(packages, installed)
+
+		if w.visited[target] {
+			continue
+		}
+		w.visited[target] = true
+
+		nested, err := w.walk(path)
+		if err != nil {
+			return nil, err
+		}
+
+		packages = append(packages, nested...)
+	}
+
+	sortInstalledPackages(packages)
+
+	return packages, nil
+}
+
+// readNodeModulesDir returns the package entries of the given node_modules
+// directory, relative to the working directory: its entries without the hidden
+// ones, with the entries of @scope directories named after their scope.
+func readNodeModulesDir(workingDir, nodeModules string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(filepath.Join(workingDir, nodeModules))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to read %s: %w", nodeModules, err)
+	}
+
+	var dirs []os.DirEntry
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		if strings.HasPrefix(entry.Name(), "@") {
+			scoped, err := os.ReadDir(filepath.Join(workingDir, nodeModules, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", filepath.Join(nodeModules, entry.Name()), err)
+			}
+
+			for _, scopedEntry := range scoped {
+				dirs = append(dirs, scopedDirEntry{DirEntry: scopedEntry, scope: entry.Name()})
+			}
+
+			continue
+		}
+
+		dirs = append(dirs, entry)
+	}
+
+	return dirs, nil
+}
+
+// isAncestor reports whether the directory is the given path or one of its
+// ancestors.
+func isAncestor(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// scopedDirEntry is a directory entry inside of an @scope directory whose
+// name includes the scope.
+type scopedDirEntry struct {
+	os.DirEntry
+	scope string
+}
+
+func (e scopedDirEntry) Name() string {
+	return e.scope + "/" + e.DirEntry.Name()
+}
+
+func mergeDependencies(sets ...map[string]string) map[string]string {
+	var merged map[string]string
+	for _, set := range sets {
+		for name, version := range set {
+			if merged == nil {
+				merged = map[string]string{}
+			}
+			merged[name] = version
+		}
+	}
+
+	return merged
+}
+
+func sortInstalledPackages(packages []InstalledPackage) {
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Path < packages[j].Path
+	})
+}
@@ -0,0 +1,399 @@
+package nodemodulebom_test
+
+import (
+	gocontext "context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testInstalledTree(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules", "leftpad"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "leftpad", "package.json"), []byte(`{
+			"name": "leftpad",
+			"version": "0.0.1",
+			"license": "BSD-3-Clause"
+		}`), 0600)).To(Succeed())
+
+		Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules", "@scope", "rightpad", "node_modules", "leftpad"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "@scope", "rightpad", "package.json"), []byte(`{
+			"name": "@scope/rightpad",
+			"version": "1.0.0",
+			"license": {"type": "MIT"},
+			"dependencies": {"leftpad": "^0.0.2"}
+		}`), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "@scope", "rightpad", "node_modules", "leftpad", "package.json"), []byte(`{
+			"name": "leftpad",
+			"version": "0.0.2"
+		}`), 0600)).To(Succeed())
+
+		Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), []byte(`{
+			"lockfileVersion": 2,
+			"packages": {
+				"": {
+					"name": "some-app",
+					"dependencies": {"leftpad": "^0.0.1", "pruned": "^1.0.0"}
+				},
+				"node_modules/leftpad": {
+					"version": "0.0.1",
+					"resolved": "https://registry.npmjs.org/leftpad/-/leftpad-0.0.1.tgz",
+					"integrity": "sha1-hrGk3k+s4YCsVFqD8VA1I9j+0RU=",
+					"license": "BSD-3-Clause"
+				},
+				"node_modules/pruned": {
+					"version": "1.0.0",
+					"dev": true
+				}
+			}
+		}`), 0600)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("ReadInstalledTree", func() {
+		context("when there is a hidden lockfile", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", ".package-lock.json"), []byte(`{
+					"lockfileVersion": 3,
+					"packages": {
+						"node_modules/leftpad": {
+							"version": "0.0.1",
+							"integrity": "sha1-hrGk3k+s4YCsVFqD8VA1I9j+0RU=",
+							"license": "BSD-3-Clause"
+						},
+						"node_modules/@scope/rightpad": {
+							"version": "1.0.0",
+							"optional": true,
+							"dependencies": {"leftpad": "^0.0.2"}
+						},
+						"node_modules/alias": {
+							"name": "leftpad",
+							"version": "0.0.3"
+						},
+						"node_modules/workspace-a": {
+							"resolved": "packages/workspace-a",
+							"link": true
+						},
+						"node_modules/local-lib": {
+							"resolved": "../local-lib",
+							"link": true
+						},
+						"../local-lib": {
+							"name": "local-lib",
+							"version": "1.2.3",
+							"dependencies": {"leftpad": "^0.0.1"}
+						}
+					}
+				}`), 0600)).To(Succeed())
+			})
+
+			it("prefers the hidden lockfile", func() {
+				tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tree).To(Equal(nodemodulebom.InstalledTree{
+					Source: "node_modules/.package-lock.json",
+					Packages: []nodemodulebom.InstalledPackage{
+						{
+							Name:         "@scope/rightpad",
+							Version:      "1.0.0",
+							Path:         "node_modules/@scope/rightpad",
+							Optional:     true,
+							Dependencies: map[string]string{"leftpad": "^0.0.2"},
+						},
+						{
+							Name:    "leftpad",
+							Version: "0.0.3",
+							Path:    "node_modules/alias",
+						},
+						{
+							Name:      "leftpad",
+							Version:   "0.0.1",
+							Path:      "node_modules/leftpad",
+							Integrity: "sha1-hrGk3k+s4YCsVFqD8VA1I9j+0RU=",
+							License:   "BSD-3-Clause",
+						},
+						{
+							Name:         "local-lib",
+							Version:      "1.2.3",
+							Path:         "node_modules/local-lib",
+							Resolved:     "file:../local-lib",
+							Dependencies: map[string]string{"leftpad": "^0.0.1"},
+						},
+					},
+				}))
+			})
+		})
+
+		context("when there is only a package-lock.json", func() {
+			it("falls back to the package-lock.json", func() {
+				tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tree).To(Equal(nodemodulebom.InstalledTree{
+					Source: "package-lock.json",
+					Packages: []nodemodulebom.InstalledPackage{
+						{
+							Name:      "leftpad",
+							Version:   "0.0.1",
+							Path:      "node_modules/leftpad",
+							Resolved:  "https://registry.npmjs.org/leftpad/-/leftpad-0.0.1.tgz",
+							Integrity: "sha1-hrGk3k+s4YCsVFqD8VA1I9j+0RU=",
+							License:   "BSD-3-Clause",
+						},
+						{
+							Name:    "pruned",
+							Version: "1.0.0",
+							Path:    "node_modules/pruned",
+							Dev:     true,
+						},
+					},
+				}))
+			})
+
+			context("when there is also an npm-shrinkwrap.json", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "npm-shrinkwrap.json"), []byte(`{
+						"lockfileVersion": 3,
+						"packages": {
+							"node_modules/leftpad": {
+								"version": "0.0.1"
+							}
+						}
+					}`), 0600)).To(Succeed())
+				})
+
+				it("prefers the npm-shrinkwrap.json", func() {
+					tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(tree).To(Equal(nodemodulebom.InstalledTree{
+						Source: "npm-shrinkwrap.json",
+						Packages: []nodemodulebom.InstalledPackage{
+							{
+								Name:    "leftpad",
+								Version: "0.0.1",
+								Path:    "node_modules/leftpad",
+							},
+						},
+					}))
+				})
+			})
+
+			context("when the package-lock.json is lockfileVersion 1", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), []byte(`{
+						"lockfileVersion": 1,
+						"dependencies": {
+							"alias": {
+								"version": "npm:leftpad@0.0.3"
+							},
+							"rightpad": {
+								"version": "1.0.0",
+								"requires": {"leftpad": "^0.0.2"},
+								"dependencies": {
+									"leftpad": {
+										"version": "0.0.2",
+										"integrity": "sha512-AAAA"
+									}
+								}
+							}
+						}
+					}`), 0600)).To(Succeed())
+				})
+
+				it("flattens the nested and aliased dependencies", func() {
+					tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(tree).To(Equal(nodemodulebom.InstalledTree{
+						Source: "package-lock.json",
+						Packages: []nodemodulebom.InstalledPackage{
+							{
+								Name:    "leftpad",
+								Version: "0.0.3",
+								Path:    "node_modules/alias",
+							},
+							{
+								Name:         "rightpad",
+								Version:      "1.0.0",
+								Path:         "node_modules/rightpad",
+								Dependencies: map[string]string{"leftpad": "^0.0.2"},
+							},
+							{
+								Name:      "leftpad",
+								Version:   "0.0.2",
+								Path:      "node_modules/rightpad/node_modules/leftpad",
+								Integrity: "sha512-AAAA",
+							},
+						},
+					}))
+				})
+			})
+		})
+
+		context("when there is no lockfile", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(workingDir, "package-lock.json"))).To(Succeed())
+			})
+
+			it("walks the node_modules directory", func() {
+				tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tree).To(Equal(nodemodulebom.InstalledTree{
+					Source: "node_modules",
+					Packages: []nodemodulebom.InstalledPackage{
+						{
+							Name:         "@scope/rightpad",
+							Version:      "1.0.0",
+							Path:         "node_modules/@scope/rightpad",
+							License:      "MIT",
+							Dependencies: map[string]string{"leftpad": "^0.0.2"},
+						},
+						{
+							Name:    "leftpad",
+							Version: "0.0.2",
+							Path:    "node_modules/@scope/rightpad/node_modules/leftpad",
+						},
+						{
+							Name:    "leftpad",
+							Version: "0.0.1",
+							Path:    "node_modules/leftpad",
+							License: "BSD-3-Clause",
+						},
+					},
+				}))
+			})
+		})
+
+		context("when node_modules contains a symlinked package", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(workingDir, "package-lock.json"))).To(Succeed())
+				Expect(os.RemoveAll(filepath.Join(workingDir, "node_modules", "@scope"))).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(workingDir, "lib", "node_modules", "nested"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "lib", "package.json"), []byte(`{"name": "lib", "version": "1.0.0"}`), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "lib", "node_modules", "nested", "package.json"), []byte(`{"name": "nested", "version": "1.0.0"}`), 0600)).To(Succeed())
+				Expect(os.Symlink(filepath.Join("..", "lib"), filepath.Join(workingDir, "node_modules", "lib"))).To(Succeed())
+			})
+
+			it("records the package as a local package and walks its dependencies", func() {
+				tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tree.Packages).To(Equal([]nodemodulebom.InstalledPackage{
+					{
+						Name:    "leftpad",
+						Version: "0.0.1",
+						Path:    "node_modules/leftpad",
+						License: "BSD-3-Clause",
+					},
+					{
+						Name:     "lib",
+						Version:  "1.0.0",
+						Path:     "node_modules/lib",
+						Resolved: "file:lib",
+					},
+					{
+						Name:    "nested",
+						Version: "1.0.0",
+						Path:    "node_modules/lib/node_modules/nested",
+					},
+				}))
+				Expect(tree.Cycles).To(BeEmpty())
+			})
+
+			context("when the symlinks form a cycle", func() {
+				it.Before(func() {
+					Expect(os.Symlink("..", filepath.Join(workingDir, "lib", "node_modules", "lib"))).To(Succeed())
+					Expect(os.Symlink(filepath.Join("..", "lib"), filepath.Join(workingDir, "node_modules", "other"))).To(Succeed())
+				})
+
+				it("records every location once without descending into the cycle", func() {
+					tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+					Expect(err).NotTo(HaveOccurred())
+
+					var paths []string
+					for _, pkg := range tree.Packages {
+						paths = append(paths, pkg.Path)
+					}
+					Expect(paths).To(Equal([]string{
+						"node_modules/leftpad",
+						"node_modules/lib",
+						"node_modules/lib/node_modules/lib",
+						"node_modules/lib/node_modules/nested",
+						"node_modules/other",
+					}))
+					Expect(tree.Cycles).To(Equal([]string{"node_modules/lib/node_modules/lib"}))
+				})
+			})
+		})
+
+		context("when there is no node_modules directory", func() {
+			it.Before(func() {
+				Expect(os.RemoveAll(filepath.Join(workingDir, "node_modules"))).To(Succeed())
+			})
+
+			it("returns an empty tree", func() {
+				tree, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tree).To(Equal(nodemodulebom.InstalledTree{}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the hidden lockfile cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", ".package-lock.json"), []byte(`%%%`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse .package-lock.json")))
+				})
+			})
+
+			context("when a package.json in node_modules cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.Remove(filepath.Join(workingDir, "package-lock.json"))).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "leftpad", "package.json"), []byte(`%%%`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := nodemodulebom.ReadInstalledTree(gocontext.Background(), workingDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse")))
+					Expect(err).To(MatchError(ContainSubstring(filepath.Join("leftpad", "package.json"))))
+				})
+			})
+		})
+	})
+
+	context("InstalledPackage.Component", func() {
+		it("records the license of the package as an ID, an expression or a name", func() {
+			licenses := func(license string) []nodemodulebom.LicenseChoice {
+				return nodemodulebom.InstalledPackage{Name: "leftpad", Version: "1.0.0", License: license}.Component().Licenses
+			}
+
+			Expect(licenses("")).To(BeEmpty())
+			Expect(licenses("mit")).To(Equal([]nodemodulebom.LicenseChoice{{License: &nodemodulebom.License{ID: "MIT"}}}))
+			Expect(licenses("(MIT OR Apache-2.0)")).To(Equal([]nodemodulebom.LicenseChoice{{Expression: "(MIT OR Apache-2.0)"}}))
+			Expect(licenses("GPL-2.0-only WITH Classpath-exception-2.0")).To(Equal([]nodemodulebom.LicenseChoice{{Expression: "GPL-2.0-only WITH Classpath-exception-2.0"}}))
+			Expect(licenses("LGPL-2.1+")).To(Equal([]nodemodulebom.LicenseChoice{{Expression: "LGPL-2.1+"}}))
+			Expect(licenses("UNLICENSED")).To(Equal([]nodemodulebom.LicenseChoice{{License: &nodemodulebom.License{Name: "UNLICENSED"}}}))
+			Expect(licenses("SEE LICENSE IN LICENSE.txt")).To(Equal([]nodemodulebom.LicenseChoice{{License: &nodemodulebom.License{Name: "SEE LICENSE IN LICENSE.txt"}}}))
+		})
+	})
+}
@@ -0,0 +1,240 @@
+package nodemodulebom
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// licenseIDString matches the license and exception identifiers of an SPDX
+// license expression, including LicenseRef- and DocumentRef- references and
+// the "+" suffix of "or later" licenses.
+var licenseIDString = regexp.MustCompile(`^(DocumentRef-[A-Za-z0-9.-]+:)?[A-Za-z0-9.-]+\+?$`)
+
+// spdxLicenseList and spdxExceptionList hold the license and exception
+// identifiers of the SPDX License List, one per line.
+var (
+	//go:embed license-list/licenses.txt
+	spdxLicenseList string
+
+	//go:embed license-list/exceptions.txt
+	spdxExceptionList string
+)
+
+var (
+	spdxLicenseIDs   map[string]string
+	spdxExceptionIDs map[string]string
+	spdxListsOnce    sync.Once
+)
+
+// loadSPDXLists indexes the identifiers of the SPDX License List by their
+// lower case, as SPDX identifiers are matched regardless of their case.
+func loadSPDXLists() {
+	spdxListsOnce.Do(func() {
+		index := func(list string) map[string]string {
+			ids := map[string]string{}
+			for _, line := range strings.Split(list, "\n") {
+				line = strings.TrimSpace(line)
+				if line != "" && !strings.HasPrefix(line, "#") {
+					ids[strings.ToLower(line)] = line
+				}
+			}
+
+			return ids
+		}
+
+		spdxLicenseIDs = index(spdxLicenseList)
+		spdxExceptionIDs = index(spdxExceptionList)
+	})
+}
+
+// listedLicenseID returns the identifier of the SPDX License List that the
+// given license identifier names, keeping its "+" suffix, and whether there is
+// one.
+func listedLicenseID(id string) (string, bool) {
+	loadSPDXLists()
+
+	plus := ""
+	if strings.HasSuffix(id, "+") {
+		id, plus = strings.TrimSuffix(id, "+"), "+"
+	}
+
+	listed, ok := spdxLicenseIDs[strings.ToLower(id)]
+	return listed + plus, ok
+}
+
+// listedExceptionID returns the identifier of the SPDX License List that the
+// given exception identifier names, and whether there is one.
+func listedExceptionID(id string) (string, bool) {
+	loadSPDXLists()
+
+	listed, ok := spdxExceptionIDs[strings.ToLower(id)]
+	return listed, ok
+}
+
+// licenseExpression is a parsed SPDX license expression: either a license,
+// with an optional exception, or the conjunction ("AND") or disjunction
+// ("OR") of its operands.
+type licenseExpression struct {
+	Operator  string
+	Operands  []licenseExpression
+	License   string
+	Exception string
+}
+
+// parseLicenseExpression parses the given SPDX license expression. Operators
+// are recognized regardless of their case. Only the syntax of the expression
+// is checked, not whether its identifiers are on the SPDX License List (see
+// listedLicenseID).
+func parseLicenseExpression(value string) (licenseExpression, error) {
+	parser := licenseExpressionParser{
+		tokens: strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(value)),
+	}
+
+	if len(parser.tokens) == 0 {
+		return licenseExpression{}, fmt.Errorf("empty license expression")
+	}
+
+	expression, err := parser.disjunction()
+	if err != nil {
+		return licenseExpression{}, fmt.Errorf("invalid license expression %q: %w", value, err)
+	}
+
+	if parser.position < len(parser.tokens) {
+		return licenseExpression{}, fmt.Errorf("invalid license expression %q: unexpected %q", value, parser.tokens[parser.position])
+	}
+
+	return expression, nil
+}
+
+// String renders the expression with upper case operators, and parentheses
+// only where the precedence of "AND" over "OR" requires them.
+func (e licenseExpression) String() string {
+	if e.Operator == "" {
+		if e.Exception != "" {
+			return e.License + " WITH " + e.Exception
+		}
+
+		return e.License
+	}
+
+	var operands []string
+	for _, operand := range e.Operands {
+		rendered := operand.String()
+		if operand.Operator == "OR" && e.Operator == "AND" {
+			rendered = "(" + rendered + ")"
+		}
+		operands = append(operands, rendered)
+	}
+
+	return strings.Join(operands, " "+e.Operator+" ")
+}
+
+type licenseExpressionParser struct {
+	tokens   []string
+	position int
+}
+
+func (p *licenseExpressionParser) peek() string {
+	if p.position < len(p.tokens) {
+		return p.tokens[p.position]
+	}
+
+	return ""
+}
+
+func (p *licenseExpressionParser) disjunction() (licenseExpression, error) {
+	return p.operation("OR", p.conjunction)
+}
+
+func (p *licenseExpressionParser) conjunction() (licenseExpression, error) {
+	return p.operation("AND", p.term)
+}
+
+// operation parses the operands of the given operator, which the operand
+// function parses, flattening nested operations of the same operator.
+func (p *licenseExpressionParser) operation(operator string, operand func() (licenseExpression, error)) (licenseExpression, error) {
+	first, err := operand()
+	if err != nil {
+		return licenseExpression{}, err
+	}
+
+	operands := []licenseExpression{first}
+	for strings.EqualFold(p.peek(), operator) {
+		p.position++
+
+		next, err := operand()
+		if err != nil {
+			return licenseExpression{}, err
+		}
+
+		if next.Operator == operator {
+			operands = append(operands, next.Operands...)
+		} else {
+			operands = append(operands, next)
+		}
+	}
+
+	if len(operands) == 1 {
+		return first, nil
+	}
+
+	if first.Operator == operator {
+		operands = append(append([]licenseExpression{}, first.Operands...), operands[1:]...)
+	}
+
+	return licenseExpression{Operator: operator, Operands: operands}, nil
+}
+
+func (p *licenseExpressionParser) term() (licenseExpression, error) {
+	token := p.peek()
+	switch {
+	case token == "":
+		return licenseExpression{}, fmt.Errorf("unexpected end")
+
+	case token == "(":
+		p.position++
+		expression, err := p.disjunction()
+		if err != nil {
+			return licenseExpression{}, err
+		}
+
+		if p.peek() != ")" {
+			return licenseExpression{}, fmt.Errorf("missing closing parenthesis")
+		}
+		p.position++
+
+		return expression, nil
+
+	case isLicenseOperator(token) || token == ")" || !licenseIDString.MatchString(token):
+		return licenseExpression{}, fmt.Errorf("unexpected %q", token)
+	}
+	p.position++
+
+	expression := licenseExpression{License: token}
+	if strings.EqualFold(p.peek(), "WITH") {
+		p.position++
+
+		exception := p.peek()
+		if exception == "" || isLicenseOperator(exception) || !licenseIDString.MatchString(exception) {
+			return licenseExpression{}, fmt.Errorf("missing exception after WITH")
+		}
+		p.position++
+
+		expression.Exception = exception
+	}
+
+	return expression, nil
+}
+
+func isLicenseOperator(token string) bool {
+	for _, operator := range []string{"AND", "OR", "WITH"} {
+		if strings.EqualFold(token, operator) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,422 @@
+package nodemodulebom
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// LicenseSourceProperty marks components whose license was not declared by
+	// their package.json but detected from a license file, with the value
+	// "file", or set by a license override of the configuration, with the
+	// value "override".
+	LicenseSourceProperty = "paketo:node-module-bom:license:source"
+
+	// LicenseFileProperty records the file, relative to the package
+	// directory, that a detected license was identified from.
+	LicenseFileProperty = "paketo:node-module-bom:license:file"
+
+	// LicenseConfidenceProperty records how closely a license file matches
+	// the text of the detected license, between 0 and 1: the lower of the
+	// share of the license text that was found in the file and the share of
+	// the file that is license text.
+	LicenseConfidenceProperty = "paketo:node-module-bom:license:confidence"
+
+	// licenseThreshold is the share of the license text below which a license
+	// file is not considered to match a license.
+	licenseThreshold = 0.8
+
+	// licenseIDThreshold is the confidence below which a match is only
+	// recorded by name rather than asserted as an SPDX license ID.
+	licenseIDThreshold = 0.9
+
+	// maxLicenseFileSize caps how much of a license file is classified.
+	maxLicenseFileSize = 256 * 1024
+)
+
+// licenseTexts holds the full license texts that license files are classified
+// against, named after the SPDX license ID, or after the license of
+// ambiguousLicenses.
+//
+//go:embed licenses/*.txt
+var licenseTexts embed.FS
+
+// ambiguousLicenses names the licenses whose text cannot tell the SPDX
+// license ID: the GNU licenses only differ by the "only" or "or later" notice
+// that is placed outside of the license text.
+var ambiguousLicenses = map[string]string{
+	"GPL-2.0":  "GNU General Public License v2.0",
+	"GPL-3.0":  "GNU General Public License v3.0",
+	"LGPL-2.1": "GNU Lesser General Public License v2.1",
+	"LGPL-3.0": "GNU Lesser General Public License v3.0",
+}
+
+// LicenseMatch is a license identified from a license file of a package. Only
+// a confident match of an unambiguous license has an SPDX license ID, other
+// matches have a name.
+type LicenseMatch struct {
+	ID         string
+	Name       string
+	File       string
+	Confidence float64
+}
+
+// LicenseChoice returns the CycloneDX license of the match, recording the
+// file and confidence that it was detected with.
+func (m LicenseMatch) LicenseChoice() LicenseChoice {
+	return LicenseChoice{
+		License: &License{
+			ID:   m.ID,
+			Name: m.Name,
+			Properties: []Property{
+				{Name: LicenseFileProperty, Value: m.File},
+				{Name: LicenseConfidenceProperty, Value: strconv.FormatFloat(m.Confidence, 'f', 2, 64)},
+			},
+		},
+	}
+}
+
+type referenceLicense struct {
+	ID      string
+	Bigrams map[string]bool
+
+	// Optional holds the word pairs of the instructions that follow the terms
+	// of some licenses, such as the appendix of Apache-2.0, which license
+	// files may or may not include.
+	Optional map[string]bool
+}
+
+// licenseTermsEnd marks the end of the terms of the licenses that are followed
+// by instructions on how to apply them.
+const licenseTermsEnd = "END OF TERMS AND CONDITIONS"
+
+var (
+	referenceLicenses     []referenceLicense
+	referenceLicensesOnce sync.Once
+)
+
+func loadReferenceLicenses() []referenceLicense {
+	referenceLicensesOnce.Do(func() {
+		entries, err := licenseTexts.ReadDir("licenses")
+		if err != nil {
+			panic(err)
+		}
+
+		for _, entry := range entries {
+			content, err := licenseTexts.ReadFile(path.Join("licenses", entry.Name()))
+			if err != nil {
+				panic(err)
+			}
+
+			terms, instructions, _ := strings.Cut(string(content), licenseTermsEnd)
+			referenceLicenses = append(referenceLicenses, referenceLicense{
+				ID:       strings.TrimSuffix(entry.Name(), ".txt"),
+				Bigrams:  licenseBigrams(terms),
+				Optional: licenseBigrams(licenseTermsEnd + instructions),
+			})
+		}
+	})
+
+	return referenceLicenses
+}
+
+// ClassifyLicense identifies the license of the given license text by
+// comparing its word pairs with those of the full license texts. A license
+// matches when at least licenseThreshold of its text is found, and the
+// confidence of the match is the lower of that share and of the share of the
+// given text that belongs to the license, so that a README or NOTICE file
+// that quotes a license among other text is no confident match. The license
+// with the highest confidence wins. A match below licenseIDThreshold, or of
+// an ambiguous license, is named instead of identified, and a zero match is
+// returned when no license matches.
+func ClassifyLicense(text string) LicenseMatch {
+	bigrams := licenseBigrams(text)
+	if len(bigrams) == 0 {
+		return LicenseMatch{}
+	}
+
+	var (
+		best    string
+		matched int
+		score   float64
+	)
+	for _, reference := range loadReferenceLicenses() {
+		var count int
+		for bigram := range reference.Bigrams {
+			if bigrams[bigram] {
+				count++
+			}
+		}
+
+		recall := float64(count) / float64(len(reference.Bigrams))
+		if recall < licenseThreshold {
+			continue
+		}
+
+		// The optional instructions of the license do not count against
+		// the match when the text includes them.
+		size := len(bigrams)
+		for bigram := range reference.Optional {
+			if bigrams[bigram] && !reference.Bigrams[bigram] {
+				size--
+			}
+		}
+
+		confidence := math.Min(recall, float64(count)/float64(size))
+		if confidence > score || (confidence == score && count > matched) {
+			best, matched, score = reference.ID, count, confidence
+		}
+	}
+
+	if best == "" {
+		return LicenseMatch{}
+	}
+
+	name, ambiguous := ambiguousLicenses[best]
+	if !ambiguous {
+		name = best
+	}
+
+	if score < licenseIDThreshold {
+		return LicenseMatch{Name: fmt.Sprintf("%s (low confidence)", name), Confidence: score}
+	}
+
+	if ambiguous {
+		return LicenseMatch{Name: name, Confidence: score}
+	}
+
+	return LicenseMatch{ID: best, Confidence: score}
+}
+
+// copyrightLine matches the copyright statements of a lowercased license
+// text, but not wrapped lines that merely start with "copyright notice".
+var copyrightLine = regexp.MustCompile(`^[\s#*/=>-]*(copyright\b[\s:]*(\(c\)|©|\d|by\b)|\(c\)|©)`)
+
+// licenseBigrams normalizes a license text into the set of its consecutive
+// word pairs. Copyright lines, punctuation, case and the British spelling of
+// "licence" are ignored.
+func licenseBigrams(text string) map[string]bool {
+	var words []string
+	for _, line := range strings.Split(strings.ToLower(text), "\n") {
+		if copyrightLine.MatchString(line) {
+			continue
+		}
+
+		words = append(words, strings.FieldsFunc(line, func(r rune) bool {
+			return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+		})...)
+	}
+
+	bigrams := map[string]bool{}
+	for i := 1; i < len(words); i++ {
+		bigrams[normalizeLicenseWord(words[i-1])+" "+normalizeLicenseWord(words[i])] = true
+	}
+
+	return bigrams
+}
+
+func normalizeLicenseWord(word string) string {
+	if strings.HasPrefix(word, "licence") {
+		return "license" + strings.TrimPrefix(word, "licence")
+	}
+
+	return word
+}
+
+// DetectLicenseFiles classifies the LICENSE, LICENCE, COPYING and UNLICENSE
+// files (with any extension or suffix, such as LICENSE-MIT) at the top of the
+// given package directory, returning a match for every distinct license that
+// was identified. The error of the given context is returned when it is done
+// before every file is classified.
+func DetectLicenseFiles(ctx context.Context, dir string) ([]LicenseMatch, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to read package directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := strings.ToLower(entry.Name())
+		for _, prefix := range []string{"license", "licence", "copying", "unlicense"} {
+			if strings.HasPrefix(name, prefix) {
+				names = append(names, entry.Name())
+				break
+			}
+		}
+	}
+
+	return classifyLicenseFiles(ctx, dir, names)
+}
+
+func classifyLicenseFiles(ctx context.Context, dir string, names []string) ([]LicenseMatch, error) {
+	var matches []LicenseMatch
+	seen := map[string]bool{}
+	for _, name := range names {
+		err := ctx.Err()
+		if err != nil {
+			return nil, err
+		}
+
+		text, err := readLicenseFile(filepath.Join(dir, name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		match := ClassifyLicense(text)
+		if match.Confidence == 0 || seen[match.ID+match.Name] {
+			continue
+		}
+		seen[match.ID+match.Name] = true
+
+		match.File = filepath.ToSlash(name)
+		matches = append(matches, match)
+	}
+
+	return matches, nil
+}
+
+func readLicenseFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxLicenseFileSize))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return string(content), nil
+}
+
+// addFileLicenses detects the license of every component of the installed
+// tree whose package.json declares no license, or refers to a license file
+// with "SEE LICENSE IN <file>", from the license files of the package. It
+// returns the number of components that a license was detected for.
+func addFileLicenses(ctx context.Context, workingDir string, tree InstalledTree, components []Component) ([]Component, int, error) {
+	positions := map[string]int{}
+	for i, component := range components {
+		if component.BOMRef != "" {
+			positions[component.BOMRef] = i
+		}
+	}
+
+	var count int
+	for _, pkg := range tree.Packages {
+		position, ok := positions[pkg.Ref()]
+		if !ok {
+			continue
+		}
+		delete(positions, pkg.Ref())
+
+		component := components[position]
+
+		var file string
+		switch {
+		case len(component.Licenses) == 0:
+		case len(component.Licenses) == 1 && strings.HasPrefix(component.Licenses[0].value(), "SEE LICENSE IN "):
+			file = strings.TrimSpace(strings.TrimPrefix(component.Licenses[0].value(), "SEE LICENSE IN "))
+		default:
+			continue
+		}
+
+		dir := filepath.Join(workingDir, pkg.Path)
+
+		var matches []LicenseMatch
+		var err error
+		if file != "" {
+			file = filepath.Clean(filepath.FromSlash(file))
+			if file == ".." || strings.HasPrefix(file, ".."+string(filepath.Separator)) || filepath.IsAbs(file) {
+				continue
+			}
+
+			matches, err = classifyLicenseFiles(ctx, dir, []string{file})
+		} else {
+			matches, err = DetectLicenseFiles(ctx, dir)
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to detect license of %s: %w", pkg.Path, err)
+		}
+
+		if len(matches) == 0 {
+			continue
+		}
+
+		component.Licenses = nil
+		for _, match := range matches {
+			component.Licenses = append(component.Licenses, match.LicenseChoice())
+		}
+		component.Properties = append(component.Properties, Property{Name: LicenseSourceProperty, Value: "file"})
+
+		components[position] = component
+		count++
+	}
+
+	return components, count, nil
+}
+
+// OverrideLicenses returns the BOM with the licenses of the components that
+// the overrides name replaced, along with the number of components that were
+// overridden. The overrides map a package name, or a package name and version
+// such as "leftpad@1.0.0", which takes precedence, to an SPDX license ID or
+// expression.
+func OverrideLicenses(bom BOM, overrides map[string]string) (BOM, int) {
+	if len(overrides) == 0 {
+		return bom, 0
+	}
+
+	var count int
+	components := make([]Component, 0, len(bom.Components))
+	for _, component := range bom.Components {
+		name := component.PackageName()
+		license, ok := overrides[name+"@"+component.Version]
+		if !ok {
+			license, ok = overrides[name]
+		}
+
+		if !ok || component.Type == "file" {
+			components = append(components, component)
+			continue
+		}
+
+		component.Licenses = licenseChoices(license)
+
+		var properties []Property
+		for _, property := range component.Properties {
+			if property.Name != LicenseSourceProperty {
+				properties = append(properties, property)
+			}
+		}
+		component.Properties = append(properties, Property{Name: LicenseSourceProperty, Value: "override"})
+
+		components = append(components, component)
+		count++
+	}
+	bom.Components = components
+
+	return bom, count
+}
@@ -0,0 +1,188 @@
+package nodemodulebom_test
+
+import (
+	gocontext "context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLicenses(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		reference = func(id string) string {
+			content, err := os.ReadFile(filepath.Join("licenses", id+".txt"))
+			Expect(err).NotTo(HaveOccurred())
+
+			return string(content)
+		}
+	)
+
+	context("ClassifyLicense", func() {
+		it("identifies a license regardless of its title, copyright lines and formatting", func() {
+			match := nodemodulebom.ClassifyLicense("The MIT License (MIT)\n\nCopyright (c) 2015 Some Author <author@example.com>\n\n" + reference("MIT"))
+			Expect(match.ID).To(Equal("MIT"))
+			Expect(match.Name).To(BeEmpty())
+			Expect(match.Confidence).To(BeNumerically(">=", 0.95))
+		})
+
+		it("prefers the license that matches the text most closely", func() {
+			Expect(nodemodulebom.ClassifyLicense(reference("BSD-3-Clause")).ID).To(Equal("BSD-3-Clause"))
+			Expect(nodemodulebom.ClassifyLicense(reference("BSD-2-Clause")).ID).To(Equal("BSD-2-Clause"))
+			Expect(nodemodulebom.ClassifyLicense(reference("ISC")).ID).To(Equal("ISC"))
+			Expect(nodemodulebom.ClassifyLicense(reference("0BSD")).ID).To(Equal("0BSD"))
+		})
+
+		it("identifies long licenses from their full text", func() {
+			apache := reference("Apache-2.0")
+			match := nodemodulebom.ClassifyLicense(apache[:strings.Index(apache, "APPENDIX")])
+			Expect(match.ID).To(Equal("Apache-2.0"))
+			Expect(match.Confidence).To(BeNumerically(">=", 0.9))
+
+			Expect(nodemodulebom.ClassifyLicense(reference("MPL-2.0")).ID).To(Equal("MPL-2.0"))
+		})
+
+		it("names the GNU licenses, whose text cannot tell whether later versions apply", func() {
+			match := nodemodulebom.ClassifyLicense(reference("GPL-3.0"))
+			Expect(match.ID).To(BeEmpty())
+			Expect(match.Name).To(Equal("GNU General Public License v3.0"))
+
+			match = nodemodulebom.ClassifyLicense(reference("LGPL-2.1"))
+			Expect(match.ID).To(BeEmpty())
+			Expect(match.Name).To(Equal("GNU Lesser General Public License v2.1"))
+		})
+
+		it("names a low-confidence match instead of identifying it", func() {
+			text := reference("MIT")
+			match := nodemodulebom.ClassifyLicense(text[:len(text)*17/20])
+			Expect(match.ID).To(BeEmpty())
+			Expect(match.Name).To(Equal("MIT (low confidence)"))
+			Expect(match.Confidence).To(BeNumerically("<", 0.9))
+			Expect(match.Confidence).To(BeNumerically(">=", 0.8))
+		})
+
+		it("does not identify a license that is quoted among other text", func() {
+			notice := "Third-party notices\n\n" + reference("MIT") + "\n\n" + reference("ISC") + "\n\n" + reference("BSD-3-Clause")
+			Expect(nodemodulebom.ClassifyLicense(notice).ID).To(BeEmpty())
+
+			readme := "# leftpad\n\nPermission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the \"Software\"), to deal in the Software without restriction"
+			Expect(nodemodulebom.ClassifyLicense(readme)).To(BeZero())
+		})
+
+		it("returns no license for text that matches none", func() {
+			Expect(nodemodulebom.ClassifyLicense("All rights reserved. Do not copy.")).To(BeZero())
+		})
+	})
+
+	context("DetectLicenseFiles", func() {
+		var dir string
+
+		it.Before(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "package")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		it("classifies every license file of the package", func() {
+			Expect(os.WriteFile(filepath.Join(dir, "LICENSE-BSD"), []byte(reference("BSD-3-Clause")), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "licence-mit.txt"), []byte(reference("MIT")), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "LICENSE"), []byte(reference("MIT")), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "COPYING"), []byte("Not a license."), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "README.md"), []byte(reference("ISC")), 0600)).To(Succeed())
+
+			matches, err := nodemodulebom.DetectLicenseFiles(gocontext.Background(), dir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matches).To(Equal([]nodemodulebom.LicenseMatch{
+				{ID: "MIT", File: "LICENSE", Confidence: 1},
+				{ID: "BSD-3-Clause", File: "LICENSE-BSD", Confidence: 1},
+			}))
+		})
+
+		it("returns nothing when the directory does not exist", func() {
+			matches, err := nodemodulebom.DetectLicenseFiles(gocontext.Background(), filepath.Join(dir, "missing"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matches).To(BeEmpty())
+		})
+	})
+
+	context("LicenseMatch", func() {
+		context("LicenseChoice", func() {
+			it("records the file and confidence of the match", func() {
+				match := nodemodulebom.LicenseMatch{ID: "MIT", File: "LICENSE", Confidence: 0.926}
+
+				Expect(match.LicenseChoice()).To(Equal(nodemodulebom.LicenseChoice{
+					License: &nodemodulebom.License{
+						ID: "MIT",
+						Properties: []nodemodulebom.Property{
+							{Name: "paketo:node-module-bom:license:file", Value: "LICENSE"},
+							{Name: "paketo:node-module-bom:license:confidence", Value: "0.93"},
+						},
+					},
+				}))
+			})
+
+			it("records a named match by its name", func() {
+				match := nodemodulebom.LicenseMatch{Name: "GNU General Public License v3.0", File: "COPYING", Confidence: 1}
+
+				Expect(match.LicenseChoice()).To(Equal(nodemodulebom.LicenseChoice{
+					License: &nodemodulebom.License{
+						Name: "GNU General Public License v3.0",
+						Properties: []nodemodulebom.Property{
+							{Name: "paketo:node-module-bom:license:file", Value: "COPYING"},
+							{Name: "paketo:node-module-bom:license:confidence", Value: "1.00"},
+						},
+					},
+				}))
+			})
+		})
+	})
+
+	context("OverrideLicenses", func() {
+		it("replaces the licenses of the named components, preferring a version match", func() {
+			bom := nodemodulebom.BOM{
+				Components: []nodemodulebom.Component{
+					{
+						Type: "library", Name: "leftpad", Version: "1.0.0",
+						Licenses:   []nodemodulebom.LicenseChoice{{License: &nodemodulebom.License{Name: "SEE LICENSE IN LICENSE.txt"}}},
+						Properties: []nodemodulebom.Property{{Name: "paketo:node-module-bom:license:source", Value: "file"}},
+					},
+					{Type: "library", Group: "@acme", Name: "fonts", Version: "1.0.0"},
+					{Type: "library", Name: "rightpad", Version: "1.0.0", Licenses: []nodemodulebom.LicenseChoice{{License: &nodemodulebom.License{ID: "ISC"}}}},
+				},
+			}
+
+			overridden, count := nodemodulebom.OverrideLicenses(bom, map[string]string{
+				"leftpad":           "MIT",
+				"@acme/fonts":       "Apache-2.0",
+				"@acme/fonts@1.0.0": "OFL-1.1 OR MIT",
+			})
+			Expect(count).To(Equal(2))
+			Expect(overridden.Components).To(Equal([]nodemodulebom.Component{
+				{
+					Type: "library", Name: "leftpad", Version: "1.0.0",
+					Licenses:   []nodemodulebom.LicenseChoice{{License: &nodemodulebom.License{ID: "MIT"}}},
+					Properties: []nodemodulebom.Property{{Name: "paketo:node-module-bom:license:source", Value: "override"}},
+				},
+				{
+					Type: "library", Group: "@acme", Name: "fonts", Version: "1.0.0",
+					Licenses:   []nodemodulebom.LicenseChoice{{Expression: "OFL-1.1 OR MIT"}},
+					Properties: []nodemodulebom.Property{{Name: "paketo:node-module-bom:license:source", Value: "override"}},
+				},
+				{Type: "library", Name: "rightpad", Version: "1.0.0", Licenses: []nodemodulebom.LicenseChoice{{License: &nodemodulebom.License{ID: "ISC"}}}},
+			}))
+
+			Expect(bom.Components[0].Licenses[0].License.Name).To(Equal("SEE LICENSE IN LICENSE.txt"))
+		})
+	})
+}
@@ -0,0 +1,58 @@
+package nodemodulebom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// ShrinkwrapLockfile is the publishable lockfile that npm honors over
+	// package-lock.json when both are present.
+	ShrinkwrapLockfile = "npm-shrinkwrap.json"
+
+	// PackageLockLockfile is the lockfile npm writes by default.
+	PackageLockLockfile = "package-lock.json"
+
+	// BunLockfile is the text lockfile written by Bun.
+	BunLockfile = "bun.lock"
+)
+
+// Lockfile is the lockfile that describes the dependencies of an application.
+type Lockfile struct {
+	// Path is the path of the lockfile relative to the application directory.
+	Path string
+
+	// SHA256 is the hex-encoded SHA-256 digest of the lockfile contents.
+	SHA256 string
+}
+
+// FindLockfile returns the lockfile that the package manager would use for
+// the application in the given working directory, following npm's precedence
+// of npm-shrinkwrap.json over package-lock.json, and falling back to the
+// bun.lock of Bun. An empty Lockfile is returned when the application has no
+// lockfile.
+func FindLockfile(workingDir string) (Lockfile, error) {
+	for _, path := range []string{ShrinkwrapLockfile, PackageLockLockfile, BunLockfile} {
+		content, err := os.ReadFile(filepath.Join(workingDir, path))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return Lockfile{}, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		sum := sha256.Sum256(content)
+
+		return Lockfile{
+			Path:   path,
+			SHA256: hex.EncodeToString(sum[:]),
+		}, nil
+	}
+
+	return Lockfile{}, nil
+}
@@ -0,0 +1,96 @@
+package nodemodulebom_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testLockfile(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), []byte(`{}`), 0600)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("FindLockfile", func() {
+		it("returns the package-lock.json", func() {
+			lockfile, err := nodemodulebom.FindLockfile(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lockfile).To(Equal(nodemodulebom.Lockfile{
+				Path:   "package-lock.json",
+				SHA256: "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a",
+			}))
+		})
+
+		context("when there is an npm-shrinkwrap.json", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "npm-shrinkwrap.json"), []byte(`{"lockfileVersion": 3}`), 0600)).To(Succeed())
+			})
+
+			it("prefers the npm-shrinkwrap.json", func() {
+				lockfile, err := nodemodulebom.FindLockfile(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lockfile).To(Equal(nodemodulebom.Lockfile{
+					Path:   "npm-shrinkwrap.json",
+					SHA256: "da4f67e19cdb13d541f3ce247dd8c1efc6601d9a2ad470faf2cfa35ba876fa75",
+				}))
+			})
+		})
+
+		context("when there is only a bun.lock", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(workingDir, "package-lock.json"))).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "bun.lock"), []byte(`{}`), 0600)).To(Succeed())
+			})
+
+			it("returns the bun.lock", func() {
+				lockfile, err := nodemodulebom.FindLockfile(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lockfile.Path).To(Equal("bun.lock"))
+			})
+		})
+
+		context("when there is no lockfile", func() {
+			it.Before(func() {
+				Expect(os.Remove(filepath.Join(workingDir, "package-lock.json"))).To(Succeed())
+			})
+
+			it("returns an empty lockfile", func() {
+				lockfile, err := nodemodulebom.FindLockfile(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lockfile).To(Equal(nodemodulebom.Lockfile{}))
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the lockfile cannot be read", func() {
+				it.Before(func() {
+					Expect(os.Chmod(filepath.Join(workingDir, "package-lock.json"), 0000)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := nodemodulebom.FindLockfile(workingDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to read package-lock.json")))
+				})
+			})
+		})
+	})
+}
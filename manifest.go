@@ -0,0 +1,331 @@
+package nodemodulebom
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	// ManifestFile is the manifest of the components of the application that
+	// the generator cannot discover, such as scripts and fonts that were
+	// copied into the application by hand.
+	ManifestFile = ".node-module-bom-components.toml"
+
+	// DeclaredProperty marks components that were declared in a manifest
+	// rather than discovered, with the path of the manifest as its value.
+	DeclaredProperty = "paketo:node-module-bom:declared"
+)
+
+// componentTypes lists the CycloneDX component types that a declared component
+// can have.
+var componentTypes = []string{"application", "framework", "library", "container", "platform", "operating-system", "device", "device-driver", "firmware", "file", "machine-learning-model", "data"}
+
+// Manifest declares components of the application that are not installed by
+// the package manager.
+type Manifest struct {
+	// Path is the path of the manifest relative to the application.
+	Path string `toml:"-"`
+
+	Components []DeclaredComponent `toml:"components"`
+}
+
+// DeclaredComponent is a component of a Manifest.
+type DeclaredComponent struct {
+	Name    string `toml:"name"`
+	Version string `toml:"version"`
+
+	// Type is the CycloneDX type of the component, "library" by default.
+	Type string `toml:"type"`
+
+	// PURL is the package URL of the component. It defaults to a generic
+	// package URL of its name and version.
+	PURL string `toml:"purl"`
+
+	// License is an SPDX license ID or expression.
+	License string `toml:"license"`
+
+	Files []DeclaredFile `toml:"files"`
+}
+
+// DeclaredFile is a file of a DeclaredComponent, with the hex-encoded digests
+// that it must match.
+type DeclaredFile struct {
+	// Path is the path of the file relative to the application.
+	Path   string `toml:"path"`
+	SHA256 string `toml:"sha256"`
+	SHA512 string `toml:"sha512"`
+}
+
+// LoadManifest reads the Manifest at the given path relative to the working
+// directory. It returns false when the manifest does not exist. Unknown keys
+// and invalid values are reported along with the component they were found
+// at.
+func LoadManifest(workingDir, path string) (Manifest, bool, error) {
+	var manifest Manifest
+	metadata, err := toml.DecodeFile(filepath.Join(workingDir, filepath.FromSlash(path)), &manifest)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Manifest{}, false, nil
+		}
+
+		return Manifest{}, false, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+		return Manifest{}, false, fmt.Errorf("failed to parse %s: unknown key %q", path, undecoded[0].String())
+	}
+
+	manifest.Path = path
+	refs := map[string]int{}
+	for i, component := range manifest.Components {
+		key := fmt.Sprintf("%s components[%d]", path, i)
+
+		if strings.TrimSpace(component.Name) == "" {
+			return Manifest{}, false, fmt.Errorf("failed to parse %s: name must not be empty", key)
+		}
+
+		if component.Type != "" && !containsString(componentTypes, component.Type) {
+			return Manifest{}, false, fmt.Errorf("failed to parse %s key type: unknown component type %q", key, component.Type)
+		}
+
+		if component.PURL != "" && !strings.HasPrefix(component.PURL, "pkg:") {
+			return Manifest{}, false, fmt.Errorf("failed to parse %s key purl: %q is not a package URL", key, component.PURL)
+		}
+
+		ref := component.component(path).BOMRef
+		if previous, ok := refs[ref]; ok {
+			return Manifest{}, false, fmt.Errorf("failed to parse %s: bom-ref %q is already declared by components[%d]", key, ref, previous)
+		}
+		refs[ref] = i
+
+		if len(component.Files) == 0 {
+			return Manifest{}, false, fmt.Errorf("failed to parse %s key files: at least one file must be declared", key)
+		}
+
+		for j, file := range component.Files {
+			fileKey := fmt.Sprintf("%s key files[%d]", key, j)
+
+			clean := filepath.Clean(filepath.FromSlash(file.Path))
+			if file.Path == "" || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) || filepath.IsAbs(clean) {
+				return Manifest{}, false, fmt.Errorf("failed to parse %s.path: %q must be a path inside of the application", fileKey, file.Path)
+			}
+			manifest.Components[i].Files[j].Path = filepath.ToSlash(clean)
+
+			for _, digest := range []struct {
+				name  string
+				value string
+				size  int
+			}{
+				{"sha256", file.SHA256, sha256.Size},
+				{"sha512", file.SHA512, sha512.Size},
+			} {
+				if digest.value == "" {
+					continue
+				}
+
+				decoded, err := hex.DecodeString(digest.value)
+				if err != nil || len(decoded) != digest.size {
+					return Manifest{}, false, fmt.Errorf("failed to parse %s.%s: expected %d hex-encoded bytes", fileKey, digest.name, digest.size)
+				}
+			}
+		}
+	}
+
+	return manifest, true, nil
+}
+
+// Verify checks that the declared files exist in the working directory and
+// that they match their declared digests, and returns the CycloneDX
+// components of the manifest. The files are recorded as the evidence
+// occurrences of their component, and the component of a single file carries
+// its digests.
+func (m Manifest) Verify(workingDir string) ([]Component, error) {
+	var components []Component
+	for i, declared := range m.Components {
+		name := declared.Name
+		if declared.Version != "" {
+			name += "@" + declared.Version
+		}
+		key := fmt.Sprintf("%s components[%d] (%s)", m.Path, i, name)
+
+		component := declared.component(m.Path)
+		for _, file := range declared.Files {
+			sums, err := fileDigests(workingDir, file.Path)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil, fmt.Errorf("failed to verify %s: file %s does not exist", key, file.Path)
+				}
+
+				return nil, fmt.Errorf("failed to verify %s: %w", key, err)
+			}
+
+			for _, digest := range []struct {
+				algorithm string
+				expected  string
+				actual    string
+			}{
+				{"SHA-256", file.SHA256, sums[0]},
+				{"SHA-512", file.SHA512, sums[1]},
+			} {
+				if digest.expected != "" && !strings.EqualFold(digest.expected, digest.actual) {
+					return nil, fmt.Errorf("failed to verify %s: %s of file %s is %s, expected %s", key, digest.algorithm, file.Path, digest.actual, strings.ToLower(digest.expected))
+				}
+			}
+
+			if len(declared.Files) == 1 {
+				component.Hashes = []Hash{{Algorithm: "SHA-256", Content: sums[0]}}
+				if file.SHA512 != "" {
+					component.Hashes = append(component.Hashes, Hash{Algorithm: "SHA-512", Content: sums[1]})
+				}
+			}
+
+			component.Evidence.Occurrences = append(component.Evidence.Occurrences, Occurrence{Location: file.Path})
+		}
+
+		components = append(components, component)
+	}
+
+	return components, nil
+}
+
+// AddDeclaredComponents returns the BOM with the declared components added to
+// its components and, as direct dependencies of the application, to its
+// dependency graph. Declared components whose bom-ref is already part of the
+// BOM are left out and returned.
+func AddDeclaredComponents(bom BOM, components []Component) (BOM, []Component) {
+	existing := map[string]bool{}
+	for _, component := range bom.Components {
+		existing[component.BOMRef] = true
+	}
+
+	var (
+		added   []string
+		skipped []Component
+	)
+	merged := append([]Component{}, bom.Components...)
+	for _, component := range components {
+		if existing[component.BOMRef] {
+			skipped = append(skipped, component)
+			continue
+		}
+		existing[component.BOMRef] = true
+
+		merged = append(merged, component)
+		added = append(added, component.BOMRef)
+	}
+	bom.Components = merged
+
+	root := bom.Metadata.Component
+	if root == nil || root.BOMRef == "" || len(added) == 0 {
+		return bom, skipped
+	}
+
+	dependencies := append([]Dependency{}, bom.Dependencies...)
+	found := false
+	for i, dependency := range dependencies {
+		if dependency.Ref == root.BOMRef {
+			dependencies[i].DependsOn = append(append([]string{}, dependency.DependsOn...), added...)
+			found = true
+		}
+	}
+
+	if !found {
+		dependencies = append(dependencies, Dependency{Ref: root.BOMRef, DependsOn: added})
+	}
+
+	for _, ref := range added {
+		dependencies = append(dependencies, Dependency{Ref: ref})
+	}
+	bom.Dependencies = dependencies
+
+	return bom, skipped
+}
+
+// component returns the CycloneDX component of the declared component,
+// without its files.
+func (c DeclaredComponent) component(manifest string) Component {
+	purl := c.PURL
+	if purl == "" {
+		purl = "pkg:generic/" + escapePURL(c.Name, "")
+		if c.Version != "" {
+			purl += "@" + escapePURL(c.Version, "")
+		}
+	}
+
+	componentType := c.Type
+	if componentType == "" {
+		componentType = "library"
+	}
+
+	return Component{
+		BOMRef:     purl,
+		Type:       componentType,
+		Name:       c.Name,
+		Version:    c.Version,
+		PURL:       purl,
+		Licenses:   licenseChoices(c.License),
+		Properties: []Property{{Name: DeclaredProperty, Value: manifest}},
+		Evidence:   &Evidence{},
+	}
+}
+
+// fileDigests returns the hex-encoded SHA-256 and SHA-512 digests of the file
+// at the given path relative to the working directory. Symbolic links are
+// only followed while they stay inside of the working directory, so that a
+// manifest cannot vouch for files of the build environment.
+func fileDigests(workingDir, rel string) ([2]string, error) {
+	path := filepath.Join(workingDir, filepath.FromSlash(rel))
+	_, err := os.Lstat(path)
+	if err != nil {
+		return [2]string{}, err
+	}
+
+	// The directories of the path can be symbolic links as well as the file.
+	root, err := filepath.EvalSymlinks(workingDir)
+	if err != nil {
+		return [2]string{}, err
+	}
+
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return [2]string{}, err
+	}
+
+	inside, err := filepath.Rel(root, target)
+	if err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return [2]string{}, fmt.Errorf("file %s is a symbolic link to %s outside of the application", rel, target)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return [2]string{}, err
+	}
+
+	if !info.Mode().IsRegular() {
+		return [2]string{}, fmt.Errorf("file %s is not a regular file", rel)
+	}
+
+	file, err := os.Open(target)
+	if err != nil {
+		return [2]string{}, err
+	}
+	defer file.Close()
+
+	hashes := []hash.Hash{sha256.New(), sha512.New()}
+	_, err = io.Copy(io.MultiWriter(hashes[0], hashes[1]), file)
+	if err != nil {
+		return [2]string{}, err
+	}
+
+	return [2]string{hex.EncodeToString(hashes[0].Sum(nil)), hex.EncodeToString(hashes[1].Sum(nil))}, nil
+}
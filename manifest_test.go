@@ -0,0 +1,281 @@
+package nodemodulebom_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testManifest(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+		jquerySHA  string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(workingDir, "public", "vendor", "fonts"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, "public", "vendor", "jquery.min.js"), []byte("/*! jQuery v3.7.1 */"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, "public", "vendor", "fonts", "Inter.woff2"), []byte("wOF2"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, "public", "vendor", "fonts", "Inter-Bold.woff2"), []byte("wOF2 bold"), 0600)).To(Succeed())
+
+		sum := sha256.Sum256([]byte("/*! jQuery v3.7.1 */"))
+		jquerySHA = hex.EncodeToString(sum[:])
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("LoadManifest", func() {
+		it("returns false when the manifest does not exist", func() {
+			_, ok, err := nodemodulebom.LoadManifest(workingDir, ".node-module-bom-components.toml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		context("failure cases", func() {
+			it("returns an error for an unknown key", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom-components.toml"), []byte(`
+[[components]]
+name = "jquery"
+licence = "MIT"
+`), 0600)).To(Succeed())
+
+				_, _, err := nodemodulebom.LoadManifest(workingDir, ".node-module-bom-components.toml")
+				Expect(err).To(MatchError(`failed to parse .node-module-bom-components.toml: unknown key "components.licence"`))
+			})
+
+			it("returns an error for a component without files", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom-components.toml"), []byte(`
+[[components]]
+name = "jquery"
+`), 0600)).To(Succeed())
+
+				_, _, err := nodemodulebom.LoadManifest(workingDir, ".node-module-bom-components.toml")
+				Expect(err).To(MatchError("failed to parse .node-module-bom-components.toml components[0] key files: at least one file must be declared"))
+			})
+
+			it("returns an error for a file outside of the application", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom-components.toml"), []byte(`
+[[components]]
+name = "jquery"
+files = [{ path = "../jquery.min.js" }]
+`), 0600)).To(Succeed())
+
+				_, _, err := nodemodulebom.LoadManifest(workingDir, ".node-module-bom-components.toml")
+				Expect(err).To(MatchError(`failed to parse .node-module-bom-components.toml components[0] key files[0].path: "../jquery.min.js" must be a path inside of the application`))
+			})
+
+			it("returns an error for a malformed digest", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom-components.toml"), []byte(`
+[[components]]
+name = "jquery"
+files = [{ path = "public/vendor/jquery.min.js", sha256 = "abc" }]
+`), 0600)).To(Succeed())
+
+				_, _, err := nodemodulebom.LoadManifest(workingDir, ".node-module-bom-components.toml")
+				Expect(err).To(MatchError("failed to parse .node-module-bom-components.toml components[0] key files[0].sha256: expected 32 hex-encoded bytes"))
+			})
+
+			it("returns an error for a component whose bom-ref is already declared", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom-components.toml"), []byte(`
+[[components]]
+name = "jquery"
+version = "3.7.1"
+files = [{ path = "public/vendor/jquery.min.js" }]
+
+[[components]]
+name = "jquery-copy"
+purl = "pkg:generic/jquery@3.7.1"
+files = [{ path = "public/vendor/jquery.js" }]
+`), 0600)).To(Succeed())
+
+				_, _, err := nodemodulebom.LoadManifest(workingDir, ".node-module-bom-components.toml")
+				Expect(err).To(MatchError(`failed to parse .node-module-bom-components.toml components[1]: bom-ref "pkg:generic/jquery@3.7.1" is already declared by components[0]`))
+			})
+
+			it("returns an error for an unknown component type", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom-components.toml"), []byte(`
+[[components]]
+name = "inter"
+type = "font"
+files = [{ path = "public/vendor/fonts/Inter.woff2" }]
+`), 0600)).To(Succeed())
+
+				_, _, err := nodemodulebom.LoadManifest(workingDir, ".node-module-bom-components.toml")
+				Expect(err).To(MatchError(`failed to parse .node-module-bom-components.toml components[0] key type: unknown component type "font"`))
+			})
+		})
+	})
+
+	context("Verify", func() {
+		it("returns the components with their files and digests", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, ".node-module-bom-components.toml"), []byte(fmt.Sprintf(`
+[[components]]
+name = "jquery"
+version = "3.7.1"
+purl = "pkg:npm/jquery@3.7.1"
+license = "MIT"
+
+  [[components.files]]
+  path = "./public/vendor/jquery.min.js"
+  sha256 = "%s"
+
+[[components]]
+name = "Inter"
+version = "4.0"
+license = "OFL-1.1"
+files = [
+  { path = "public/vendor/fonts/Inter.woff2" },
+  { path = "public/vendor/fonts/Inter-Bold.woff2" },
+]
+`, jquerySHA)), 0600)).To(Succeed())
+
+			manifest, ok, err := nodemodulebom.LoadManifest(workingDir, ".node-module-bom-components.toml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+
+			components, err := manifest.Verify(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(components).To(Equal([]nodemodulebom.Component{
+				{
+					BOMRef:     "pkg:npm/jquery@3.7.1",
+					Type:       "library",
+					Name:       "jquery",
+					Version:    "3.7.1",
+					PURL:       "pkg:npm/jquery@3.7.1",
+					Hashes:     []nodemodulebom.Hash{{Algorithm: "SHA-256", Content: jquerySHA}},
+					Licenses:   []nodemodulebom.LicenseChoice{{License: &nodemodulebom.License{ID: "MIT"}}},
+					Properties: []nodemodulebom.Property{{Name: "paketo:node-module-bom:declared", Value: ".node-module-bom-components.toml"}},
+					Evidence:   &nodemodulebom.Evidence{Occurrences: []nodemodulebom.Occurrence{{Location: "public/vendor/jquery.min.js"}}},
+				},
+				{
+					BOMRef:     "pkg:generic/Inter@4.0",
+					Type:       "library",
+					Name:       "Inter",
+					Version:    "4.0",
+					PURL:       "pkg:generic/Inter@4.0",
+					Licenses:   []nodemodulebom.LicenseChoice{{License: &nodemodulebom.License{ID: "OFL-1.1"}}},
+					Properties: []nodemodulebom.Property{{Name: "paketo:node-module-bom:declared", Value: ".node-module-bom-components.toml"}},
+					Evidence: &nodemodulebom.Evidence{Occurrences: []nodemodulebom.Occurrence{
+						{Location: "public/vendor/fonts/Inter.woff2"},
+						{Location: "public/vendor/fonts/Inter-Bold.woff2"},
+					}},
+				},
+			}))
+		})
+
+		it("follows symbolic links inside of the application", func() {
+			Expect(os.Symlink("jquery.min.js", filepath.Join(workingDir, "public", "vendor", "jquery.js"))).To(Succeed())
+
+			manifest := nodemodulebom.Manifest{
+				Path: "components.toml",
+				Components: []nodemodulebom.DeclaredComponent{
+					{Name: "jquery", Files: []nodemodulebom.DeclaredFile{{Path: "public/vendor/jquery.js", SHA256: jquerySHA}}},
+				},
+			}
+
+			components, err := manifest.Verify(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(components[0].Hashes).To(Equal([]nodemodulebom.Hash{{Algorithm: "SHA-256", Content: jquerySHA}}))
+		})
+
+		context("failure cases", func() {
+			it("returns an error when a declared file does not exist", func() {
+				manifest := nodemodulebom.Manifest{
+					Path: "components.toml",
+					Components: []nodemodulebom.DeclaredComponent{
+						{Name: "jquery", Version: "3.7.1", Files: []nodemodulebom.DeclaredFile{{Path: "public/vendor/jquery.js"}}},
+					},
+				}
+
+				_, err := manifest.Verify(workingDir)
+				Expect(err).To(MatchError("failed to verify components.toml components[0] (jquery@3.7.1): file public/vendor/jquery.js does not exist"))
+			})
+
+			it("returns an error when a declared file is a symbolic link that leaves the application", func() {
+				outside, err := os.MkdirTemp("", "outside")
+				Expect(err).NotTo(HaveOccurred())
+				defer os.RemoveAll(outside)
+
+				Expect(os.WriteFile(filepath.Join(outside, "passwd"), []byte("root"), 0600)).To(Succeed())
+				Expect(os.Symlink(filepath.Join(outside, "passwd"), filepath.Join(workingDir, "public", "vendor", "passwd.js"))).To(Succeed())
+
+				manifest := nodemodulebom.Manifest{
+					Path: "components.toml",
+					Components: []nodemodulebom.DeclaredComponent{
+						{Name: "passwd", Files: []nodemodulebom.DeclaredFile{{Path: "public/vendor/passwd.js"}}},
+					},
+				}
+
+				_, err = manifest.Verify(workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to verify components.toml components[0] (passwd): file public/vendor/passwd.js is a symbolic link to ")))
+				Expect(err).To(MatchError(ContainSubstring("outside of the application")))
+			})
+
+			it("returns an error when the digest of a declared file does not match", func() {
+				manifest := nodemodulebom.Manifest{
+					Path: "components.toml",
+					Components: []nodemodulebom.DeclaredComponent{
+						{Name: "jquery", Files: []nodemodulebom.DeclaredFile{{Path: "public/vendor/fonts/Inter.woff2", SHA256: jquerySHA}}},
+					},
+				}
+
+				_, err := manifest.Verify(workingDir)
+				sum := sha256.Sum256([]byte("wOF2"))
+				Expect(err).To(MatchError(fmt.Sprintf("failed to verify components.toml components[0] (jquery): SHA-256 of file public/vendor/fonts/Inter.woff2 is %s, expected %s", hex.EncodeToString(sum[:]), jquerySHA)))
+			})
+		})
+	})
+
+	context("AddDeclaredComponents", func() {
+		it("adds the components as direct dependencies of the application, unless they are already part of the BOM", func() {
+			bom := nodemodulebom.BOM{
+				Metadata: nodemodulebom.Metadata{
+					Component: &nodemodulebom.Component{BOMRef: "pkg:npm/some-app@1.0.0", Type: "application", Name: "some-app"},
+				},
+				Components: []nodemodulebom.Component{
+					{BOMRef: "pkg:npm/leftpad@1.0.0", Type: "library", Name: "leftpad", Version: "1.0.0"},
+				},
+				Dependencies: []nodemodulebom.Dependency{
+					{Ref: "pkg:npm/some-app@1.0.0", DependsOn: []string{"pkg:npm/leftpad@1.0.0"}},
+					{Ref: "pkg:npm/leftpad@1.0.0"},
+				},
+			}
+
+			merged, skipped := nodemodulebom.AddDeclaredComponents(bom, []nodemodulebom.Component{
+				{BOMRef: "pkg:npm/jquery@3.7.1", Type: "library", Name: "jquery", Version: "3.7.1"},
+				{BOMRef: "pkg:npm/leftpad@1.0.0", Type: "library", Name: "leftpad", Version: "1.0.0"},
+			})
+
+			Expect(skipped).To(Equal([]nodemodulebom.Component{
+				{BOMRef: "pkg:npm/leftpad@1.0.0", Type: "library", Name: "leftpad", Version: "1.0.0"},
+			}))
+			Expect(merged.Components).To(Equal([]nodemodulebom.Component{
+				{BOMRef: "pkg:npm/leftpad@1.0.0", Type: "library", Name: "leftpad", Version: "1.0.0"},
+				{BOMRef: "pkg:npm/jquery@3.7.1", Type: "library", Name: "jquery", Version: "3.7.1"},
+			}))
+			Expect(merged.Dependencies).To(Equal([]nodemodulebom.Dependency{
+				{Ref: "pkg:npm/some-app@1.0.0", DependsOn: []string{"pkg:npm/leftpad@1.0.0", "pkg:npm/jquery@3.7.1"}},
+				{Ref: "pkg:npm/leftpad@1.0.0"},
+				{Ref: "pkg:npm/jquery@3.7.1"},
+			}))
+
+			Expect(bom.Dependencies[0].DependsOn).To(HaveLen(1))
+		})
+	})
+}
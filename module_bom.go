@@ -2,23 +2,22 @@ package nodemodulebom
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
-	"github.com/paketo-buildpacks/packit/v2"
-
-	//nolint Ignore SA1019, informed usage of deprecated package
-	"github.com/paketo-buildpacks/packit/v2/paketosbom"
 	"github.com/paketo-buildpacks/packit/v2/pexec"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
 )
 
 //go:generate faux --interface Executable --output fakes/executable.go
 type Executable interface {
-	Execute(execution pexec.Execution) error
+	Execute(ctx context.Context, execution pexec.Execution) error
 }
 
 type ModuleBOM struct {
@@ -33,88 +32,359 @@ func NewModuleBOM(executable Executable, logger scribe.Emitter) ModuleBOM {
 	}
 }
 
-func (m ModuleBOM) Generate(workingDir string) ([]packit.BOMEntry, error) {
+// Generate runs cyclonedx-bom, from the given bin directory, on the Node
+// project in the given working directory and completes the BOM it writes with
+// what the buildpack knows about the installed packages. The generator writes
+// into a private temporary directory, never into the application, and runs
+// with the minimal environment of generatorEnv, and is stopped when the given
+// context is done. The scans of node_modules that complete the BOM stop as
+// well, in which case the BOM that was built before the context was done is
+// returned along with the error of the context.
+func (m ModuleBOM) Generate(ctx context.Context, workingDir, binDir string) (BOM, error) {
+	outputDir, err := os.MkdirTemp("", "node-module-bom")
+	if err != nil {
+		return BOM{}, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	output := filepath.Join(outputDir, "bom.json")
 
 	buffer := bytes.NewBuffer(nil)
-	args := []string{"-o", "bom.json"}
+	args := []string{"-o", output}
 	m.logger.Subprocess("Running 'cyclonedx-bom %s'", strings.Join(args, " "))
-	err := m.executable.Execute(pexec.Execution{
+	err = m.executable.Execute(ctx, pexec.Execution{
 		Args:   args,
 		Dir:    workingDir,
+		Env:    generatorEnv(binDir),
 		Stdout: buffer,
 		Stderr: buffer,
 	})
 
 	if err != nil {
 		m.logger.Detail(buffer.String())
-		return nil, fmt.Errorf("failed to run cyclonedx-bom: %w", err)
+		return BOM{}, fmt.Errorf("failed to run cyclonedx-bom: %w", err)
 	}
 
-	file, err := os.Open(filepath.Join(workingDir, "bom.json"))
+	file, err := os.Open(output)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open bom.json: %w", err)
+		return BOM{}, fmt.Errorf("failed to open bom.json: %w", err)
 	}
 	defer file.Close()
 
-	var bom struct {
-		Components []struct {
-			Name    string `json:"name"`
-			PURL    string `json:"purl"`
-			Version string `json:"version"`
-			Hashes  []struct {
-				Algorithm string `json:"alg"`
-				Content   string `json:"content"`
-			} `json:"hashes"`
-			Licenses []struct {
-				License struct {
-					ID string `json:"id"`
-				} `json:"license"`
-			} `json:"licenses"`
-		} `json:"components"`
+	var bom BOM
+	err = json.NewDecoder(file).Decode(&bom)
+	if err != nil {
+		return BOM{}, fmt.Errorf("failed to decode bom.json: %w", err)
 	}
 
-	err = json.NewDecoder(file).Decode(&bom)
+	bom.BOMFormat = "CycloneDX"
+	bom.SpecVersion = CycloneDXSpecVersion
+	bom.Version = 1
+
+	lockfile, err := FindLockfile(workingDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode bom.json: %w", err)
+		return BOM{}, err
+	}
+
+	if lockfile.Path != "" {
+		m.logger.Subprocess("Using lockfile %s", lockfile.Path)
+		bom.Metadata.Properties = append(bom.Metadata.Properties,
+			Property{Name: LockfileProperty, Value: lockfile.Path},
+			Property{Name: LockfileSHA256Property, Value: lockfile.SHA256},
+		)
 	}
 
-	var entries []packit.BOMEntry
-	for _, entry := range bom.Components {
-		packitEntry := packit.BOMEntry{
-			Name: entry.Name,
+	manager, err := FindPackageManager(workingDir)
+	if err != nil && !errors.Is(err, ErrNotNodeProject) {
+		return BOM{}, err
+	}
+
+	if manager.Name != "" {
+		if manager.Ignored != "" {
+			m.logger.Subprocess("Warning: ignoring the packageManager field of package.json: %s", manager.Ignored)
 		}
 
-		metadata := paketosbom.BOMMetadata{
-			Version: entry.Version,
-			PURL:    entry.PURL,
+		m.logger.Subprocess("Using package manager %s (from %s)", manager, manager.Source)
+		bom.Metadata.Properties = append(bom.Metadata.Properties, Property{Name: PackageManagerProperty, Value: manager.String()})
+	}
+
+	// Once the context is done, the BOM as completed so far is returned
+	// rather than discarded.
+	partial := func(err error) (BOM, error) {
+		if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			return bom, err
 		}
 
-		if len(entry.Hashes) > 0 {
-			algorithm, err := paketosbom.GetBOMChecksumAlgorithm(entry.Hashes[0].Algorithm)
-			if err != nil {
-				return nil, err
+		return BOM{}, err
+	}
+
+	tree, err := readInstalledTree(ctx, workingDir, manager)
+	if err != nil {
+		return partial(fmt.Errorf("failed to read installed tree: %w", err))
+	}
+
+	if tree.Source != "" {
+		m.logger.Subprocess("Using installed tree from %s", tree.Source)
+		bom.Metadata.Properties = append(bom.Metadata.Properties, Property{Name: InstalledTreeProperty, Value: tree.Source})
+		for _, cycle := range tree.Cycles {
+			m.logger.Subprocess("Skipping symlink cycle at %s", cycle)
+		}
+
+		if len(tree.Skipped) > 0 {
+			m.logger.Subprocess("Skipping %d optional dependencies that are not installed", len(tree.Skipped))
+			for _, pkg := range tree.Skipped {
+				m.logger.Action("%s@%s", pkg.Name, pkg.Version)
+				bom.Metadata.Properties = append(bom.Metadata.Properties, Property{Name: NotInstalledProperty, Value: pkg.PURL()})
 			}
-			metadata.Checksum = paketosbom.BOMChecksum{
-				Algorithm: algorithm,
-				Hash:      entry.Hashes[0].Content,
+		}
+		bom.Components = reconcileComponents(bom.Components, tree)
+	}
+
+	workspaces, err := FindWorkspaces(workingDir)
+	if err != nil {
+		return BOM{}, err
+	}
+
+	if len(workspaces) > 0 {
+		m.logger.Subprocess("Found %d workspace packages", len(workspaces))
+		bom.Components = workspaceComponents(bom.Components, workspaces)
+	}
+
+	components, licensed, err := addFileLicenses(ctx, workingDir, tree, bom.Components)
+	if err != nil {
+		return partial(err)
+	}
+	bom.Components = components
+
+	if licensed > 0 {
+		m.logger.Subprocess("Detected licenses of %d packages from license files", licensed)
+	}
+
+	components, copyrighted, err := addCopyrights(ctx, workingDir, tree, bom.Components)
+	if err != nil {
+		return partial(err)
+	}
+	bom.Components = components
+
+	if copyrighted > 0 {
+		m.logger.Subprocess("Found copyright statements for %d packages", copyrighted)
+	}
+
+	application, ok, err := ReadApplication(workingDir)
+	if err != nil {
+		return BOM{}, fmt.Errorf("failed to read application: %w", err)
+	}
+
+	var root *Application
+	if ok {
+		root = &application
+
+		component := application.Component()
+		bom.Metadata.Component = &component
+	}
+
+	if tree.Source != "" || len(workspaces) > 0 {
+		bom.Dependencies = dependencyGraph(tree, root, workspaces)
+	}
+
+	components, edges, err := addNativeBinaries(ctx, workingDir, tree, bom.Components)
+	if err != nil {
+		return partial(err)
+	}
+	bom.Components = components
+
+	if len(edges) > 0 {
+		m.logger.Subprocess("Found native binaries in %d packages", len(edges))
+		bom.Dependencies = addDependencyEdges(bom.Dependencies, edges)
+	}
+
+	artifacts, err := FindDownloadedArtifacts(ctx, workingDir, tree)
+	if err != nil {
+		return partial(err)
+	}
+
+	if len(artifacts) > 0 {
+		m.logger.Subprocess("Found %d downloaded artifacts", len(artifacts))
+
+		edges := map[string][]string{}
+		for _, artifact := range artifacts {
+			m.logger.Action("%s %s (%s)", artifact.Name, artifact.Version, artifact.Location)
+
+			component := artifact.Component()
+			bom.Components = append(bom.Components, component)
+			edges[artifact.DownloadedBy] = append(edges[artifact.DownloadedBy], component.BOMRef)
+		}
+		bom.Dependencies = addDependencyEdges(bom.Dependencies, edges)
+	}
+
+	return bom, nil
+}
+
+// generatorEnvPassthrough are the variables of the buildpack process that the
+// generator keeps, for Node.js to find its home and temporary directories and
+// to honor the options, proxies and certificates of the platform.
+var generatorEnvPassthrough = []string{
+	"HOME",
+	"TMPDIR",
+	"LANG",
+	"NODE_OPTIONS",
+	"NODE_EXTRA_CA_CERTS",
+	"HTTP_PROXY",
+	"HTTPS_PROXY",
+	"NO_PROXY",
+	"http_proxy",
+	"https_proxy",
+	"no_proxy",
+	"SSL_CERT_FILE",
+	"SSL_CERT_DIR",
+}
+
+// generatorEnv returns the environment that the generator runs with: a PATH
+// of the given bin directory in front of the PATH of the buildpack process,
+// which holds Node.js, and the variables of generatorEnvPassthrough.
+func generatorEnv(binDir string) []string {
+	var path []string
+	for _, dir := range []string{binDir, processPath} {
+		if dir != "" {
+			path = append(path, dir)
+		}
+	}
+
+	env := []string{"PATH=" + strings.Join(path, string(os.PathListSeparator))}
+	for _, name := range generatorEnvPassthrough {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+
+	return env
+}
+
+// reconcileComponents limits the components reported by the generator to the
+// packages in the installed tree, adding a component for every installed
+// package that the generator did not report. Copies of the same package
+// installed at several locations are reported as a single component with an
+// evidence occurrence for every location.
+func reconcileComponents(components []Component, tree InstalledTree) []Component {
+	installed := map[string]InstalledPackage{}
+	occurrences := map[string][]Occurrence{}
+	for _, pkg := range tree.Packages {
+		// The copy closest to the root of node_modules describes the package.
+		key := pkg.Name + "@" + pkg.Version
+		if existing, ok := installed[key]; !ok || strings.Count(pkg.Path, "node_modules/") < strings.Count(existing.Path, "node_modules/") {
+			installed[key] = pkg
+		}
+		occurrences[key] = append(occurrences[key], Occurrence{Location: pkg.Path})
+	}
+
+	var reconciled []Component
+	for _, component := range components {
+		key := component.PackageName() + "@" + component.Version
+		if _, ok := installed[key]; !ok {
+			continue
+		}
+
+		pkg := installed[key]
+		component.BOMRef = pkg.Ref()
+		component.PURL = pkg.PURL()
+		component.Hashes = mergeHashes(component.Hashes, ParseIntegrity(pkg.Integrity)...)
+		if pkg.Local() && !hasProperty(component.Properties, FirstPartyProperty) {
+			component.Properties = append(component.Properties, Property{Name: FirstPartyProperty, Value: "true"})
+		}
+		if !hasProperty(component.Properties, BundledProperty) {
+			component.Properties = append(component.Properties, pkg.bundleProperties()...)
+		}
+		component.Evidence = &Evidence{Occurrences: occurrences[key]}
+		reconciled = append(reconciled, component)
+		delete(installed, key)
+	}
+
+	for _, pkg := range tree.Packages {
+		key := pkg.Name + "@" + pkg.Version
+		if _, ok := installed[key]; !ok {
+			continue
+		}
+
+		component := installed[key].Component()
+		component.Evidence = &Evidence{Occurrences: occurrences[key]}
+		reconciled = append(reconciled, component)
+		delete(installed, key)
+	}
+
+	return reconciled
+}
+
+// dependencyGraph resolves the dependencies of every package in the installed
+// tree and of every workspace package into the CycloneDX dependency graph
+// between their components. Dependencies on other workspace packages resolve
+// to the workspace package itself. When there is an application, its direct
+// dependencies and its workspace packages hang off of it as the root of the
+// graph.
+func dependencyGraph(tree InstalledTree, application *Application, workspaces []Workspace) []Dependency {
+	index := map[string]InstalledPackage{}
+	for _, pkg := range tree.Packages {
+		index[pkg.Path] = pkg
+	}
+
+	for link, target := range tree.Links {
+		if pkg, ok := index[target]; ok {
+			index[link] = pkg
+		}
+	}
+
+	workspaceRefs := map[string]string{}
+	for _, workspace := range workspaces {
+		if workspace.Name != "" {
+			workspaceRefs[workspace.Name] = workspace.Ref()
+		}
+	}
+
+	var refs []string
+	dependsOn := map[string]map[string]bool{}
+	add := func(ref, path string, dependencies map[string]string) {
+		if _, ok := dependsOn[ref]; !ok {
+			refs = append(refs, ref)
+			dependsOn[ref] = map[string]bool{}
+		}
+
+		for name := range dependencies {
+			if workspaceRef, ok := workspaceRefs[name]; ok {
+				dependsOn[ref][workspaceRef] = true
+				continue
+			}
+
+			dependency, ok := resolvePackage(index, path, name)
+			if ok {
+				dependsOn[ref][dependency.Ref()] = true
 			}
 		}
+	}
 
-		var licenses []string
-		for _, license := range entry.Licenses {
-			licenses = append(licenses, license.License.ID)
+	if application != nil {
+		add(application.Ref(), "", application.Dependencies)
+		for _, workspace := range workspaces {
+			dependsOn[application.Ref()][workspace.Ref()] = true
 		}
-		metadata.Licenses = licenses
+	}
 
-		packitEntry.Metadata = metadata
+	for _, workspace := range workspaces {
+		add(workspace.Ref(), workspace.Path, workspace.Dependencies)
+	}
 
-		entries = append(entries, packitEntry)
+	for _, pkg := range tree.Packages {
+		add(pkg.Ref(), pkg.Path, pkg.Dependencies)
 	}
 
-	err = os.Remove(filepath.Join(workingDir, "bom.json"))
-	if err != nil {
-		return nil, fmt.Errorf("failed to remove bom.json: %w", err)
+	var graph []Dependency
+	for _, ref := range refs {
+		dependency := Dependency{Ref: ref}
+		for dependencyRef := range dependsOn[ref] {
+			dependency.DependsOn = append(dependency.DependsOn, dependencyRef)
+		}
+		sort.Strings(dependency.DependsOn)
+
+		graph = append(graph, dependency)
 	}
 
-	return entries, nil
+	return graph
 }
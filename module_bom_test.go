@@ -2,6 +2,9 @@ package nodemodulebom_test
 
 import (
 	"bytes"
+	gocontext "context"
+	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"os"
@@ -10,10 +13,6 @@ import (
 
 	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
 	"github.com/paketo-buildpacks/node-module-bom/fakes"
-	"github.com/paketo-buildpacks/packit/v2"
-
-	//nolint Ignore SA1019, informed usage of deprecated package
-	"github.com/paketo-buildpacks/packit/v2/paketosbom"
 	"github.com/paketo-buildpacks/packit/v2/pexec"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
 	"github.com/sclevine/spec"
@@ -39,8 +38,8 @@ func testModuleBOM(t *testing.T, context spec.G, it spec.S) {
 		Expect(err).NotTo(HaveOccurred())
 
 		executable = &fakes.Executable{}
-		executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
-			Expect(os.WriteFile(filepath.Join(workingDir, "bom.json"), []byte(`{
+		executable.ExecuteCall.Stub = func(_ gocontext.Context, execution pexec.Execution) error {
+			Expect(os.WriteFile(execution.Args[1], []byte(`{
 				"bomFormat": "CycloneDX",
 				"specVersion": "1.3",
 				"serialNumber": "urn:uuid:a717bde3-8a77-4ec6-a530-5d0d9007ecbe",
@@ -116,42 +115,84 @@ func testModuleBOM(t *testing.T, context spec.G, it spec.S) {
 	})
 
 	context("Generate", func() {
+		it.Before(func() {
+			Expect(os.Setenv("SOME_SECRET", "some-value")).To(Succeed())
+			Expect(os.Setenv("HTTPS_PROXY", "http://proxy.example.com:3128")).To(Succeed())
+			Expect(os.Setenv("SSL_CERT_FILE", "/etc/ssl/certs/some-bundle.pem")).To(Succeed())
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("SOME_SECRET")).To(Succeed())
+			Expect(os.Unsetenv("HTTPS_PROXY")).To(Succeed())
+			Expect(os.Unsetenv("SSL_CERT_FILE")).To(Succeed())
+		})
+
 		it("succeeds in installing the BOM generation tool and creating the BOM", func() {
-			bomEntries, err := moduleBOM.Generate(workingDir)
+			bom, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
 			Expect(err).ToNot(HaveOccurred())
 
-			Expect(executable.ExecuteCall.Receives.Execution).To(Equal(pexec.Execution{
-				Args:   []string{"-o", "bom.json"},
-				Dir:    workingDir,
-				Stdout: commandOutput,
-				Stderr: commandOutput,
-			}))
+			Expect(executable.ExecuteCall.Receives.Ctx).To(Equal(gocontext.Background()))
 
-			algorithm, err := paketosbom.GetBOMChecksumAlgorithm("SHA-1")
-			Expect(err).ToNot(HaveOccurred())
+			execution := executable.ExecuteCall.Receives.Execution
+			Expect(execution.Args).To(HaveLen(2))
+			Expect(execution.Args[0]).To(Equal("-o"))
+			Expect(execution.Args[1]).To(HavePrefix(os.TempDir()))
+			Expect(execution.Args[1]).NotTo(HavePrefix(workingDir))
+			Expect(filepath.Dir(execution.Args[1])).NotTo(BeADirectory())
+			Expect(execution.Dir).To(Equal(workingDir))
+			Expect(execution.Env).To(ContainElement(fmt.Sprintf("PATH=some-bin-dir%c%s", os.PathListSeparator, os.Getenv("PATH"))))
+			Expect(execution.Env).To(ContainElement("HTTPS_PROXY=http://proxy.example.com:3128"))
+			Expect(execution.Env).To(ContainElement("SSL_CERT_FILE=/etc/ssl/certs/some-bundle.pem"))
+			Expect(execution.Env).NotTo(ContainElement("SOME_SECRET=some-value"))
+			Expect(execution.Stdout).To(Equal(commandOutput))
+			Expect(execution.Stderr).To(Equal(commandOutput))
 
-			Expect(bomEntries).To(Equal([]packit.BOMEntry{
-				{
-					Name: "leftpad",
-					Metadata: paketosbom.BOMMetadata{
-						Checksum: paketosbom.BOMChecksum{
-							Algorithm: algorithm,
-							Hash:      "86b1a4de4face180ac545a83f1503523d8fed115",
+			Expect(bom).To(Equal(nodemodulebom.BOM{
+				BOMFormat:   "CycloneDX",
+				SpecVersion: "1.5",
+				Version:     1,
+				Metadata: nodemodulebom.Metadata{
+					Timestamp: "2021-08-16T19:35:52.107Z",
+					Tools: []nodemodulebom.Tool{
+						{
+							Vendor:  "CycloneDX",
+							Name:    "Node.js module",
+							Version: "3.0.3",
 						},
-						PURL:     "pkg:npm/leftpad@0.0.1",
-						Licenses: []string{"BSD-3-Clause"},
-						Version:  "0.0.1",
+					},
+					Component: &nodemodulebom.Component{
+						Type: "library",
 					},
 				},
-				{
-					Name: "rightpad",
-					Metadata: paketosbom.BOMMetadata{
-						PURL:     "pkg:npm/rightpad@1.0.0",
-						Licenses: []string{"Apache"},
-						Version:  "1.0.0",
-						Checksum: paketosbom.BOMChecksum{
-							Algorithm: paketosbom.SHA256,
-							Hash:      "123456789",
+				Components: []nodemodulebom.Component{
+					{
+						Type:    "library",
+						Name:    "leftpad",
+						Version: "0.0.1",
+						PURL:    "pkg:npm/leftpad@0.0.1",
+						Hashes: []nodemodulebom.Hash{
+							{
+								Algorithm: "SHA-1",
+								Content:   "86b1a4de4face180ac545a83f1503523d8fed115",
+							},
+						},
+						Licenses: []nodemodulebom.LicenseChoice{
+							{License: &nodemodulebom.License{ID: "BSD-3-Clause"}},
+						},
+					},
+					{
+						Type:    "library",
+						Name:    "rightpad",
+						Version: "1.0.0",
+						PURL:    "pkg:npm/rightpad@1.0.0",
+						Hashes: []nodemodulebom.Hash{
+							{
+								Algorithm: "SHA-256",
+								Content:   "123456789",
+							},
+						},
+						Licenses: []nodemodulebom.LicenseChoice{
+							{License: &nodemodulebom.License{ID: "Apache"}},
 						},
 					},
 				},
@@ -162,8 +203,8 @@ func testModuleBOM(t *testing.T, context spec.G, it spec.S) {
 
 		context("the bom.json has no hashes", func() {
 			it.Before(func() {
-				executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
-					Expect(os.WriteFile(filepath.Join(workingDir, "bom.json"), []byte(`{
+				executable.ExecuteCall.Stub = func(_ gocontext.Context, execution pexec.Execution) error {
+					Expect(os.WriteFile(execution.Args[1], []byte(`{
 						"components": [
 							{
 								"type": "library",
@@ -185,32 +226,562 @@ func testModuleBOM(t *testing.T, context spec.G, it spec.S) {
 				}
 			})
 			it("the output BOM does not contain hashes", func() {
-				bomEntries, err := moduleBOM.Generate(workingDir)
+				bom, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
 				Expect(err).ToNot(HaveOccurred())
-				Expect(bomEntries).To(Equal([]packit.BOMEntry{
+				Expect(bom.Components).To(Equal([]nodemodulebom.Component{
 					{
-						Name: "leftpad",
-						Metadata: paketosbom.BOMMetadata{
-							PURL:     "pkg:npm/leftpad@0.0.1",
-							Licenses: []string{"BSD-3-Clause"},
-							Version:  "0.0.1",
+						Type:    "library",
+						Name:    "leftpad",
+						Version: "0.0.1",
+						PURL:    "pkg:npm/leftpad@0.0.1",
+						Licenses: []nodemodulebom.LicenseChoice{
+							{License: &nodemodulebom.License{ID: "BSD-3-Clause"}},
+						},
+					},
+				}))
+			})
+		})
+
+		context("when the working directory has an installed node_modules tree", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", ".package-lock.json"), []byte(`{
+					"lockfileVersion": 3,
+					"packages": {
+						"node_modules/leftpad": {
+							"version": "0.0.1",
+							"integrity": "sha1-hrGk3k+s4YCsVFqD8VA1I9j+0RU= sha512-N9DJPF4XpR4XKbuk9aoVh3B5ZO25/yqv+ZkqZTbuOXjOSp/BR7WzuuCy6Gzd5O7zPLWLNFKhPfrCv2xG67fYfA=="
+						},
+						"node_modules/@scope/uppercase": {
+							"version": "2.0.0",
+							"resolved": "https://npm.example.com/@scope/uppercase/-/uppercase-2.0.0.tgz",
+							"integrity": "sha1-Yid0pMb1zH3ZTSlt9iNoJ9ZvYJw=",
+							"license": "MIT",
+							"dependencies": {"leftpad": "^0.0.1"}
+						},
+						"node_modules/@scope/uppercase/node_modules/leftpad": {
+							"version": "0.0.1"
+						}
+					}
+				}`), 0600)).To(Succeed())
+			})
+
+			it("limits the BOM to the installed packages and adds their integrity hashes", func() {
+				bom, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bom.Components).To(Equal([]nodemodulebom.Component{
+					{
+						BOMRef:  "pkg:npm/leftpad@0.0.1",
+						Type:    "library",
+						Name:    "leftpad",
+						Version: "0.0.1",
+						PURL:    "pkg:npm/leftpad@0.0.1",
+						Hashes: []nodemodulebom.Hash{
+							{
+								Algorithm: "SHA-1",
+								Content:   "86b1a4de4face180ac545a83f1503523d8fed115",
+							},
+							{
+								Algorithm: "SHA-512",
+								Content:   "37d0c93c5e17a51e1729bba4f5aa1587707964edb9ff2aaff9992a6536ee3978ce4a9fc147b5b3bae0b2e86cdde4eef33cb58b3452a13dfac2bf6c46ebb7d87c",
+							},
+						},
+						Licenses: []nodemodulebom.LicenseChoice{
+							{License: &nodemodulebom.License{ID: "BSD-3-Clause"}},
+						},
+						Evidence: &nodemodulebom.Evidence{
+							Occurrences: []nodemodulebom.Occurrence{
+								{Location: "node_modules/@scope/uppercase/node_modules/leftpad"},
+								{Location: "node_modules/leftpad"},
+							},
+						},
+					},
+					{
+						BOMRef:  "pkg:npm/%40scope/uppercase@2.0.0",
+						Type:    "library",
+						Group:   "@scope",
+						Name:    "uppercase",
+						Version: "2.0.0",
+						PURL:    "pkg:npm/%40scope/uppercase@2.0.0?repository_url=https://npm.example.com",
+						Hashes: []nodemodulebom.Hash{
+							{
+								Algorithm: "SHA-1",
+								Content:   "622774a4c6f5cc7dd94d296df6236827d66f609c",
+							},
+						},
+						Licenses: []nodemodulebom.LicenseChoice{
+							{License: &nodemodulebom.License{ID: "MIT"}},
+						},
+						Evidence: &nodemodulebom.Evidence{
+							Occurrences: []nodemodulebom.Occurrence{
+								{Location: "node_modules/@scope/uppercase"},
+							},
+						},
+					},
+				}))
+				Expect(bom.Dependencies).To(Equal([]nodemodulebom.Dependency{
+					{
+						Ref:       "pkg:npm/%40scope/uppercase@2.0.0",
+						DependsOn: []string{"pkg:npm/leftpad@0.0.1"},
+					},
+					{
+						Ref: "pkg:npm/leftpad@0.0.1",
+					},
+				}))
+				Expect(bom.Metadata.Properties).To(Equal([]nodemodulebom.Property{
+					{Name: "paketo:node-module-bom:package-manager", Value: "npm"},
+					{Name: "paketo:node-module-bom:installed-tree", Value: "node_modules/.package-lock.json"},
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring("Using installed tree from node_modules/.package-lock.json"))
+			})
+		})
+
+		context("when optional packages of the lockfile were not installed", func() {
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules", "leftpad"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "leftpad", "package.json"), []byte(`{}`), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), []byte(`{
+					"lockfileVersion": 3,
+					"packages": {
+						"node_modules/leftpad": {
+							"version": "0.0.1"
 						},
+						"node_modules/@esbuild/darwin-arm64": {
+							"version": "0.19.0",
+							"optional": true,
+							"os": ["darwin"],
+							"cpu": ["arm64"]
+						}
+					}
+				}`), 0600)).To(Succeed())
+			})
+
+			it("records them as not installed", func() {
+				bom, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bom.Components).To(HaveLen(1))
+				Expect(bom.Components[0].Name).To(Equal("leftpad"))
+				Expect(bom.Metadata.Properties).To(ContainElement(nodemodulebom.Property{
+					Name:  "paketo:node-module-bom:not-installed",
+					Value: "pkg:npm/%40esbuild/darwin-arm64@0.19.0",
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring("Skipping 1 optional dependencies that are not installed"))
+				Expect(buffer.String()).To(ContainSubstring("@esbuild/darwin-arm64@0.19.0"))
+			})
+		})
+
+		context("when installed packages contain native addons and WebAssembly modules", func() {
+			var elfHeader []byte
+
+			it.Before(func() {
+				elfHeader = make([]byte, 64)
+				copy(elfHeader, []byte{0x7f, 'E', 'L', 'F', 2, 1, 1})
+				binary.LittleEndian.PutUint16(elfHeader[16:], 3)
+				binary.LittleEndian.PutUint16(elfHeader[18:], 183)
+				binary.LittleEndian.PutUint32(elfHeader[20:], 1)
+				binary.LittleEndian.PutUint16(elfHeader[52:], 64)
+
+				for path, content := range map[string][]byte{
+					"node_modules/leftpad/package.json":                                 []byte(`{"name": "leftpad", "version": "0.0.1", "gypfile": true}`),
+					"node_modules/leftpad/binding.gyp":                                  []byte(`{}`),
+					"node_modules/leftpad/build/Release/leftpad.node":                   elfHeader,
+					"node_modules/leftpad/prebuilds/darwin-x64/node.napi.node":          []byte("prebuilt"),
+					"node_modules/leftpad/lib/binding/node-v108-win32-x64/leftpad.node": []byte("prebuilt"),
+					"node_modules/leftpad/node_modules/nested/package.json":             []byte(`{"name": "nested", "version": "1.0.0"}`),
+					"node_modules/leftpad/node_modules/nested/nested.node":              []byte("nested"),
+					"node_modules/@scope/uppercase/package.json":                        []byte(`{"name": "@scope/uppercase", "version": "2.0.0"}`),
+					"node_modules/@scope/uppercase/dist/uppercase.wasm":                 []byte("\x00asm"),
+				} {
+					Expect(os.MkdirAll(filepath.Join(workingDir, filepath.Dir(path)), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, path), content, 0600)).To(Succeed())
+				}
+			})
+
+			it("marks the packages and adds a file component for every binary", func() {
+				bom, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
+				Expect(err).ToNot(HaveOccurred())
+
+				components := map[string]nodemodulebom.Component{}
+				for _, component := range bom.Components {
+					components[component.BOMRef] = component
+				}
+
+				Expect(components["pkg:npm/leftpad@0.0.1"].Properties).To(ContainElement(nodemodulebom.Property{Name: "paketo:node-module-bom:native-addon", Value: "true"}))
+				Expect(components["pkg:npm/%40scope/uppercase@2.0.0"].Properties).To(ContainElement(nodemodulebom.Property{Name: "paketo:node-module-bom:wasm", Value: "true"}))
+				Expect(components["pkg:npm/nested@1.0.0"].Properties).To(ContainElement(nodemodulebom.Property{Name: "paketo:node-module-bom:native-addon", Value: "true"}))
+
+				Expect(components["file:node_modules/leftpad/build/Release/leftpad.node"]).To(Equal(nodemodulebom.Component{
+					BOMRef: "file:node_modules/leftpad/build/Release/leftpad.node",
+					Type:   "file",
+					Name:   "node_modules/leftpad/build/Release/leftpad.node",
+					Hashes: []nodemodulebom.Hash{
+						{Algorithm: "SHA-256", Content: fmt.Sprintf("%x", sha256.Sum256(elfHeader))},
+					},
+					Properties: []nodemodulebom.Property{
+						{Name: "paketo:node-module-bom:binary", Value: "native-addon"},
+						{Name: "paketo:node-module-bom:platform", Value: "linux-arm64"},
 					},
 				}))
+				Expect(components["file:node_modules/leftpad/prebuilds/darwin-x64/node.napi.node"].Properties).To(Equal([]nodemodulebom.Property{
+					{Name: "paketo:node-module-bom:binary", Value: "native-addon"},
+					{Name: "paketo:node-module-bom:platform", Value: "darwin-x64"},
+					{Name: "paketo:node-module-bom:abi", Value: "napi"},
+				}))
+				Expect(components["file:node_modules/leftpad/lib/binding/node-v108-win32-x64/leftpad.node"].Properties).To(Equal([]nodemodulebom.Property{
+					{Name: "paketo:node-module-bom:binary", Value: "native-addon"},
+					{Name: "paketo:node-module-bom:platform", Value: "win32-x64"},
+					{Name: "paketo:node-module-bom:abi", Value: "node-v108"},
+				}))
+				Expect(components["file:node_modules/@scope/uppercase/dist/uppercase.wasm"].Properties).To(Equal([]nodemodulebom.Property{
+					{Name: "paketo:node-module-bom:binary", Value: "wasm"},
+					{Name: "paketo:node-module-bom:platform", Value: "wasm32"},
+				}))
+
+				Expect(bom.Dependencies).To(ContainElement(nodemodulebom.Dependency{
+					Ref: "pkg:npm/leftpad@0.0.1",
+					DependsOn: []string{
+						"file:node_modules/leftpad/build/Release/leftpad.node",
+						"file:node_modules/leftpad/lib/binding/node-v108-win32-x64/leftpad.node",
+						"file:node_modules/leftpad/prebuilds/darwin-x64/node.napi.node",
+					},
+				}))
+				Expect(bom.Dependencies).To(ContainElement(nodemodulebom.Dependency{
+					Ref:       "pkg:npm/nested@1.0.0",
+					DependsOn: []string{"file:node_modules/leftpad/node_modules/nested/nested.node"},
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring("Found native binaries in 3 packages"))
+			})
+		})
+
+		context("when an installed package downloaded artifacts during install", func() {
+			it.Before(func() {
+				for path, content := range map[string]string{
+					"node_modules/sharp/package.json":                                  `{"name": "sharp", "version": "0.32.6"}`,
+					"node_modules/sharp/vendor/8.14.5/linux-x64/versions.json":         `{"vips": "8.14.5"}`,
+					"node_modules/sharp/vendor/8.14.5/linux-x64/lib/libvips-cpp.so.42": "libvips",
+				} {
+					Expect(os.MkdirAll(filepath.Join(workingDir, filepath.Dir(path)), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, path), []byte(content), 0600)).To(Succeed())
+				}
+			})
+
+			it("adds the artifacts as components of the downloading package", func() {
+				bom, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bom.Components).To(ContainElement(nodemodulebom.Component{
+					BOMRef:  "pkg:generic/libvips@8.14.5",
+					Type:    "application",
+					Name:    "libvips",
+					Version: "8.14.5",
+					PURL:    "pkg:generic/libvips@8.14.5",
+					Hashes: []nodemodulebom.Hash{
+						{Algorithm: "SHA-256", Content: fmt.Sprintf("%x", sha256.Sum256([]byte("libvips")))},
+					},
+					Properties: []nodemodulebom.Property{
+						{Name: "paketo:node-module-bom:location", Value: "node_modules/sharp/vendor/8.14.5/linux-x64"},
+						{Name: "paketo:node-module-bom:downloaded-by", Value: "pkg:npm/sharp@0.32.6"},
+					},
+				}))
+				Expect(bom.Dependencies).To(ContainElement(nodemodulebom.Dependency{
+					Ref:       "pkg:npm/sharp@0.32.6",
+					DependsOn: []string{"pkg:generic/libvips@8.14.5"},
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring("Found 1 downloaded artifacts"))
+				Expect(buffer.String()).To(ContainSubstring("libvips 8.14.5 (node_modules/sharp/vendor/8.14.5/linux-x64)"))
+			})
+		})
+
+		context("when installed packages declare no license", func() {
+			it.Before(func() {
+				for path, content := range map[string]string{
+					"node_modules/unlicensed/package.json": `{"name": "unlicensed", "version": "1.0.0"}`,
+					"node_modules/unlicensed/LICENSE.md":   "# The ISC License\n\nCopyright (c) Some Author\n\nPermission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.\n\nTHE SOFTWARE IS PROVIDED \"AS IS\" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.\n",
+					"node_modules/declared/package.json":   `{"name": "declared", "version": "1.0.0", "license": "MIT"}`,
+					"node_modules/declared/LICENSE":        "Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted.",
+				} {
+					Expect(os.MkdirAll(filepath.Join(workingDir, filepath.Dir(path)), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, path), []byte(content), 0600)).To(Succeed())
+				}
+			})
+
+			it("detects their license from their license files", func() {
+				bom, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
+				Expect(err).ToNot(HaveOccurred())
+
+				components := map[string]nodemodulebom.Component{}
+				for _, component := range bom.Components {
+					components[component.BOMRef] = component
+				}
+
+				Expect(components["pkg:npm/unlicensed@1.0.0"].Licenses).To(Equal([]nodemodulebom.LicenseChoice{
+					{
+						License: &nodemodulebom.License{
+							ID: "ISC",
+							Properties: []nodemodulebom.Property{
+								{Name: "paketo:node-module-bom:license:file", Value: "LICENSE.md"},
+								{Name: "paketo:node-module-bom:license:confidence", Value: "0.97"},
+							},
+						},
+					},
+				}))
+				Expect(components["pkg:npm/unlicensed@1.0.0"].Properties).To(ContainElement(nodemodulebom.Property{Name: "paketo:node-module-bom:license:source", Value: "file"}))
+
+				Expect(components["pkg:npm/declared@1.0.0"].Licenses).To(Equal([]nodemodulebom.LicenseChoice{
+					{License: &nodemodulebom.License{ID: "MIT"}},
+				}))
+				Expect(components["pkg:npm/declared@1.0.0"].Properties).NotTo(ContainElement(nodemodulebom.Property{Name: "paketo:node-module-bom:license:source", Value: "file"}))
+
+				Expect(buffer.String()).To(ContainSubstring("Detected licenses of 1 packages from license files"))
+			})
+		})
+
+		context("when installed packages have copyright statements", func() {
+			it.Before(func() {
+				for path, content := range map[string]string{
+					"node_modules/leftpad/package.json": `{"name": "leftpad", "version": "0.0.1", "license": "BSD-3-Clause"}`,
+					"node_modules/leftpad/LICENSE":      "Copyright (c) 2015 Some Author\n",
+					"node_modules/leftpad/index.js":     "/*! Copyright 2016 Other Author */\n",
+				} {
+					Expect(os.MkdirAll(filepath.Join(workingDir, filepath.Dir(path)), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, path), []byte(content), 0600)).To(Succeed())
+				}
+			})
+
+			it("records them as the copyright of the component", func() {
+				bom, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bom.Components).To(HaveLen(1))
+				Expect(bom.Components[0].Copyright).To(Equal("Copyright (c) 2015 Some Author\nCopyright 2016 Other Author"))
+
+				Expect(buffer.String()).To(ContainSubstring("Found copyright statements for 1 packages"))
+			})
+		})
+
+		context("when the application has a package.json", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{
+					"name": "some-app",
+					"version": "1.2.3",
+					"license": "MIT",
+					"repository": "github:acme/some-app",
+					"dependencies": {"leftpad": "^0.0.1"}
+				}`), 0600)).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules", "leftpad"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "leftpad", "package.json"), []byte(`{"name": "leftpad", "version": "0.0.1"}`), 0600)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules", "rightpad"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "rightpad", "package.json"), []byte(`{"name": "rightpad", "version": "1.0.0"}`), 0600)).To(Succeed())
+			})
+
+			it("describes the application as the root of the dependency graph", func() {
+				bom, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bom.Metadata.Component).To(Equal(&nodemodulebom.Component{
+					BOMRef:  "pkg:npm/some-app@1.2.3",
+					Type:    "application",
+					Name:    "some-app",
+					Version: "1.2.3",
+					PURL:    "pkg:npm/some-app@1.2.3",
+					Licenses: []nodemodulebom.LicenseChoice{
+						{License: &nodemodulebom.License{ID: "MIT"}},
+					},
+					ExternalReferences: []nodemodulebom.ExternalReference{
+						{Type: "vcs", URL: "git+https://github.com/acme/some-app.git"},
+					},
+				}))
+
+				Expect(bom.Dependencies).To(Equal([]nodemodulebom.Dependency{
+					{
+						Ref:       "pkg:npm/some-app@1.2.3",
+						DependsOn: []string{"pkg:npm/leftpad@0.0.1"},
+					},
+					{
+						Ref: "pkg:npm/leftpad@0.0.1",
+					},
+					{
+						Ref: "pkg:npm/rightpad@1.0.0",
+					},
+				}))
+			})
+		})
+
+		context("when the application is a workspace (monorepo)", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"name": "monorepo", "version": "1.0.0", "workspaces": ["packages/*"]}`), 0600)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(workingDir, "packages", "rightpad"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "packages", "rightpad", "package.json"), []byte(`{
+					"name": "rightpad",
+					"version": "1.0.0",
+					"dependencies": {"leftpad": "^0.0.1"}
+				}`), 0600)).To(Succeed())
+
+				Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", ".package-lock.json"), []byte(`{
+					"lockfileVersion": 3,
+					"packages": {
+						"node_modules/leftpad": {
+							"version": "0.0.1"
+						},
+						"node_modules/rightpad": {
+							"resolved": "packages/rightpad",
+							"link": true
+						}
+					}
+				}`), 0600)).To(Succeed())
+			})
+
+			it("models the workspace packages as first-party components", func() {
+				bom, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bom.Components).To(HaveLen(2))
+				Expect(bom.Components[0].Name).To(Equal("leftpad"))
+				Expect(bom.Components[1]).To(Equal(nodemodulebom.Component{
+					BOMRef:  "pkg:npm/rightpad@1.0.0",
+					Type:    "library",
+					Name:    "rightpad",
+					Version: "1.0.0",
+					PURL:    "pkg:npm/rightpad@1.0.0",
+					Properties: []nodemodulebom.Property{
+						{Name: "paketo:node-module-bom:first-party", Value: "true"},
+						{Name: "paketo:node-module-bom:workspace", Value: "packages/rightpad"},
+					},
+				}))
+
+				Expect(bom.Dependencies).To(Equal([]nodemodulebom.Dependency{
+					{
+						Ref:       "pkg:npm/monorepo@1.0.0",
+						DependsOn: []string{"pkg:npm/rightpad@1.0.0"},
+					},
+					{
+						Ref:       "pkg:npm/rightpad@1.0.0",
+						DependsOn: []string{"pkg:npm/leftpad@0.0.1"},
+					},
+					{
+						Ref: "pkg:npm/leftpad@0.0.1",
+					},
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring("Found 1 workspace packages"))
+			})
+		})
+
+		context("when the working directory has a lockfile", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), []byte(`{}`), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "npm-shrinkwrap.json"), []byte(`{"lockfileVersion": 3}`), 0600)).To(Succeed())
+			})
+
+			it("records the lockfile that takes precedence in the BOM metadata", func() {
+				bom, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bom.Metadata.Properties).To(Equal([]nodemodulebom.Property{
+					{Name: "paketo:node-module-bom:lockfile", Value: "npm-shrinkwrap.json"},
+					{Name: "paketo:node-module-bom:lockfile:sha256", Value: "da4f67e19cdb13d541f3ce247dd8c1efc6601d9a2ad470faf2cfa35ba876fa75"},
+					{Name: "paketo:node-module-bom:package-manager", Value: "npm"},
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring("Using lockfile npm-shrinkwrap.json"))
+				Expect(buffer.String()).To(ContainSubstring("Using package manager npm (from npm-shrinkwrap.json)"))
+			})
+		})
+
+		context("when the application is installed by pnpm", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"name": "some-app", "version": "1.0.0", "packageManager": "pnpm@8.6.0", "dependencies": {"@scope/uppercase": "^2.0.0"}}`), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-lock.yaml"), []byte("lockfileVersion: '6.0'\n"), 0600)).To(Succeed())
+
+				for path, content := range map[string]string{
+					"node_modules/.pnpm/@scope+uppercase@2.0.0/node_modules/@scope/uppercase/package.json": `{"name": "@scope/uppercase", "version": "2.0.0", "dependencies": {"leftpad": "^0.0.1"}}`,
+					"node_modules/.pnpm/leftpad@0.0.1/node_modules/leftpad/package.json":                   `{"name": "leftpad", "version": "0.0.1"}`,
+				} {
+					Expect(os.MkdirAll(filepath.Join(workingDir, filepath.Dir(path)), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, path), []byte(content), 0600)).To(Succeed())
+				}
+
+				Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules", "@scope"), os.ModePerm)).To(Succeed())
+				Expect(os.Symlink("../.pnpm/@scope+uppercase@2.0.0/node_modules/@scope/uppercase", filepath.Join(workingDir, "node_modules", "@scope", "uppercase"))).To(Succeed())
+				Expect(os.Symlink("../../leftpad@0.0.1/node_modules/leftpad", filepath.Join(workingDir, "node_modules", ".pnpm", "@scope+uppercase@2.0.0", "node_modules", "leftpad"))).To(Succeed())
+			})
+
+			it("reads the installed tree from the virtual store", func() {
+				bom, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bom.Metadata.Properties).To(ContainElement(nodemodulebom.Property{Name: "paketo:node-module-bom:package-manager", Value: "pnpm@8.6.0"}))
+				Expect(bom.Metadata.Properties).To(ContainElement(nodemodulebom.Property{Name: "paketo:node-module-bom:installed-tree", Value: "node_modules/.pnpm"}))
+
+				var locations []string
+				for _, component := range bom.Components {
+					for _, occurrence := range component.Evidence.Occurrences {
+						locations = append(locations, occurrence.Location)
+					}
+				}
+				Expect(locations).To(ConsistOf(
+					"node_modules/.pnpm/@scope+uppercase@2.0.0/node_modules/@scope/uppercase",
+					"node_modules/.pnpm/leftpad@0.0.1/node_modules/leftpad",
+				))
+
+				Expect(bom.Dependencies).To(Equal([]nodemodulebom.Dependency{
+					{Ref: "pkg:npm/some-app@1.0.0", DependsOn: []string{"pkg:npm/%40scope/uppercase@2.0.0"}},
+					{Ref: "pkg:npm/%40scope/uppercase@2.0.0", DependsOn: []string{"pkg:npm/leftpad@0.0.1"}},
+					{Ref: "pkg:npm/leftpad@0.0.1"},
+				}))
+
+				Expect(buffer.String()).To(ContainSubstring("Using package manager pnpm@8.6.0 (from packageManager)"))
+			})
+		})
+
+		context("when the context is done after the generator completes", func() {
+			var cancel gocontext.CancelFunc
+
+			it.Before(func() {
+				Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules", "leftpad"), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "leftpad", "package.json"), []byte(`{"name": "leftpad", "version": "0.0.1"}`), 0600)).To(Succeed())
+
+				stub := executable.ExecuteCall.Stub
+				executable.ExecuteCall.Stub = func(ctx gocontext.Context, execution pexec.Execution) error {
+					defer cancel()
+					return stub(ctx, execution)
+				}
+			})
+
+			it("returns the BOM that was built before along with the error of the context", func() {
+				var ctx gocontext.Context
+				ctx, cancel = gocontext.WithCancel(gocontext.Background())
+				defer cancel()
+
+				bom, err := moduleBOM.Generate(ctx, workingDir, "some-bin-dir")
+				Expect(err).To(MatchError("failed to read installed tree: context canceled"))
+				Expect(errors.Is(err, gocontext.Canceled)).To(BeTrue())
+
+				Expect(bom.BOMFormat).To(Equal("CycloneDX"))
+				Expect(bom.Components).To(HaveLen(2))
+				Expect(bom.Components[0].Name).To(Equal("leftpad"))
 			})
 		})
 
 		context("failure cases", func() {
 			context("the cyclonedx-bom executable call fails", func() {
 				it.Before(func() {
-					executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
+					executable.ExecuteCall.Stub = func(_ gocontext.Context, execution pexec.Execution) error {
 						fmt.Fprintln(execution.Stdout, "build error stdout")
 						fmt.Fprintln(execution.Stderr, "build error stderr")
 						return errors.New("error")
 					}
 				})
 				it("returns an error", func() {
-					_, err := moduleBOM.Generate(workingDir)
+					_, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
 					Expect(err).To(MatchError("failed to run cyclonedx-bom: error"))
 
 					Expect(buffer.String()).To(ContainSubstring("        build error stdout"))
@@ -220,14 +791,14 @@ func testModuleBOM(t *testing.T, context spec.G, it spec.S) {
 
 			context("cannot open the bom.json file", func() {
 				it.Before(func() {
-					executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
-						Expect(os.WriteFile(filepath.Join(workingDir, "bom.json"), []byte(``), 0000)).To(Succeed())
+					executable.ExecuteCall.Stub = func(_ gocontext.Context, execution pexec.Execution) error {
+						Expect(os.WriteFile(execution.Args[1], []byte(``), 0000)).To(Succeed())
 						return nil
 					}
 				})
 
 				it("returns an error", func() {
-					_, err := moduleBOM.Generate(workingDir)
+					_, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
 					Expect(err).To(HaveOccurred())
 					Expect(err).To(MatchError(ContainSubstring("failed to open bom.json")))
 				})
@@ -235,53 +806,27 @@ func testModuleBOM(t *testing.T, context spec.G, it spec.S) {
 
 			context("cannot decode the bom.json into a struct", func() {
 				it.Before(func() {
-					executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
-						Expect(os.WriteFile(filepath.Join(workingDir, "bom.json"), []byte(``), 0600)).To(Succeed())
+					executable.ExecuteCall.Stub = func(_ gocontext.Context, execution pexec.Execution) error {
+						Expect(os.WriteFile(execution.Args[1], []byte(``), 0600)).To(Succeed())
 						return nil
 					}
 				})
 
 				it("returns an error", func() {
-					_, err := moduleBOM.Generate(workingDir)
+					_, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
 					Expect(err).To(MatchError(ContainSubstring("failed to decode bom.json")))
 				})
 			})
 
-			context("the BOM entry contains unsupported checksum algorithm", func() {
+			context("the installed tree cannot be read", func() {
 				it.Before(func() {
-					executable.ExecuteCall.Stub = func(execution pexec.Execution) error {
-						Expect(os.WriteFile(filepath.Join(workingDir, "bom.json"), []byte(`{
-							"components": [
-								{
-									"type": "library",
-									"name": "leftpad",
-									"version": "0.0.1",
-									"description": "left pad numbers",
-									"hashes": [
-										{
-											"alg": "randomAlgorithm",
-											"content": "86b1a4de4face180ac545a83f1503523d8fed115"
-										}
-									]
-								}
-							]
-						}`), 0600)).To(Succeed())
-						return nil
-					}
-
-					buffer = bytes.NewBuffer(nil)
-					commandOutput = bytes.NewBuffer(nil)
-
-					moduleBOM = nodemodulebom.NewModuleBOM(executable, scribe.NewEmitter(buffer))
-				})
-
-				it.After(func() {
-					Expect(os.RemoveAll(workingDir)).To(Succeed())
+					Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules"), os.ModePerm)).To(Succeed())
+					Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", ".package-lock.json"), []byte(`%%%`), 0600)).To(Succeed())
 				})
 
 				it("returns an error", func() {
-					_, err := moduleBOM.Generate(workingDir)
-					Expect(err).To(MatchError("failed to get supported BOM checksum algorithm: randomAlgorithm is not valid"))
+					_, err := moduleBOM.Generate(gocontext.Background(), workingDir, "some-bin-dir")
+					Expect(err).To(MatchError(ContainSubstring("failed to read installed tree")))
 				})
 			})
 		})
@@ -0,0 +1,379 @@
+package nodemodulebom
+
+import (
+	"context"
+	"crypto/sha256"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// NativeAddonProperty marks packages that contain a native addon, either
+	// as a prebuilt *.node binary or as sources built by node-gyp.
+	NativeAddonProperty = "paketo:node-module-bom:native-addon"
+
+	// WASMProperty marks packages that contain WebAssembly modules.
+	WASMProperty = "paketo:node-module-bom:wasm"
+
+	// BinaryProperty records the kind of a binary file component, either
+	// "native-addon" or "wasm".
+	BinaryProperty = "paketo:node-module-bom:binary"
+
+	// PlatformProperty records the platform that a binary targets, such as
+	// "linux-x64".
+	PlatformProperty = "paketo:node-module-bom:platform"
+
+	// ABIProperty records the Node.js ABI that a native addon targets, either
+	// "napi" (or "napi-v<version>") for Node-API addons or the
+	// NODE_MODULE_VERSION (e.g. "node-v108").
+	ABIProperty = "paketo:node-module-bom:abi"
+)
+
+// NativeBinary is a native addon or WebAssembly module inside of an installed
+// package.
+type NativeBinary struct {
+	// Path is the path of the binary relative to the application directory.
+	Path     string
+	Kind     string
+	SHA256   string
+	Platform string
+	ABI      string
+}
+
+// Component returns the CycloneDX file component describing the binary.
+func (b NativeBinary) Component() Component {
+	component := Component{
+		BOMRef: "file:" + b.Path,
+		Type:   "file",
+		Name:   b.Path,
+		Hashes: []Hash{{Algorithm: "SHA-256", Content: b.SHA256}},
+		Properties: []Property{
+			{Name: BinaryProperty, Value: b.Kind},
+		},
+	}
+
+	if b.Platform != "" {
+		component.Properties = append(component.Properties, Property{Name: PlatformProperty, Value: b.Platform})
+	}
+
+	if b.ABI != "" {
+		component.Properties = append(component.Properties, Property{Name: ABIProperty, Value: b.ABI})
+	}
+
+	return component
+}
+
+// nativePackage is the result of scanning an installed package for native
+// code.
+type nativePackage struct {
+	Addon    bool
+	WASM     bool
+	Binaries []NativeBinary
+}
+
+// scanNativePackage looks for native addons and WebAssembly modules inside
+// the directory of the installed package, without descending into the
+// packages nested in its node_modules directory. A package is a native addon
+// when it has a binding.gyp, declares "gypfile" or a node-pre-gyp "binary"
+// in its package.json, or ships *.node binaries.
+func scanNativePackage(ctx context.Context, workingDir string, pkg InstalledPackage) (nativePackage, error) {
+	root, err := filepath.EvalSymlinks(filepath.Join(workingDir, pkg.Path))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nativePackage{}, nil
+		}
+
+		return nativePackage{}, fmt.Errorf("failed to resolve %s: %w", pkg.Path, err)
+	}
+
+	var result nativePackage
+
+	manifest, err := readPackageJSON(filepath.Join(root, "package.json"))
+	if err == nil && (manifest.Gypfile || len(manifest.Binary) > 0) {
+		result.Addon = true
+	}
+
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if entry.IsDir() {
+			if path != root && entry.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		var kind string
+		switch {
+		case rel == "binding.gyp":
+			result.Addon = true
+			return nil
+		case strings.HasSuffix(entry.Name(), ".node"):
+			result.Addon = true
+			kind = "native-addon"
+		case strings.HasSuffix(entry.Name(), ".wasm"):
+			result.WASM = true
+			kind = "wasm"
+		default:
+			return nil
+		}
+
+		binary, err := inspectBinary(path, kind)
+		if err != nil {
+			return err
+		}
+		binary.Path = pkg.Path + "/" + rel
+		if kind == "native-addon" {
+			binary.ABI = addonABI(rel)
+			if binary.Platform == "" {
+				binary.Platform = pathPlatform(rel)
+			}
+		}
+
+		result.Binaries = append(result.Binaries, binary)
+
+		return nil
+	})
+	if err != nil {
+		return nativePackage{}, fmt.Errorf("failed to scan %s for native binaries: %w", pkg.Path, err)
+	}
+
+	return result, nil
+}
+
+// inspectBinary hashes the binary and determines the platform that it
+// targets from its executable format.
+func inspectBinary(path, kind string) (NativeBinary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return NativeBinary{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return NativeBinary{}, err
+	}
+
+	binary := NativeBinary{
+		Kind:   kind,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}
+
+	if kind == "wasm" {
+		binary.Platform = "wasm32"
+		return binary, nil
+	}
+
+	binary.Platform = binaryPlatform(file)
+
+	return binary, nil
+}
+
+// binaryPlatform returns the Node.js platform and architecture (e.g.
+// "linux-x64") of an ELF, Mach-O or PE binary, or an empty string when the
+// format is not recognized.
+func binaryPlatform(file io.ReaderAt) string {
+	if f, err := elf.NewFile(file); err == nil {
+		arch := map[elf.Machine]string{
+			elf.EM_X86_64:  "x64",
+			elf.EM_386:     "ia32",
+			elf.EM_AARCH64: "arm64",
+			elf.EM_ARM:     "arm",
+			elf.EM_PPC64:   "ppc64",
+			elf.EM_S390:    "s390x",
+		}[f.Machine]
+
+		osName := "linux"
+		if f.OSABI == elf.ELFOSABI_FREEBSD {
+			osName = "freebsd"
+		}
+
+		return platformString(osName, arch)
+	}
+
+	if f, err := macho.NewFile(file); err == nil {
+		arch := map[macho.Cpu]string{
+			macho.CpuAmd64: "x64",
+			macho.CpuArm64: "arm64",
+		}[f.Cpu]
+
+		return platformString("darwin", arch)
+	}
+
+	if _, err := macho.NewFatFile(file); err == nil {
+		return "darwin-universal"
+	}
+
+	if f, err := pe.NewFile(file); err == nil {
+		arch := map[uint16]string{
+			pe.IMAGE_FILE_MACHINE_AMD64: "x64",
+			pe.IMAGE_FILE_MACHINE_I386:  "ia32",
+			pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+		}[f.Machine]
+
+		return platformString("win32", arch)
+	}
+
+	return ""
+}
+
+func platformString(osName, arch string) string {
+	if arch == "" {
+		return osName
+	}
+
+	return osName + "-" + arch
+}
+
+var (
+	// prebuildPlatformPattern matches the platform directories of prebuilds
+	// (prebuilds/linux-x64) and of node-pre-gyp (node-v108-linux-x64).
+	prebuildPlatformPattern = regexp.MustCompile(`(?:^|[/-])(linux|linuxmusl|darwin|win32|freebsd|openbsd|android|sunos|aix)-(x64|ia32|arm64|arm|ppc64|s390x|riscv64|universal)(?:$|[/.-])`)
+
+	napiPattern     = regexp.MustCompile(`(?:^|[/.-])napi(?:-v(\d+))?(?:$|[/.-])`)
+	nodeABIPattern  = regexp.MustCompile(`(?:^|[/-])node-v(\d+)(?:$|[/-])|(?:^|[/.])node\.abi(\d+)(?:$|[/.])`)
+	electronPattern = regexp.MustCompile(`electron\.abi(\d+)`)
+)
+
+// pathPlatform determines the platform of a prebuilt binary from its path
+// within the package.
+func pathPlatform(rel string) string {
+	match := prebuildPlatformPattern.FindStringSubmatch(rel)
+	if match == nil {
+		return ""
+	}
+
+	return match[1] + "-" + match[2]
+}
+
+// addonABI determines the ABI that a prebuilt native addon targets from the
+// naming conventions of prebuildify (node.napi.node, node.abi108.node) and
+// node-pre-gyp (node-v108-linux-x64, napi-v3-linux-x64).
+func addonABI(rel string) string {
+	if match := electronPattern.FindStringSubmatch(rel); match != nil {
+		return "electron-v" + match[1]
+	}
+
+	if match := nodeABIPattern.FindStringSubmatch(rel); match != nil {
+		return "node-v" + match[1] + match[2]
+	}
+
+	if match := napiPattern.FindStringSubmatch(rel); match != nil {
+		if match[1] != "" {
+			return "napi-v" + match[1]
+		}
+
+		return "napi"
+	}
+
+	return ""
+}
+
+// addNativeBinaries marks the components of installed packages that contain
+// native addons or WebAssembly modules, and adds a file component for every
+// binary. The returned edges map the bom-ref of each package to the bom-refs
+// of its binaries. The scan stops with the error of the given context once it
+// is done.
+func addNativeBinaries(ctx context.Context, workingDir string, tree InstalledTree, components []Component) ([]Component, map[string][]string, error) {
+	positions := map[string]int{}
+	for i, component := range components {
+		if component.BOMRef != "" {
+			positions[component.BOMRef] = i
+		}
+	}
+
+	edges := map[string][]string{}
+	for _, pkg := range tree.Packages {
+		native, err := scanNativePackage(ctx, workingDir, pkg)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		position, ok := positions[pkg.Ref()]
+		if !ok {
+			continue
+		}
+
+		if native.Addon && !hasProperty(components[position].Properties, NativeAddonProperty) {
+			components[position].Properties = append(components[position].Properties, Property{Name: NativeAddonProperty, Value: "true"})
+		}
+
+		if native.WASM && !hasProperty(components[position].Properties, WASMProperty) {
+			components[position].Properties = append(components[position].Properties, Property{Name: WASMProperty, Value: "true"})
+		}
+
+		for _, binary := range native.Binaries {
+			component := binary.Component()
+			components = append(components, component)
+			edges[pkg.Ref()] = append(edges[pkg.Ref()], component.BOMRef)
+		}
+	}
+
+	return components, edges, nil
+}
+
+// addDependencyEdges adds the given edges to the dependency graph, along
+// with an entry for every component that they lead to.
+func addDependencyEdges(graph []Dependency, edges map[string][]string) []Dependency {
+	positions := map[string]int{}
+	for i, dependency := range graph {
+		positions[dependency.Ref] = i
+	}
+
+	var refs []string
+	for ref := range edges {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	for _, ref := range refs {
+		position, ok := positions[ref]
+		if !ok {
+			graph = append(graph, Dependency{Ref: ref})
+			position = len(graph) - 1
+			positions[ref] = position
+		}
+
+		graph[position].DependsOn = append(graph[position].DependsOn, edges[ref]...)
+		sort.Strings(graph[position].DependsOn)
+
+		for _, dependency := range edges[ref] {
+			if _, ok := positions[dependency]; !ok {
+				graph = append(graph, Dependency{Ref: dependency})
+				positions[dependency] = len(graph) - 1
+			}
+		}
+	}
+
+	return graph
+}
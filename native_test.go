@@ -0,0 +1,54 @@
+package nodemodulebom_test
+
+import (
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testNative(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("NativeBinary", func() {
+		context("Component", func() {
+			it("returns a file component with the SHA-256 of the binary", func() {
+				binary := nodemodulebom.NativeBinary{
+					Path:     "node_modules/addon/prebuilds/linux-x64/node.napi.node",
+					Kind:     "native-addon",
+					SHA256:   "abcdef",
+					Platform: "linux-x64",
+					ABI:      "napi",
+				}
+
+				Expect(binary.Component()).To(Equal(nodemodulebom.Component{
+					BOMRef: "file:node_modules/addon/prebuilds/linux-x64/node.napi.node",
+					Type:   "file",
+					Name:   "node_modules/addon/prebuilds/linux-x64/node.napi.node",
+					Hashes: []nodemodulebom.Hash{
+						{Algorithm: "SHA-256", Content: "abcdef"},
+					},
+					Properties: []nodemodulebom.Property{
+						{Name: "paketo:node-module-bom:binary", Value: "native-addon"},
+						{Name: "paketo:node-module-bom:platform", Value: "linux-x64"},
+						{Name: "paketo:node-module-bom:abi", Value: "napi"},
+					},
+				}))
+			})
+
+			it("omits the platform and ABI when they are unknown", func() {
+				binary := nodemodulebom.NativeBinary{
+					Path:   "node_modules/addon/build/Release/addon.node",
+					Kind:   "native-addon",
+					SHA256: "abcdef",
+				}
+
+				Expect(binary.Component().Properties).To(Equal([]nodemodulebom.Property{
+					{Name: "paketo:node-module-bom:binary", Value: "native-addon"},
+				}))
+			})
+		})
+	})
+}
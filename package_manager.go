@@ -0,0 +1,241 @@
+package nodemodulebom
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// PackageManagerNPM is npm.
+	PackageManagerNPM = "npm"
+
+	// PackageManagerYarnClassic is Yarn 1.
+	PackageManagerYarnClassic = "yarn-classic"
+
+	// PackageManagerYarnBerry is Yarn 2 and later.
+	PackageManagerYarnBerry = "yarn-berry"
+
+	// PackageManagerPNPM is pnpm.
+	PackageManagerPNPM = "pnpm"
+
+	// PackageManagerBun is Bun.
+	PackageManagerBun = "bun"
+
+	// PackageManagerProperty records the package manager that installed the
+	// packages of the application, with its version when it is known.
+	PackageManagerProperty = "paketo:node-module-bom:package-manager"
+
+	// YarnLockfile is the lockfile of Yarn.
+	YarnLockfile = "yarn.lock"
+
+	// PNPMLockfile is the lockfile of pnpm.
+	PNPMLockfile = "pnpm-lock.yaml"
+
+	// BunBinaryLockfile is the binary lockfile of Bun before version 1.2.
+	BunBinaryLockfile = "bun.lockb"
+)
+
+// PackageManager is the package manager that installs the packages of a Node
+// project.
+type PackageManager struct {
+	// Name is one of npm, yarn-classic, yarn-berry, pnpm or bun.
+	Name string
+
+	// Version is the version that the "packageManager" field of package.json
+	// pins, if any.
+	Version string
+
+	// Lockfile is the path of the lockfile of the package manager relative to
+	// the project directory, if there is one.
+	Lockfile string
+
+	// Source says how the package manager was determined: "packageManager" for
+	// the field of package.json, the path of a lockfile, or "default" when the
+	// project has neither and npm is assumed.
+	Source string
+
+	// Ignored is the problem with a "packageManager" field of package.json
+	// that is unsupported or malformed, in which case the package manager was
+	// determined as if the field was not set.
+	Ignored string
+}
+
+// ErrNotNodeProject is returned by FindPackageManager for a directory that has
+// no package.json, lockfile or node_modules.
+var ErrNotNodeProject = errors.New("no package.json, lockfile or node_modules found")
+
+// packageManagerLockfiles lists the lockfiles in order of precedence, along
+// with the package manager that writes them. Yarn lockfiles are told apart by
+// their contents.
+var packageManagerLockfiles = []struct {
+	path    string
+	manager string
+}{
+	{ShrinkwrapLockfile, PackageManagerNPM},
+	{PackageLockLockfile, PackageManagerNPM},
+	{YarnLockfile, PackageManagerYarnClassic},
+	{PNPMLockfile, PackageManagerPNPM},
+	{BunLockfile, PackageManagerBun},
+	{BunBinaryLockfile, PackageManagerBun},
+}
+
+// FindPackageManager determines the package manager of the Node project in the
+// given directory. The Corepack "packageManager" field of package.json takes
+// precedence, followed by the lockfiles in the order of npm-shrinkwrap.json,
+// package-lock.json, yarn.lock, pnpm-lock.yaml, bun.lock and bun.lockb. A
+// project without either is assumed to use npm. A "packageManager" field that
+// names an unsupported package manager or cannot be parsed is ignored, and
+// the problem is recorded in the Ignored field of the result.
+// ErrNotNodeProject is returned when the directory is not a Node project.
+func FindPackageManager(projectPath string) (PackageManager, error) {
+	var pkg struct {
+		PackageManager string `json:"packageManager"`
+	}
+
+	hasPackageJSON := true
+	content, err := os.ReadFile(filepath.Join(projectPath, "package.json"))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return PackageManager{}, fmt.Errorf("failed to read package.json: %w", err)
+		}
+		hasPackageJSON = false
+	} else {
+		err = json.Unmarshal(content, &pkg)
+		if err != nil {
+			return PackageManager{}, fmt.Errorf("failed to parse package.json: %w", err)
+		}
+	}
+
+	var lockfiles []PackageManager
+	for _, lockfile := range packageManagerLockfiles {
+		_, err := os.Stat(filepath.Join(projectPath, lockfile.path))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return PackageManager{}, fmt.Errorf("failed to stat %s: %w", lockfile.path, err)
+		}
+
+		name := lockfile.manager
+		if lockfile.path == YarnLockfile {
+			name, err = yarnLockfileManager(filepath.Join(projectPath, lockfile.path))
+			if err != nil {
+				return PackageManager{}, err
+			}
+		}
+		lockfiles = append(lockfiles, PackageManager{Name: name, Lockfile: lockfile.path, Source: lockfile.path})
+	}
+
+	var ignored string
+	if pkg.PackageManager != "" {
+		manager, err := parsePackageManagerField(pkg.PackageManager)
+		if err == nil {
+			for _, lockfile := range lockfiles {
+				if lockfile.Name == manager.Name {
+					manager.Lockfile = lockfile.Lockfile
+					break
+				}
+			}
+
+			return manager, nil
+		}
+
+		ignored = err.Error()
+	}
+
+	if len(lockfiles) > 0 {
+		manager := lockfiles[0]
+		manager.Ignored = ignored
+
+		return manager, nil
+	}
+
+	if !hasPackageJSON {
+		_, err = os.Stat(filepath.Join(projectPath, "node_modules"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return PackageManager{}, ErrNotNodeProject
+			}
+
+			return PackageManager{}, fmt.Errorf("failed to stat node_modules: %w", err)
+		}
+	}
+
+	return PackageManager{Name: PackageManagerNPM, Source: "default", Ignored: ignored}, nil
+}
+
+// String returns the name of the package manager, followed by its version
+// when it is known.
+func (m PackageManager) String() string {
+	if m.Version == "" {
+		return m.Name
+	}
+
+	return m.Name + "@" + m.Version
+}
+
+// parsePackageManagerField parses a Corepack "packageManager" field such as
+// "yarn@3.6.0" or "pnpm@8.6.0+sha256.<hash>".
+func parsePackageManagerField(value string) (PackageManager, error) {
+	name, version := value, ""
+	if i := strings.LastIndex(value, "@"); i > 0 {
+		name, version = value[:i], value[i+1:]
+	}
+
+	if i := strings.Index(version, "+"); i >= 0 {
+		version = version[:i]
+	}
+
+	if version == "" {
+		return PackageManager{}, fmt.Errorf("failed to parse package.json packageManager %q: expected <name>@<version>", value)
+	}
+
+	switch name {
+	case "npm", "pnpm", "bun":
+	case "yarn":
+		name = PackageManagerYarnBerry
+		if strings.HasPrefix(version, "0.") || strings.HasPrefix(version, "1.") {
+			name = PackageManagerYarnClassic
+		}
+	default:
+		return PackageManager{}, fmt.Errorf("failed to parse package.json packageManager %q: unsupported package manager %q", value, name)
+	}
+
+	return PackageManager{Name: name, Version: version, Source: "packageManager"}, nil
+}
+
+// yarnLockfileManager tells the yarn.lock of Yarn 1, which starts with a
+// "# yarn lockfile v1" comment, apart from the YAML lockfile of later
+// versions, which has a __metadata entry.
+func yarnLockfileManager(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", YarnLockfile, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "# yarn lockfile v1" {
+			return PackageManagerYarnClassic, nil
+		}
+
+		if line == "__metadata:" {
+			return PackageManagerYarnBerry, nil
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", YarnLockfile, err)
+	}
+
+	return PackageManagerYarnClassic, nil
+}
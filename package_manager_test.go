@@ -0,0 +1,146 @@
+package nodemodulebom_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPackageManager(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"name": "some-app"}`), 0600)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("FindPackageManager", func() {
+		it("assumes npm without a packageManager field or lockfile", func() {
+			manager, err := nodemodulebom.FindPackageManager(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manager).To(Equal(nodemodulebom.PackageManager{Name: "npm", Source: "default"}))
+		})
+
+		it("tells the package manager from the lockfile", func() {
+			for lockfile, name := range map[string]string{
+				"package-lock.json": "npm",
+				"pnpm-lock.yaml":    "pnpm",
+				"bun.lock":          "bun",
+				"bun.lockb":         "bun",
+			} {
+				path := filepath.Join(workingDir, lockfile)
+				Expect(os.WriteFile(path, []byte("{}"), 0600)).To(Succeed())
+
+				manager, err := nodemodulebom.FindPackageManager(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manager).To(Equal(nodemodulebom.PackageManager{Name: name, Lockfile: lockfile, Source: lockfile}))
+
+				Expect(os.Remove(path)).To(Succeed())
+			}
+		})
+
+		it("tells Yarn 1 apart from later versions by the yarn.lock", func() {
+			path := filepath.Join(workingDir, "yarn.lock")
+			Expect(os.WriteFile(path, []byte("# THIS IS AN AUTOGENERATED FILE.\n# yarn lockfile v1\n\nleftpad@^1.0.0:\n  version \"1.0.0\"\n"), 0600)).To(Succeed())
+
+			manager, err := nodemodulebom.FindPackageManager(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manager.Name).To(Equal("yarn-classic"))
+
+			Expect(os.WriteFile(path, []byte("# This file is generated by running \"yarn install\".\n\n__metadata:\n  version: 6\n"), 0600)).To(Succeed())
+
+			manager, err = nodemodulebom.FindPackageManager(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manager.Name).To(Equal("yarn-berry"))
+		})
+
+		it("prefers the packageManager field over the lockfiles", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"packageManager": "pnpm@8.6.0+sha256.abc"}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), []byte("{}"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-lock.yaml"), []byte("lockfileVersion: '6.0'\n"), 0600)).To(Succeed())
+
+			manager, err := nodemodulebom.FindPackageManager(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manager).To(Equal(nodemodulebom.PackageManager{Name: "pnpm", Version: "8.6.0", Lockfile: "pnpm-lock.yaml", Source: "packageManager"}))
+			Expect(manager.String()).To(Equal("pnpm@8.6.0"))
+		})
+
+		it("reads the major version of Yarn from the packageManager field", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"packageManager": "yarn@1.22.19"}`), 0600)).To(Succeed())
+
+			manager, err := nodemodulebom.FindPackageManager(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manager).To(Equal(nodemodulebom.PackageManager{Name: "yarn-classic", Version: "1.22.19", Source: "packageManager"}))
+		})
+
+		it("treats a directory with only node_modules as an npm project", func() {
+			Expect(os.Remove(filepath.Join(workingDir, "package.json"))).To(Succeed())
+			Expect(os.Mkdir(filepath.Join(workingDir, "node_modules"), os.ModePerm)).To(Succeed())
+
+			manager, err := nodemodulebom.FindPackageManager(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manager.Name).To(Equal("npm"))
+		})
+
+		context("when the packageManager field cannot be used", func() {
+			it("falls back to the lockfiles when it has no version", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"packageManager": "pnpm"}`), 0600)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-lock.yaml"), []byte("lockfileVersion: '6.0'\n"), 0600)).To(Succeed())
+
+				manager, err := nodemodulebom.FindPackageManager(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manager).To(Equal(nodemodulebom.PackageManager{
+					Name:     "pnpm",
+					Lockfile: "pnpm-lock.yaml",
+					Source:   "pnpm-lock.yaml",
+					Ignored:  `failed to parse package.json packageManager "pnpm": expected <name>@<version>`,
+				}))
+			})
+
+			it("assumes npm when it is unsupported and there is no lockfile", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"packageManager": "deno@1.0.0"}`), 0600)).To(Succeed())
+
+				manager, err := nodemodulebom.FindPackageManager(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manager).To(Equal(nodemodulebom.PackageManager{
+					Name:    "npm",
+					Source:  "default",
+					Ignored: `failed to parse package.json packageManager "deno@1.0.0": unsupported package manager "deno"`,
+				}))
+			})
+		})
+
+		context("failure cases", func() {
+			it("returns ErrNotNodeProject without a package.json, lockfile or node_modules", func() {
+				Expect(os.Remove(filepath.Join(workingDir, "package.json"))).To(Succeed())
+
+				_, err := nodemodulebom.FindPackageManager(workingDir)
+				Expect(err).To(MatchError(nodemodulebom.ErrNotNodeProject))
+			})
+
+			it("returns an error when the package.json is malformed", func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{`), 0600)).To(Succeed())
+
+				_, err := nodemodulebom.FindPackageManager(workingDir)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse package.json")))
+			})
+
+		})
+	})
+}
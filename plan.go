@@ -0,0 +1,162 @@
+package nodemodulebom
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2"
+)
+
+const (
+	// PlanEntryName is the name of the build plan entry that the buildpack
+	// provides. Buildpacks that require it can configure the SBOM through the
+	// metadata of their requirement.
+	PlanEntryName = "node-module-bom"
+
+	// FormatCycloneDX names the CycloneDX SBOM format in the build plan.
+	FormatCycloneDX = "cyclonedx"
+
+	// FormatSPDX names the SPDX SBOM format in the build plan.
+	FormatSPDX = "spdx"
+)
+
+// formatExtensions maps the SBOM formats of the build plan to the extension
+// of the SBOM file of the format.
+var formatExtensions = map[string]string{
+	FormatCycloneDX: "cdx.json",
+	FormatSPDX:      "spdx.json",
+}
+
+// PlanConfig is the SBOM configuration that the node-module-bom requirements
+// of the build plan ask for.
+type PlanConfig struct {
+	// Formats lists the SBOM formats to write, "cyclonedx" and "spdx". Every
+	// format is written when it is empty.
+	Formats []string
+
+	// Launch is false when no requirement needs the SBOM of the launch image,
+	// in which case only the build SBOM is written.
+	Launch bool
+
+	// ProjectPaths lists the directories, or globs of directories, of the Node
+	// projects relative to the working directory, as
+	// BP_NODE_MODULE_BOM_PROJECT_PATHS does.
+	ProjectPaths []string
+}
+
+// ReadPlanConfig merges the metadata of the node-module-bom entries of the
+// build plan. The formats and project paths of the entries are combined, and
+// the launch SBOM is written unless every entry that sets "launch" sets it to
+// false. Entries can give the formats and project paths as a list or as a comma
+// separated string.
+func ReadPlanConfig(entries []packit.BuildpackPlanEntry) (PlanConfig, error) {
+	var (
+		config       PlanConfig
+		launchSet    bool
+		launchWanted bool
+	)
+	formats := map[string]bool{}
+	for _, entry := range entries {
+		if entry.Name != PlanEntryName {
+			continue
+		}
+
+		values, err := planStrings(entry.Metadata, "formats")
+		if err != nil {
+			return PlanConfig{}, err
+		}
+
+		for _, format := range values {
+			format = strings.ToLower(format)
+			if _, ok := formatExtensions[format]; !ok {
+				return PlanConfig{}, fmt.Errorf("failed to parse %s build plan metadata formats: unknown format %q, must be %q or %q", PlanEntryName, format, FormatCycloneDX, FormatSPDX)
+			}
+			formats[format] = true
+		}
+
+		if value, ok := entry.Metadata["launch"]; ok {
+			launch, ok := value.(bool)
+			if !ok {
+				return PlanConfig{}, fmt.Errorf("failed to parse %s build plan metadata launch: expected a boolean, got %v", PlanEntryName, value)
+			}
+			launchSet = true
+			launchWanted = launchWanted || launch
+		}
+
+		values, err = planStrings(entry.Metadata, "project-paths")
+		if err != nil {
+			return PlanConfig{}, err
+		}
+
+		for _, path := range values {
+			if !containsString(config.ProjectPaths, path) {
+				config.ProjectPaths = append(config.ProjectPaths, path)
+			}
+		}
+	}
+
+	for format := range formats {
+		config.Formats = append(config.Formats, format)
+	}
+	sort.Strings(config.Formats)
+
+	config.Launch = !launchSet || launchWanted
+
+	return config, nil
+}
+
+// Select returns the SBOM files of the formats of the configuration.
+func (c PlanConfig) Select(formats packit.SBOMFormats) packit.SBOMFormats {
+	if len(c.Formats) == 0 {
+		return formats
+	}
+
+	var selected packit.SBOMFormats
+	for _, format := range formats {
+		for _, name := range c.Formats {
+			if format.Extension == formatExtensions[name] {
+				selected = append(selected, format)
+			}
+		}
+	}
+
+	return selected
+}
+
+// planStrings returns the strings of the given metadata field, which is a
+// list of strings or a comma separated string.
+func planStrings(metadata map[string]interface{}, key string) ([]string, error) {
+	value, ok := metadata[key]
+	if !ok {
+		return nil, nil
+	}
+
+	var values []string
+	switch v := value.(type) {
+	case string:
+		values = strings.Split(v, ",")
+	case []string:
+		values = v
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("failed to parse %s build plan metadata %s: expected a list of strings, got %v", PlanEntryName, key, value)
+			}
+			values = append(values, s)
+		}
+	default:
+		return nil, fmt.Errorf("failed to parse %s build plan metadata %s: expected a list of strings, got %v", PlanEntryName, key, value)
+	}
+
+	var trimmed []string
+	for _, s := range values {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			trimmed = append(trimmed, s)
+		}
+	}
+
+	return trimmed, nil
+}
@@ -0,0 +1,106 @@
+package nodemodulebom_test
+
+import (
+	"strings"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/paketo-buildpacks/packit/v2"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPlan(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("ReadPlanConfig", func() {
+		it("writes every format of the launch SBOM without node-module-bom metadata", func() {
+			config, err := nodemodulebom.ReadPlanConfig([]packit.BuildpackPlanEntry{
+				{Name: "node-module-bom"},
+				{Name: "node", Metadata: map[string]interface{}{"launch": false}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(nodemodulebom.PlanConfig{Launch: true}))
+		})
+
+		it("merges the metadata of every node-module-bom entry", func() {
+			config, err := nodemodulebom.ReadPlanConfig([]packit.BuildpackPlanEntry{
+				{
+					Name: "node-module-bom",
+					Metadata: map[string]interface{}{
+						"formats":       []interface{}{"SPDX"},
+						"launch":        false,
+						"project-paths": []interface{}{"apps/*"},
+					},
+				},
+				{
+					Name: "node-module-bom",
+					Metadata: map[string]interface{}{
+						"formats":       "cyclonedx, spdx",
+						"project-paths": "apps/*,tools/cli",
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(nodemodulebom.PlanConfig{
+				Formats:      []string{"cyclonedx", "spdx"},
+				Launch:       false,
+				ProjectPaths: []string{"apps/*", "tools/cli"},
+			}))
+		})
+
+		it("writes the launch SBOM when any entry requires it", func() {
+			config, err := nodemodulebom.ReadPlanConfig([]packit.BuildpackPlanEntry{
+				{Name: "node-module-bom", Metadata: map[string]interface{}{"launch": false}},
+				{Name: "node-module-bom", Metadata: map[string]interface{}{"launch": true}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.Launch).To(BeTrue())
+		})
+
+		context("failure cases", func() {
+			it("returns an error when a format is unknown", func() {
+				_, err := nodemodulebom.ReadPlanConfig([]packit.BuildpackPlanEntry{
+					{Name: "node-module-bom", Metadata: map[string]interface{}{"formats": []interface{}{"cyclonedx", "syft"}}},
+				})
+				Expect(err).To(MatchError(`failed to parse node-module-bom build plan metadata formats: unknown format "syft", must be "cyclonedx" or "spdx"`))
+			})
+
+			it("returns an error when launch is not a boolean", func() {
+				_, err := nodemodulebom.ReadPlanConfig([]packit.BuildpackPlanEntry{
+					{Name: "node-module-bom", Metadata: map[string]interface{}{"launch": "no"}},
+				})
+				Expect(err).To(MatchError("failed to parse node-module-bom build plan metadata launch: expected a boolean, got no"))
+			})
+
+			it("returns an error when the project paths are not strings", func() {
+				_, err := nodemodulebom.ReadPlanConfig([]packit.BuildpackPlanEntry{
+					{Name: "node-module-bom", Metadata: map[string]interface{}{"project-paths": []interface{}{"api", 1}}},
+				})
+				Expect(err).To(MatchError("failed to parse node-module-bom build plan metadata project-paths: expected a list of strings, got [api 1]"))
+			})
+		})
+	})
+
+	context("Select", func() {
+		var formats packit.SBOMFormats
+
+		it.Before(func() {
+			formats = packit.SBOMFormats{
+				{Extension: "cdx.json", Content: strings.NewReader("{}")},
+				{Extension: "spdx.json", Content: strings.NewReader("{}")},
+			}
+		})
+
+		it("returns the SBOM files of the formats of the configuration", func() {
+			selected := nodemodulebom.PlanConfig{Formats: []string{"spdx"}}.Select(formats)
+			Expect(selected).To(HaveLen(1))
+			Expect(selected[0].Extension).To(Equal("spdx.json"))
+		})
+
+		it("returns every SBOM file without formats", func() {
+			Expect(nodemodulebom.PlanConfig{}.Select(formats)).To(Equal(formats))
+		})
+	})
+}
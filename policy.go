@@ -0,0 +1,234 @@
+package nodemodulebom
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// LicensePolicy is a policy file that restricts the licenses of the
+// components of the SBOM. A component violates the policy when one of its
+// licenses is denied, or, when the policy has an allow list, when its licenses
+// are not allowed. A component whose license is an SPDX expression with "OR"
+// only needs one of the alternatives to be allowed, and only violates a deny
+// list when all of the alternatives are denied.
+type LicensePolicy struct {
+	// Path is the path of the policy file relative to the application.
+	Path string `toml:"-"`
+
+	Allow []string `toml:"allow-licenses"`
+	Deny  []string `toml:"deny-licenses"`
+}
+
+// PolicyViolation is a component that violates a LicensePolicy.
+type PolicyViolation struct {
+	BOMRef   string
+	Name     string
+	Version  string
+	Licenses []string
+	Policy   string
+	Reason   string
+}
+
+// LoadLicensePolicy reads the LicensePolicy from the given file. The path is
+// the path of the file relative to the application, which errors refer to.
+func LoadLicensePolicy(file, path string) (LicensePolicy, error) {
+	var policy LicensePolicy
+	metadata, err := toml.DecodeFile(file, &policy)
+	if err != nil {
+		return LicensePolicy{}, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+	}
+
+	if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+		return LicensePolicy{}, fmt.Errorf("failed to parse policy file %s: unknown key %q", path, undecoded[0].String())
+	}
+
+	for _, list := range []struct {
+		key      string
+		licenses []string
+	}{
+		{"allow-licenses", policy.Allow},
+		{"deny-licenses", policy.Deny},
+	} {
+		for _, license := range list.licenses {
+			if strings.TrimSpace(license) == "" {
+				return LicensePolicy{}, fmt.Errorf("failed to parse policy file %s key %s: licenses must not be empty", path, list.key)
+			}
+		}
+	}
+
+	policy.Path = path
+
+	return policy, nil
+}
+
+// Evaluate returns the components of the BOM that violate the policy. File
+// components are not evaluated, as they are covered by the license of the
+// package that ships them.
+func (p LicensePolicy) Evaluate(bom BOM) []PolicyViolation {
+	var violations []PolicyViolation
+	for _, component := range bom.Components {
+		if component.Type == "file" {
+			continue
+		}
+
+		expression := componentLicenseExpression(component)
+		ids := licenseIDs(expression)
+
+		reason := ""
+		if denied := p.denied(expression); denied != "" {
+			reason = fmt.Sprintf("license %s is denied", denied)
+		}
+
+		if reason == "" && len(p.Allow) > 0 {
+			switch {
+			case len(ids) == 0:
+				reason = "license is unknown"
+			case !p.allowed(expression):
+				reason = "license is not allowed"
+			}
+		}
+
+		if reason != "" {
+			violations = append(violations, PolicyViolation{
+				BOMRef:   component.BOMRef,
+				Name:     component.PackageName(),
+				Version:  component.Version,
+				Licenses: ids,
+				Policy:   p.Path,
+				Reason:   reason,
+			})
+		}
+	}
+
+	return violations
+}
+
+// denied returns a denied license that the expression cannot do without: a
+// denied operand of an "AND" expression, or the first alternative of an "OR"
+// expression whose alternatives are all denied. It is empty when the
+// expression can be satisfied without a denied license.
+func (p LicensePolicy) denied(expression licenseExpression) string {
+	switch expression.Operator {
+	case "":
+		if containsLicense(p.Deny, expression.License) {
+			return expression.License
+		}
+
+		return ""
+
+	case "OR":
+		var first string
+		for _, operand := range expression.Operands {
+			denied := p.denied(operand)
+			if denied == "" {
+				return ""
+			}
+
+			if first == "" {
+				first = denied
+			}
+		}
+
+		return first
+
+	default:
+		for _, operand := range expression.Operands {
+			if denied := p.denied(operand); denied != "" {
+				return denied
+			}
+		}
+
+		return ""
+	}
+}
+
+// allowed returns whether the expression can be satisfied with allowed
+// licenses only: every operand of an "AND" expression, and one alternative of
+// an "OR" expression.
+func (p LicensePolicy) allowed(expression licenseExpression) bool {
+	switch expression.Operator {
+	case "":
+		return containsLicense(p.Allow, expression.License)
+
+	case "OR":
+		for _, operand := range expression.Operands {
+			if p.allowed(operand) {
+				return true
+			}
+		}
+
+		return false
+
+	default:
+		for _, operand := range expression.Operands {
+			if !p.allowed(operand) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// componentLicenseExpression returns the licenses of a component as a single
+// expression, the conjunction of its license entries. An entry that is not a
+// valid SPDX expression, such as a license name, is a license of its own.
+func componentLicenseExpression(component Component) licenseExpression {
+	var operands []licenseExpression
+	for _, choice := range component.Licenses {
+		value := choice.value()
+
+		expression, err := parseLicenseExpression(value)
+		if err != nil {
+			expression = licenseExpression{License: strings.TrimSpace(value)}
+		}
+
+		operands = append(operands, expression)
+	}
+
+	if len(operands) == 1 {
+		return operands[0]
+	}
+
+	return licenseExpression{Operator: "AND", Operands: operands}
+}
+
+// licenseIDs returns the sorted license identifiers of an expression, without
+// the exceptions of "WITH" expressions.
+func licenseIDs(expression licenseExpression) []string {
+	var ids []string
+	seen := map[string]bool{}
+
+	var visit func(expression licenseExpression)
+	visit = func(expression licenseExpression) {
+		if expression.Operator == "" {
+			if expression.License != "" && !seen[expression.License] {
+				seen[expression.License] = true
+				ids = append(ids, expression.License)
+			}
+
+			return
+		}
+
+		for _, operand := range expression.Operands {
+			visit(operand)
+		}
+	}
+	visit(expression)
+	sort.Strings(ids)
+
+	return ids
+}
+
+func containsLicense(licenses []string, id string) bool {
+	for _, license := range licenses {
+		if strings.EqualFold(license, id) {
+			return true
+		}
+	}
+
+	return false
+}
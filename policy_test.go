@@ -0,0 +1,109 @@
+package nodemodulebom_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPolicy(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("LoadLicensePolicy", func() {
+		var dir string
+
+		it.Before(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "policies")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		it("reads the allowed and denied licenses", func() {
+			path := filepath.Join(dir, "licenses.toml")
+			Expect(os.WriteFile(path, []byte("allow-licenses = [\"MIT\", \"ISC\"]\ndeny-licenses = [\"GPL-3.0-only\"]\n"), 0600)).To(Succeed())
+
+			policy, err := nodemodulebom.LoadLicensePolicy(path, "policies/licenses.toml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy).To(Equal(nodemodulebom.LicensePolicy{
+				Path:  "policies/licenses.toml",
+				Allow: []string{"MIT", "ISC"},
+				Deny:  []string{"GPL-3.0-only"},
+			}))
+		})
+
+		context("failure cases", func() {
+			it("returns an error for an unknown key", func() {
+				path := filepath.Join(dir, "licenses.toml")
+				Expect(os.WriteFile(path, []byte(`denied = ["GPL-3.0-only"]`), 0600)).To(Succeed())
+
+				_, err := nodemodulebom.LoadLicensePolicy(path, "policies/licenses.toml")
+				Expect(err).To(MatchError(`failed to parse policy file policies/licenses.toml: unknown key "denied"`))
+			})
+
+			it("returns an error for an empty license", func() {
+				path := filepath.Join(dir, "licenses.toml")
+				Expect(os.WriteFile(path, []byte(`allow-licenses = [""]`), 0600)).To(Succeed())
+
+				_, err := nodemodulebom.LoadLicensePolicy(path, "policies/licenses.toml")
+				Expect(err).To(MatchError("failed to parse policy file policies/licenses.toml key allow-licenses: licenses must not be empty"))
+			})
+		})
+	})
+
+	context("Evaluate", func() {
+		var bom nodemodulebom.BOM
+
+		it.Before(func() {
+			bom = nodemodulebom.BOM{
+				Components: []nodemodulebom.Component{
+					{BOMRef: "pkg:npm/leftpad@1.0.0", Type: "library", Name: "leftpad", Version: "1.0.0", Licenses: []nodemodulebom.LicenseChoice{{License: &nodemodulebom.License{ID: "MIT"}}}},
+					{BOMRef: "pkg:npm/copyleft@1.0.0", Type: "library", Name: "copyleft", Version: "1.0.0", Licenses: []nodemodulebom.LicenseChoice{{License: &nodemodulebom.License{ID: "GPL-3.0-only"}}}},
+					{BOMRef: "pkg:npm/dual@1.0.0", Type: "library", Name: "dual", Version: "1.0.0", Licenses: []nodemodulebom.LicenseChoice{{Expression: "(GPL-3.0-only OR MIT)"}}},
+					{BOMRef: "pkg:npm/exception@1.0.0", Type: "library", Name: "exception", Version: "1.0.0", Licenses: []nodemodulebom.LicenseChoice{{Expression: "Apache-2.0 WITH LLVM-exception"}}},
+					{BOMRef: "pkg:npm/unlicensed@1.0.0", Type: "library", Name: "unlicensed", Version: "1.0.0"},
+					{BOMRef: "file:node_modules/copyleft/addon.node", Type: "file", Name: "node_modules/copyleft/addon.node"},
+				},
+			}
+		})
+
+		it("returns the components with a denied license", func() {
+			violations := nodemodulebom.LicensePolicy{Path: "licenses.toml", Deny: []string{"gpl-3.0-only"}}.Evaluate(bom)
+			Expect(violations).To(Equal([]nodemodulebom.PolicyViolation{
+				{BOMRef: "pkg:npm/copyleft@1.0.0", Name: "copyleft", Version: "1.0.0", Licenses: []string{"GPL-3.0-only"}, Policy: "licenses.toml", Reason: "license GPL-3.0-only is denied"},
+			}))
+		})
+
+		it("returns the components whose alternative licenses are all denied", func() {
+			violations := nodemodulebom.LicensePolicy{Path: "licenses.toml", Deny: []string{"GPL-3.0-only", "MIT"}}.Evaluate(bom)
+			Expect(violations).To(Equal([]nodemodulebom.PolicyViolation{
+				{BOMRef: "pkg:npm/leftpad@1.0.0", Name: "leftpad", Version: "1.0.0", Licenses: []string{"MIT"}, Policy: "licenses.toml", Reason: "license MIT is denied"},
+				{BOMRef: "pkg:npm/copyleft@1.0.0", Name: "copyleft", Version: "1.0.0", Licenses: []string{"GPL-3.0-only"}, Policy: "licenses.toml", Reason: "license GPL-3.0-only is denied"},
+				{BOMRef: "pkg:npm/dual@1.0.0", Name: "dual", Version: "1.0.0", Licenses: []string{"GPL-3.0-only", "MIT"}, Policy: "licenses.toml", Reason: "license GPL-3.0-only is denied"},
+			}))
+		})
+
+		it("returns the components with a denied license of an AND expression", func() {
+			bom.Components = append(bom.Components, nodemodulebom.Component{BOMRef: "pkg:npm/both@1.0.0", Type: "library", Name: "both", Version: "1.0.0", Licenses: []nodemodulebom.LicenseChoice{{Expression: "MIT AND (GPL-3.0-only OR GPL-2.0-only)"}}})
+
+			violations := nodemodulebom.LicensePolicy{Path: "licenses.toml", Deny: []string{"GPL-3.0-only", "GPL-2.0-only"}}.Evaluate(bom)
+			Expect(violations).To(ContainElement(nodemodulebom.PolicyViolation{BOMRef: "pkg:npm/both@1.0.0", Name: "both", Version: "1.0.0", Licenses: []string{"GPL-2.0-only", "GPL-3.0-only", "MIT"}, Policy: "licenses.toml", Reason: "license GPL-3.0-only is denied"}))
+		})
+
+		it("returns the components whose licenses are not allowed", func() {
+			violations := nodemodulebom.LicensePolicy{Path: "licenses.toml", Allow: []string{"MIT", "Apache-2.0"}}.Evaluate(bom)
+			Expect(violations).To(Equal([]nodemodulebom.PolicyViolation{
+				{BOMRef: "pkg:npm/copyleft@1.0.0", Name: "copyleft", Version: "1.0.0", Licenses: []string{"GPL-3.0-only"}, Policy: "licenses.toml", Reason: "license is not allowed"},
+				{BOMRef: "pkg:npm/unlicensed@1.0.0", Name: "unlicensed", Version: "1.0.0", Policy: "licenses.toml", Reason: "license is unknown"},
+			}))
+		})
+	})
+}
@@ -0,0 +1,191 @@
+package nodemodulebom
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// ProjectPathEnv names the environment variable that points to the Node
+	// project inside of the application source directory, as it does for the
+	// other Node.js buildpacks.
+	ProjectPathEnv = "BP_NODE_PROJECT_PATH"
+
+	// ProjectPathsEnv names the environment variable that lists the
+	// directories, or globs of directories, of several Node projects inside
+	// of the application source directory, separated by colons or commas.
+	ProjectPathsEnv = "BP_NODE_MODULE_BOM_PROJECT_PATHS"
+)
+
+// FindProjectPath returns the directory of the Node project in the given
+// working directory. It is the working directory itself unless
+// BP_NODE_PROJECT_PATH names a subdirectory of it.
+func FindProjectPath(workingDir string) (string, error) {
+	value, ok := os.LookupEnv(ProjectPathEnv)
+	if !ok || value == "" {
+		return workingDir, nil
+	}
+
+	return projectDir(workingDir, ProjectPathEnv, value)
+}
+
+// FindProjectPaths returns the directories of the Node projects in the given
+// working directory. BP_NODE_MODULE_BOM_PROJECT_PATHS lists the directories
+// (see ResolveProjectPaths). Without it, the single project of
+// FindProjectPath is returned.
+func FindProjectPaths(workingDir string) ([]string, error) {
+	value := strings.TrimSpace(os.Getenv(ProjectPathsEnv))
+	if value == "" {
+		path, err := FindProjectPath(workingDir)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{path}, nil
+	}
+
+	return ResolveProjectPaths(workingDir, ProjectPathsEnv, strings.FieldsFunc(value, func(r rune) bool { return r == ':' || r == ',' }))
+}
+
+// LoadProjectPaths returns the directories of the Node projects in the given
+// working directory, in the same way for detection and build. They are listed
+// by BP_NODE_MODULE_BOM_PROJECT_PATHS, then by BP_NODE_PROJECT_PATH, then by
+// the project-paths of the configuration, then by the given project-paths of
+// the build plan, and are otherwise the working directory itself.
+func LoadProjectPaths(workingDir string, config Config, planPaths []string) ([]string, error) {
+	_, ok := os.LookupEnv(ProjectPathsEnv)
+	if !ok {
+		ok = os.Getenv(ProjectPathEnv) != ""
+	}
+
+	switch {
+	case !ok && len(config.ProjectPaths) > 0:
+		return ResolveProjectPaths(workingDir, config.Sources["project-paths"], config.ProjectPaths)
+	case !ok && len(planPaths) > 0:
+		return ResolveProjectPaths(workingDir, PlanEntryName+" build plan project-paths", planPaths)
+	default:
+		return FindProjectPaths(workingDir)
+	}
+}
+
+// ResolveProjectPaths returns the directories of the Node projects that the
+// given entries, relative to the working directory, name. Entries containing
+// glob characters (including "**") match every subdirectory with a
+// package.json outside of node_modules. The source names where the entries
+// come from in errors.
+func ResolveProjectPaths(workingDir, source string, entries []string) ([]string, error) {
+	var paths []string
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		var matches []string
+		if strings.ContainsAny(entry, "*?[") {
+			var err error
+			matches, err = globProjects(workingDir, entry)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			path, err := projectDir(workingDir, source, entry)
+			if err != nil {
+				return nil, err
+			}
+			matches = []string{path}
+		}
+
+		for _, path := range matches {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("expected value derived from %s [%s] to match at least one directory with a package.json", source, strings.Join(entries, ":"))
+	}
+
+	return paths, nil
+}
+
+func projectDir(workingDir, env, value string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(value))
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("expected value derived from %s [%s] to be a directory inside of the application", env, value)
+	}
+
+	path := filepath.Join(workingDir, rel)
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("expected value derived from %s [%s] to be an existing directory", env, value)
+		}
+
+		return "", fmt.Errorf("failed to stat %s: %w", env, err)
+	}
+
+	if !info.IsDir() {
+		return "", fmt.Errorf("expected value derived from %s [%s] to be an existing directory", env, value)
+	}
+
+	return path, nil
+}
+
+// globProjects returns the subdirectories that match the glob and contain a
+// package.json, in lexical order. The working directory itself is only a
+// project when it is listed explicitly, as ".".
+func globProjects(workingDir, pattern string) ([]string, error) {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(pattern), "./"), "/")
+
+	var paths []string
+	err := filepath.WalkDir(workingDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() || path == workingDir {
+			return nil
+		}
+
+		if entry.Name() == "node_modules" || strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(workingDir, path)
+		if err != nil {
+			return err
+		}
+
+		if !matchGlob(pattern, filepath.ToSlash(rel)) {
+			return nil
+		}
+
+		_, err = os.Stat(filepath.Join(path, "package.json"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find project directories: %w", err)
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
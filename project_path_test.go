@@ -0,0 +1,183 @@
+package nodemodulebom_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testProjectPath(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(workingDir, "apps", "api"), os.ModePerm)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{}`), 0600)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.Unsetenv("BP_NODE_PROJECT_PATH")).To(Succeed())
+		Expect(os.Unsetenv("BP_NODE_MODULE_BOM_PROJECT_PATHS")).To(Succeed())
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("FindProjectPath", func() {
+		it("returns the working directory when BP_NODE_PROJECT_PATH is not set", func() {
+			path, err := nodemodulebom.FindProjectPath(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path).To(Equal(workingDir))
+		})
+
+		it("returns the directory that BP_NODE_PROJECT_PATH points to", func() {
+			Expect(os.Setenv("BP_NODE_PROJECT_PATH", "./apps/api/")).To(Succeed())
+
+			path, err := nodemodulebom.FindProjectPath(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path).To(Equal(filepath.Join(workingDir, "apps", "api")))
+		})
+
+		context("failure cases", func() {
+			it("returns an error when the directory does not exist", func() {
+				Expect(os.Setenv("BP_NODE_PROJECT_PATH", "apps/worker")).To(Succeed())
+
+				_, err := nodemodulebom.FindProjectPath(workingDir)
+				Expect(err).To(MatchError("expected value derived from BP_NODE_PROJECT_PATH [apps/worker] to be an existing directory"))
+			})
+
+			it("returns an error when the path is not a directory", func() {
+				Expect(os.Setenv("BP_NODE_PROJECT_PATH", "package.json")).To(Succeed())
+
+				_, err := nodemodulebom.FindProjectPath(workingDir)
+				Expect(err).To(MatchError("expected value derived from BP_NODE_PROJECT_PATH [package.json] to be an existing directory"))
+			})
+
+			it("returns an error when the path leaves the application", func() {
+				Expect(os.Setenv("BP_NODE_PROJECT_PATH", "../elsewhere")).To(Succeed())
+
+				_, err := nodemodulebom.FindProjectPath(workingDir)
+				Expect(err).To(MatchError("expected value derived from BP_NODE_PROJECT_PATH [../elsewhere] to be a directory inside of the application"))
+			})
+		})
+	})
+
+	context("FindProjectPaths", func() {
+		it.Before(func() {
+			for _, dir := range []string{"apps/api", "apps/worker", "apps/docs", "apps/api/node_modules/dep", "tools/cli"} {
+				Expect(os.MkdirAll(filepath.Join(workingDir, dir), os.ModePerm)).To(Succeed())
+			}
+
+			for _, dir := range []string{"apps/api", "apps/worker", "apps/api/node_modules/dep", "tools/cli"} {
+				Expect(os.WriteFile(filepath.Join(workingDir, dir, "package.json"), []byte(`{}`), 0600)).To(Succeed())
+			}
+		})
+
+		it("returns the single project when BP_NODE_MODULE_BOM_PROJECT_PATHS is not set", func() {
+			Expect(os.Setenv("BP_NODE_PROJECT_PATH", "apps/api")).To(Succeed())
+
+			paths, err := nodemodulebom.FindProjectPaths(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{filepath.Join(workingDir, "apps", "api")}))
+		})
+
+		it("returns the listed directories and the directories with a package.json that match the globs", func() {
+			Expect(os.Setenv("BP_NODE_MODULE_BOM_PROJECT_PATHS", "tools/cli, apps/*:apps/api")).To(Succeed())
+
+			paths, err := nodemodulebom.FindProjectPaths(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{
+				filepath.Join(workingDir, "tools", "cli"),
+				filepath.Join(workingDir, "apps", "api"),
+				filepath.Join(workingDir, "apps", "worker"),
+			}))
+		})
+
+		it("matches any depth with **, outside of node_modules and including the application only when listed", func() {
+			Expect(os.Setenv("BP_NODE_MODULE_BOM_PROJECT_PATHS", ".:**")).To(Succeed())
+
+			paths, err := nodemodulebom.FindProjectPaths(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{
+				workingDir,
+				filepath.Join(workingDir, "apps", "api"),
+				filepath.Join(workingDir, "apps", "worker"),
+				filepath.Join(workingDir, "tools", "cli"),
+			}))
+		})
+
+		context("failure cases", func() {
+			it("returns an error when a listed directory does not exist", func() {
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_PROJECT_PATHS", "apps/api:apps/missing")).To(Succeed())
+
+				_, err := nodemodulebom.FindProjectPaths(workingDir)
+				Expect(err).To(MatchError("expected value derived from BP_NODE_MODULE_BOM_PROJECT_PATHS [apps/missing] to be an existing directory"))
+			})
+
+			it("returns an error when nothing matches", func() {
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_PROJECT_PATHS", "services/*")).To(Succeed())
+
+				_, err := nodemodulebom.FindProjectPaths(workingDir)
+				Expect(err).To(MatchError("expected value derived from BP_NODE_MODULE_BOM_PROJECT_PATHS [services/*] to match at least one directory with a package.json"))
+			})
+		})
+	})
+
+	context("LoadProjectPaths", func() {
+		var config nodemodulebom.Config
+
+		it.Before(func() {
+			for _, dir := range []string{"apps/api", "apps/worker"} {
+				Expect(os.MkdirAll(filepath.Join(workingDir, dir), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, dir, "package.json"), []byte(`{}`), 0600)).To(Succeed())
+			}
+
+			config = nodemodulebom.Config{
+				ProjectPaths: []string{"apps/worker"},
+				Sources:      map[string]string{"project-paths": ".node-module-bom.toml key project-paths"},
+			}
+		})
+
+		it("prefers the configuration over the build plan", func() {
+			paths, err := nodemodulebom.LoadProjectPaths(workingDir, config, []string{"apps/api"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{filepath.Join(workingDir, "apps", "worker")}))
+		})
+
+		it("uses the build plan without a configuration", func() {
+			paths, err := nodemodulebom.LoadProjectPaths(workingDir, nodemodulebom.Config{}, []string{"apps/api"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{filepath.Join(workingDir, "apps", "api")}))
+		})
+
+		it("prefers BP_NODE_PROJECT_PATH over the configuration and the build plan", func() {
+			Expect(os.Setenv("BP_NODE_PROJECT_PATH", "apps/api")).To(Succeed())
+
+			paths, err := nodemodulebom.LoadProjectPaths(workingDir, config, []string{"apps/worker"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{filepath.Join(workingDir, "apps", "api")}))
+		})
+
+		it("prefers BP_NODE_MODULE_BOM_PROJECT_PATHS over BP_NODE_PROJECT_PATH and the configuration", func() {
+			Expect(os.Setenv("BP_NODE_PROJECT_PATH", "apps/api")).To(Succeed())
+			Expect(os.Setenv("BP_NODE_MODULE_BOM_PROJECT_PATHS", "apps/*")).To(Succeed())
+
+			paths, err := nodemodulebom.LoadProjectPaths(workingDir, config, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{
+				filepath.Join(workingDir, "apps", "api"),
+				filepath.Join(workingDir, "apps", "worker"),
+			}))
+		})
+	})
+}
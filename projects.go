@@ -0,0 +1,324 @@
+package nodemodulebom
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+)
+
+const (
+	// ProjectProperty records the directory, relative to the application, of
+	// the root component of a Node project in a BOM that merges several
+	// projects.
+	ProjectProperty = "paketo:node-module-bom:project"
+
+	// ConcurrencyEnv names the environment variable that holds the maximum
+	// number of projects whose BOMs are generated at the same time.
+	ConcurrencyEnv = "BP_NODE_MODULE_BOM_CONCURRENCY"
+)
+
+// LoadConcurrency reads the maximum number of projects whose BOMs are
+// generated at the same time from BP_NODE_MODULE_BOM_CONCURRENCY, defaulting
+// to the number of CPUs.
+func LoadConcurrency() (int, error) {
+	str, ok := os.LookupEnv(ConcurrencyEnv)
+	if !ok {
+		return runtime.NumCPU(), nil
+	}
+
+	concurrency, err := strconv.Atoi(strings.TrimSpace(str))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s value %s: %w", ConcurrencyEnv, str, err)
+	}
+
+	if concurrency < 1 {
+		return 0, fmt.Errorf("failed to parse %s value %s: must be positive", ConcurrencyEnv, str)
+	}
+
+	return concurrency, nil
+}
+
+// ProjectBOM is the BOM of a single Node project, along with the directory of
+// the project relative to the application.
+type ProjectBOM struct {
+	Path string
+	BOM  BOM
+}
+
+// GenerateProjects generates the BOM of every given project directory
+// concurrently, with the generator in the given bin directory, and merges
+// them into a single BOM of the application in the working directory (see
+// MergeProjectBOMs). At most as many projects as LoadConcurrency allows are
+// generated at the same time, so that a large monorepo does not start a
+// generator process for every project at once. The log of every project is
+// buffered and written out in the order of the projects once they are all
+// done, so that the logs of concurrent projects do not interleave. When the
+// context is done before every project completes, the merged BOM of the
+// projects that did complete, and of what was built of the others, is
+// returned along with the error of the first project that did not.
+func (m ModuleBOM) GenerateProjects(ctx context.Context, workingDir string, projectPaths []string, binDir string) (BOM, error) {
+	type result struct {
+		bom BOM
+		err error
+		log *bytes.Buffer
+	}
+
+	concurrency, err := LoadConcurrency()
+	if err != nil {
+		return BOM{}, err
+	}
+
+	if concurrency > len(projectPaths) {
+		concurrency = len(projectPaths)
+	}
+
+	results := make([]result, len(projectPaths))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				log := bytes.NewBuffer(nil)
+
+				// Projects that are still waiting for a worker when the
+				// context is done are not started at all.
+				if ctx.Err() != nil {
+					results[i] = result{err: ctx.Err(), log: log}
+					continue
+				}
+
+				generator := NewModuleBOM(m.executable, scribe.NewEmitter(log))
+
+				bom, err := generator.Generate(ctx, projectPaths[i], binDir)
+				results[i] = result{bom: bom, err: err, log: log}
+			}
+		}()
+	}
+
+	for i := range projectPaths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var (
+		projects  []ProjectBOM
+		cancelErr error
+	)
+	for i, projectPath := range projectPaths {
+		rel, err := filepath.Rel(workingDir, projectPath)
+		if err != nil {
+			return BOM{}, err
+		}
+		rel = filepath.ToSlash(rel)
+
+		m.logger.Subprocess("Project %s", rel)
+		_, _ = m.logger.TitleWriter.Write(results[i].log.Bytes())
+
+		if results[i].err != nil {
+			err = fmt.Errorf("failed to generate BOM of project %s: %w", rel, results[i].err)
+			if ctx.Err() == nil || !errors.Is(results[i].err, ctx.Err()) {
+				return BOM{}, err
+			}
+
+			if cancelErr == nil {
+				cancelErr = err
+			}
+
+			if results[i].bom.BOMFormat != "" {
+				projects = append(projects, ProjectBOM{Path: rel, BOM: results[i].bom})
+			}
+			continue
+		}
+
+		projects = append(projects, ProjectBOM{Path: rel, BOM: results[i].bom})
+	}
+
+	application, ok, err := ReadApplication(workingDir)
+	if err != nil {
+		return BOM{}, fmt.Errorf("failed to read application: %w", err)
+	}
+
+	root := Component{
+		BOMRef: "project:.",
+		Type:   "application",
+		Name:   filepath.Base(workingDir),
+	}
+	if ok {
+		root = application.Component()
+	}
+
+	return MergeProjectBOMs(root, projects), cancelErr
+}
+
+// MergeProjectBOMs merges the BOMs of several Node projects into a single BOM
+// with the given root component. The root component of every project is
+// marked with its directory and is depended on by the root of the merged BOM.
+// Packages that several projects share are reported once, with the
+// occurrences of every project, and the paths that the BOMs record relative
+// to their project, such as file components and lockfiles, are made relative
+// to the application.
+func MergeProjectBOMs(root Component, projects []ProjectBOM) BOM {
+	merged := BOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: CycloneDXSpecVersion,
+		Version:     1,
+	}
+	merged.Metadata.Component = &root
+
+	positions := map[string]int{}
+	dependsOn := map[string][]string{}
+	var refs []string
+	addEdges := func(ref string, dependencies ...string) {
+		if _, ok := dependsOn[ref]; !ok {
+			refs = append(refs, ref)
+			dependsOn[ref] = nil
+		}
+
+		for _, dependency := range dependencies {
+			if !containsString(dependsOn[ref], dependency) {
+				dependsOn[ref] = append(dependsOn[ref], dependency)
+			}
+		}
+	}
+
+	properties := map[Property]bool{}
+	for _, project := range projects {
+		rel := project.Path
+		bom := project.BOM
+
+		if merged.Metadata.Timestamp == "" {
+			merged.Metadata.Timestamp = bom.Metadata.Timestamp
+		}
+		if len(merged.Metadata.Tools) == 0 {
+			merged.Metadata.Tools = bom.Metadata.Tools
+		}
+
+		var projectProperties []Property
+		for _, property := range bom.Metadata.Properties {
+			switch property.Name {
+			case LockfileProperty, InstalledTreeProperty:
+				property.Value = path.Join(rel, property.Value)
+			case PackageManagerProperty:
+				// Projects can use different package managers, so the
+				// package manager is recorded on the root of each.
+				projectProperties = append(projectProperties, property)
+				continue
+			}
+
+			if !properties[property] {
+				properties[property] = true
+				merged.Metadata.Properties = append(merged.Metadata.Properties, property)
+			}
+		}
+
+		rewrite := func(ref string) string {
+			if strings.HasPrefix(ref, "file:") {
+				return "file:" + path.Join(rel, strings.TrimPrefix(ref, "file:"))
+			}
+
+			return ref
+		}
+
+		projectRoot := Component{
+			BOMRef: "project:" + rel,
+			Type:   "application",
+			Name:   rel,
+		}
+		if bom.Metadata.Component != nil {
+			projectRoot = *bom.Metadata.Component
+			if projectRoot.BOMRef == "" {
+				projectRoot.BOMRef = "project:" + rel
+			}
+		}
+		projectRoot.Properties = append(projectRoot.Properties, Property{Name: ProjectProperty, Value: rel})
+		projectRoot.Properties = append(projectRoot.Properties, projectProperties...)
+
+		components := bom.Components
+		if projectRoot.BOMRef == root.BOMRef {
+			merged.Metadata.Component.Properties = projectRoot.Properties
+		} else {
+			addEdges(root.BOMRef, projectRoot.BOMRef)
+			components = append([]Component{projectRoot}, components...)
+		}
+
+		for _, component := range components {
+			component.BOMRef = rewrite(component.BOMRef)
+			if component.Type == "file" {
+				component.Name = path.Join(rel, component.Name)
+			}
+
+			if component.Evidence != nil {
+				evidence := Evidence{}
+				for _, occurrence := range component.Evidence.Occurrences {
+					evidence.Occurrences = append(evidence.Occurrences, Occurrence{Location: path.Join(rel, occurrence.Location)})
+				}
+				component.Evidence = &evidence
+			}
+
+			position, ok := positions[component.BOMRef]
+			if !ok || component.BOMRef == "" {
+				if component.BOMRef != "" {
+					positions[component.BOMRef] = len(merged.Components)
+				}
+				merged.Components = append(merged.Components, component)
+				continue
+			}
+
+			existing := merged.Components[position]
+			existing.Hashes = mergeHashes(existing.Hashes, component.Hashes...)
+			if component.Evidence != nil {
+				if existing.Evidence == nil {
+					existing.Evidence = &Evidence{}
+				}
+				existing.Evidence.Occurrences = append(existing.Evidence.Occurrences, component.Evidence.Occurrences...)
+			}
+			merged.Components[position] = existing
+		}
+
+		for _, dependency := range bom.Dependencies {
+			ref := rewrite(dependency.Ref)
+			if bom.Metadata.Component != nil && dependency.Ref == bom.Metadata.Component.BOMRef {
+				ref = projectRoot.BOMRef
+			}
+
+			var dependencies []string
+			for _, dependsOn := range dependency.DependsOn {
+				dependencies = append(dependencies, rewrite(dependsOn))
+			}
+			addEdges(ref, dependencies...)
+		}
+	}
+
+	for _, ref := range refs {
+		sort.Strings(dependsOn[ref])
+		merged.Dependencies = append(merged.Dependencies, Dependency{Ref: ref, DependsOn: dependsOn[ref]})
+	}
+
+	return merged
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
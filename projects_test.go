@@ -0,0 +1,335 @@
+package nodemodulebom_test
+
+import (
+	"bytes"
+	gocontext "context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/paketo-buildpacks/node-module-bom/fakes"
+	"github.com/paketo-buildpacks/packit/v2/pexec"
+	"github.com/paketo-buildpacks/packit/v2/scribe"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testProjects(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("MergeProjectBOMs", func() {
+		var projects []nodemodulebom.ProjectBOM
+
+		it.Before(func() {
+			projects = []nodemodulebom.ProjectBOM{
+				{
+					Path: "api",
+					BOM: nodemodulebom.BOM{
+						Metadata: nodemodulebom.Metadata{
+							Timestamp: "2021-08-16T19:35:52.107Z",
+							Tools:     []nodemodulebom.Tool{{Name: "Node.js module", Version: "3.0.3"}},
+							Component: &nodemodulebom.Component{BOMRef: "pkg:npm/api@1.0.0", Type: "application", Name: "api", Version: "1.0.0"},
+							Properties: []nodemodulebom.Property{
+								{Name: "paketo:node-module-bom:lockfile", Value: "package-lock.json"},
+								{Name: "paketo:node-module-bom:lockfile:sha256", Value: "abc"},
+								{Name: "paketo:node-module-bom:package-manager", Value: "npm"},
+							},
+						},
+						Components: []nodemodulebom.Component{
+							{
+								BOMRef:   "pkg:npm/leftpad@0.0.1",
+								Type:     "library",
+								Name:     "leftpad",
+								Version:  "0.0.1",
+								Hashes:   []nodemodulebom.Hash{{Algorithm: "SHA-512", Content: "abcdef"}},
+								Evidence: &nodemodulebom.Evidence{Occurrences: []nodemodulebom.Occurrence{{Location: "node_modules/leftpad"}}},
+							},
+							{
+								BOMRef: "file:node_modules/leftpad/leftpad.node",
+								Type:   "file",
+								Name:   "node_modules/leftpad/leftpad.node",
+							},
+						},
+						Dependencies: []nodemodulebom.Dependency{
+							{Ref: "pkg:npm/api@1.0.0", DependsOn: []string{"pkg:npm/leftpad@0.0.1"}},
+							{Ref: "pkg:npm/leftpad@0.0.1", DependsOn: []string{"file:node_modules/leftpad/leftpad.node"}},
+						},
+					},
+				},
+				{
+					Path: "worker",
+					BOM: nodemodulebom.BOM{
+						Metadata: nodemodulebom.Metadata{
+							Properties: []nodemodulebom.Property{
+								{Name: "paketo:node-module-bom:lockfile", Value: "package-lock.json"},
+								{Name: "paketo:node-module-bom:lockfile:sha256", Value: "abc"},
+								{Name: "paketo:node-module-bom:package-manager", Value: "pnpm@8.6.0"},
+							},
+						},
+						Components: []nodemodulebom.Component{
+							{
+								BOMRef:   "pkg:npm/leftpad@0.0.1",
+								Type:     "library",
+								Name:     "leftpad",
+								Version:  "0.0.1",
+								Hashes:   []nodemodulebom.Hash{{Algorithm: "SHA-1", Content: "123456"}},
+								Evidence: &nodemodulebom.Evidence{Occurrences: []nodemodulebom.Occurrence{{Location: "node_modules/leftpad"}}},
+							},
+						},
+						Dependencies: []nodemodulebom.Dependency{
+							{Ref: "pkg:npm/leftpad@0.0.1"},
+						},
+					},
+				},
+			}
+		})
+
+		it("merges the projects under the root component", func() {
+			bom := nodemodulebom.MergeProjectBOMs(nodemodulebom.Component{BOMRef: "pkg:npm/monorepo@1.0.0", Type: "application", Name: "monorepo", Version: "1.0.0"}, projects)
+
+			Expect(bom).To(Equal(nodemodulebom.BOM{
+				BOMFormat:   "CycloneDX",
+				SpecVersion: "1.5",
+				Version:     1,
+				Metadata: nodemodulebom.Metadata{
+					Timestamp: "2021-08-16T19:35:52.107Z",
+					Tools:     []nodemodulebom.Tool{{Name: "Node.js module", Version: "3.0.3"}},
+					Component: &nodemodulebom.Component{BOMRef: "pkg:npm/monorepo@1.0.0", Type: "application", Name: "monorepo", Version: "1.0.0"},
+					Properties: []nodemodulebom.Property{
+						{Name: "paketo:node-module-bom:lockfile", Value: "api/package-lock.json"},
+						{Name: "paketo:node-module-bom:lockfile:sha256", Value: "abc"},
+						{Name: "paketo:node-module-bom:lockfile", Value: "worker/package-lock.json"},
+					},
+				},
+				Components: []nodemodulebom.Component{
+					{
+						BOMRef:  "pkg:npm/api@1.0.0",
+						Type:    "application",
+						Name:    "api",
+						Version: "1.0.0",
+						Properties: []nodemodulebom.Property{
+							{Name: "paketo:node-module-bom:project", Value: "api"},
+							{Name: "paketo:node-module-bom:package-manager", Value: "npm"},
+						},
+					},
+					{
+						BOMRef:  "pkg:npm/leftpad@0.0.1",
+						Type:    "library",
+						Name:    "leftpad",
+						Version: "0.0.1",
+						Hashes: []nodemodulebom.Hash{
+							{Algorithm: "SHA-512", Content: "abcdef"},
+							{Algorithm: "SHA-1", Content: "123456"},
+						},
+						Evidence: &nodemodulebom.Evidence{Occurrences: []nodemodulebom.Occurrence{
+							{Location: "api/node_modules/leftpad"},
+							{Location: "worker/node_modules/leftpad"},
+						}},
+					},
+					{
+						BOMRef: "file:api/node_modules/leftpad/leftpad.node",
+						Type:   "file",
+						Name:   "api/node_modules/leftpad/leftpad.node",
+					},
+					{
+						BOMRef: "project:worker",
+						Type:   "application",
+						Name:   "worker",
+						Properties: []nodemodulebom.Property{
+							{Name: "paketo:node-module-bom:project", Value: "worker"},
+							{Name: "paketo:node-module-bom:package-manager", Value: "pnpm@8.6.0"},
+						},
+					},
+				},
+				Dependencies: []nodemodulebom.Dependency{
+					{Ref: "pkg:npm/monorepo@1.0.0", DependsOn: []string{"pkg:npm/api@1.0.0", "project:worker"}},
+					{Ref: "pkg:npm/api@1.0.0", DependsOn: []string{"pkg:npm/leftpad@0.0.1"}},
+					{Ref: "pkg:npm/leftpad@0.0.1", DependsOn: []string{"file:api/node_modules/leftpad/leftpad.node"}},
+				},
+			}))
+		})
+
+		context("when one of the projects is the application itself", func() {
+			it.Before(func() {
+				projects[0].Path = "."
+			})
+
+			it("uses it as the root component", func() {
+				bom := nodemodulebom.MergeProjectBOMs(nodemodulebom.Component{BOMRef: "pkg:npm/api@1.0.0", Type: "application", Name: "api", Version: "1.0.0"}, projects)
+
+				Expect(bom.Metadata.Component.Properties).To(Equal([]nodemodulebom.Property{
+					{Name: "paketo:node-module-bom:project", Value: "."},
+					{Name: "paketo:node-module-bom:package-manager", Value: "npm"},
+				}))
+				Expect(bom.Components[0].BOMRef).To(Equal("pkg:npm/leftpad@0.0.1"))
+				Expect(bom.Dependencies).To(ContainElement(nodemodulebom.Dependency{
+					Ref:       "pkg:npm/api@1.0.0",
+					DependsOn: []string{"pkg:npm/leftpad@0.0.1", "project:worker"},
+				}))
+			})
+		})
+	})
+
+	context("GenerateProjects", func() {
+		var (
+			workingDir string
+			executable *fakes.Executable
+			buffer     *bytes.Buffer
+			moduleBOM  nodemodulebom.ModuleBOM
+		)
+
+		it.Before(func() {
+			var err error
+			workingDir, err = os.MkdirTemp("", "working-dir")
+			Expect(err).NotTo(HaveOccurred())
+
+			for path, content := range map[string]string{
+				"package.json":                             `{"name": "monorepo", "version": "1.0.0"}`,
+				"api/package.json":                         `{"name": "api", "version": "1.0.0", "dependencies": {"leftpad": "^0.0.1"}}`,
+				"api/node_modules/leftpad/package.json":    `{"name": "leftpad", "version": "0.0.1"}`,
+				"worker/package.json":                      `{"name": "worker", "version": "2.0.0", "dependencies": {"leftpad": "^0.0.1"}}`,
+				"worker/node_modules/leftpad/package.json": `{"name": "leftpad", "version": "0.0.1"}`,
+			} {
+				Expect(os.MkdirAll(filepath.Join(workingDir, filepath.Dir(path)), os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(workingDir, path), []byte(content), 0600)).To(Succeed())
+			}
+
+			executable = &fakes.Executable{}
+			executable.ExecuteCall.Stub = func(_ gocontext.Context, execution pexec.Execution) error {
+				return os.WriteFile(execution.Args[1], []byte(`{"components": []}`), 0600)
+			}
+
+			buffer = bytes.NewBuffer(nil)
+			moduleBOM = nodemodulebom.NewModuleBOM(executable, scribe.NewEmitter(buffer))
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(workingDir)).To(Succeed())
+		})
+
+		it("generates the BOM of every project and merges them", func() {
+			bom, err := moduleBOM.GenerateProjects(gocontext.Background(), workingDir, []string{filepath.Join(workingDir, "api"), filepath.Join(workingDir, "worker")}, "some-bin-dir")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(executable.ExecuteCall.CallCount).To(Equal(2))
+
+			Expect(bom.Metadata.Component.BOMRef).To(Equal("pkg:npm/monorepo@1.0.0"))
+			Expect(bom.Dependencies).To(ContainElement(nodemodulebom.Dependency{
+				Ref:       "pkg:npm/monorepo@1.0.0",
+				DependsOn: []string{"pkg:npm/api@1.0.0", "pkg:npm/worker@2.0.0"},
+			}))
+			Expect(bom.Dependencies).To(ContainElement(nodemodulebom.Dependency{
+				Ref:       "pkg:npm/worker@2.0.0",
+				DependsOn: []string{"pkg:npm/leftpad@0.0.1"},
+			}))
+
+			var refs []string
+			for _, component := range bom.Components {
+				refs = append(refs, component.BOMRef)
+			}
+			Expect(refs).To(Equal([]string{"pkg:npm/api@1.0.0", "pkg:npm/leftpad@0.0.1", "pkg:npm/worker@2.0.0"}))
+			Expect(bom.Components[1].Evidence.Occurrences).To(Equal([]nodemodulebom.Occurrence{
+				{Location: "api/node_modules/leftpad"},
+				{Location: "worker/node_modules/leftpad"},
+			}))
+
+			Expect(buffer.String()).To(MatchRegexp(`(?s)Project api\n.*Running 'cyclonedx-bom -o .*/bom.json'.*Project worker\n.*Running 'cyclonedx-bom -o .*/bom.json'`))
+		})
+
+		context("when BP_NODE_MODULE_BOM_CONCURRENCY is set", func() {
+			it.Before(func() {
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_CONCURRENCY", "1")).To(Succeed())
+			})
+
+			it.After(func() {
+				Expect(os.Unsetenv("BP_NODE_MODULE_BOM_CONCURRENCY")).To(Succeed())
+			})
+
+			it("generates at most that many projects at the same time", func() {
+				var running, maximum int32
+				executable.ExecuteCall.Stub = func(_ gocontext.Context, execution pexec.Execution) error {
+					current := atomic.AddInt32(&running, 1)
+					defer atomic.AddInt32(&running, -1)
+
+					for {
+						previous := atomic.LoadInt32(&maximum)
+						if current <= previous || atomic.CompareAndSwapInt32(&maximum, previous, current) {
+							break
+						}
+					}
+					time.Sleep(10 * time.Millisecond)
+
+					return os.WriteFile(execution.Args[1], []byte(`{"components": []}`), 0600)
+				}
+
+				_, err := moduleBOM.GenerateProjects(gocontext.Background(), workingDir, []string{filepath.Join(workingDir, "api"), filepath.Join(workingDir, "worker")}, "some-bin-dir")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(executable.ExecuteCall.CallCount).To(Equal(2))
+				Expect(atomic.LoadInt32(&maximum)).To(Equal(int32(1)))
+			})
+
+			context("when it is not a positive number", func() {
+				it.Before(func() {
+					Expect(os.Setenv("BP_NODE_MODULE_BOM_CONCURRENCY", "0")).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := moduleBOM.GenerateProjects(gocontext.Background(), workingDir, []string{filepath.Join(workingDir, "api"), filepath.Join(workingDir, "worker")}, "some-bin-dir")
+					Expect(err).To(MatchError("failed to parse BP_NODE_MODULE_BOM_CONCURRENCY value 0: must be positive"))
+				})
+			})
+		})
+
+		context("when the context is done before every project completes", func() {
+			it.Before(func() {
+				executable.ExecuteCall.Stub = func(ctx gocontext.Context, execution pexec.Execution) error {
+					if execution.Dir == filepath.Join(workingDir, "worker") {
+						<-ctx.Done()
+						return ctx.Err()
+					}
+
+					return os.WriteFile(execution.Args[1], []byte(`{"components": []}`), 0600)
+				}
+			})
+
+			it("returns the BOM of the projects that completed along with the error", func() {
+				ctx, cancel := gocontext.WithTimeout(gocontext.Background(), 100*time.Millisecond)
+				defer cancel()
+
+				bom, err := moduleBOM.GenerateProjects(ctx, workingDir, []string{filepath.Join(workingDir, "api"), filepath.Join(workingDir, "worker")}, "some-bin-dir")
+				Expect(err).To(MatchError("failed to generate BOM of project worker: failed to run cyclonedx-bom: context deadline exceeded"))
+				Expect(nodemodulebom.IsTimeout(err)).To(BeTrue())
+
+				Expect(bom.Metadata.Component.BOMRef).To(Equal("pkg:npm/monorepo@1.0.0"))
+				Expect(bom.Dependencies).To(ContainElement(nodemodulebom.Dependency{
+					Ref:       "pkg:npm/monorepo@1.0.0",
+					DependsOn: []string{"pkg:npm/api@1.0.0"},
+				}))
+			})
+		})
+
+		context("when the BOM of a project cannot be generated", func() {
+			it.Before(func() {
+				executable.ExecuteCall.Stub = func(_ gocontext.Context, execution pexec.Execution) error {
+					if execution.Dir == filepath.Join(workingDir, "worker") {
+						return errors.New("failed to execute")
+					}
+
+					return os.WriteFile(execution.Args[1], []byte(`{"components": []}`), 0600)
+				}
+			})
+
+			it("returns an error", func() {
+				_, err := moduleBOM.GenerateProjects(gocontext.Background(), workingDir, []string{filepath.Join(workingDir, "api"), filepath.Join(workingDir, "worker")}, "some-bin-dir")
+				Expect(err).To(MatchError(fmt.Sprintf("failed to generate BOM of project %s: failed to run cyclonedx-bom: failed to execute", "worker")))
+			})
+		})
+	})
+}
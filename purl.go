@@ -0,0 +1,169 @@
+package nodemodulebom
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// defaultRegistries are the registries that npm packages are resolved from
+// by default, and for which the purl has no repository_url qualifier.
+var defaultRegistries = []string{
+	"https://registry.npmjs.org",
+	"https://registry.yarnpkg.com",
+}
+
+// PackageURL builds the purl of an npm package with the given name, version
+// and qualifiers, percent-encoding each part as required by the purl
+// specification. The "@scope" of a scoped package becomes the purl namespace.
+func PackageURL(name, version string, qualifiers map[string]string) string {
+	purl := "pkg:npm/"
+
+	scope, name := splitPackageName(name)
+	if scope != "" {
+		purl += escapePURL(scope, "") + "/"
+	}
+	purl += escapePURL(name, "")
+
+	if version != "" {
+		purl += "@" + escapePURL(version, "")
+	}
+
+	var keys []string
+	for key, value := range qualifiers {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for i, key := range keys {
+		separator := "&"
+		if i == 0 {
+			separator = "?"
+		}
+		purl += separator + key + "=" + escapePURL(qualifiers[key], qualifierValueCharacters)
+	}
+
+	return purl
+}
+
+// qualifierValueCharacters are the characters that the purl specification
+// leaves unencoded in qualifier values besides the unreserved characters, so
+// that a vcs_url such as "git+https://github.com/user/repo.git@sha" reads the
+// same as it does in the purls of other generators. Characters that delimit
+// the qualifiers or the subpath, such as "&" and "#", are still encoded.
+const qualifierValueCharacters = ":/@+"
+
+// escapePURL percent-encodes every character of the value that is not an
+// unreserved character or one of the given allowed characters.
+func escapePURL(value, allowed string) string {
+	var builder strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9', strings.IndexByte("-._~", b) >= 0, strings.IndexByte(allowed, b) >= 0:
+			builder.WriteByte(b)
+		default:
+			fmt.Fprintf(&builder, "%%%02X", b)
+		}
+	}
+
+	return builder.String()
+}
+
+// packageLocation classifies where an npm package was resolved from, given
+// either its resolved URL or a dependency specifier such as
+// "github:user/repo#sha" or "file:../lib".
+type packageLocation struct {
+	Qualifiers map[string]string
+	Commit     string
+	Local      bool
+}
+
+func locatePackage(name, location string) packageLocation {
+	switch {
+	case location == "":
+		return packageLocation{}
+
+	case strings.HasPrefix(location, "file:"), strings.HasPrefix(location, "link:"):
+		return packageLocation{Local: true}
+
+	case isGitLocation(location):
+		repository, commit := splitFragment(location)
+		repository = expandGitShorthand(repository)
+
+		vcsURL := repository
+		if commit != "" {
+			vcsURL += "@" + commit
+		}
+
+		return packageLocation{
+			Qualifiers: map[string]string{"vcs_url": vcsURL},
+			Commit:     commit,
+		}
+
+	case strings.HasPrefix(location, "http://"), strings.HasPrefix(location, "https://"):
+		marker := "/" + name + "/-/"
+		if index := strings.Index(location, marker); index >= 0 {
+			registry := location[:index]
+			for _, defaultRegistry := range defaultRegistries {
+				if strings.TrimSuffix(registry, "/") == defaultRegistry {
+					return packageLocation{}
+				}
+			}
+
+			return packageLocation{Qualifiers: map[string]string{"repository_url": registry}}
+		}
+
+		return packageLocation{Qualifiers: map[string]string{"download_url": location}}
+	}
+
+	return packageLocation{}
+}
+
+func isGitLocation(location string) bool {
+	for _, prefix := range []string{"git+", "git://", "github:", "gitlab:", "bitbucket:", "gist:"} {
+		if strings.HasPrefix(location, prefix) {
+			return true
+		}
+	}
+
+	repository, _ := splitFragment(location)
+	return strings.HasSuffix(repository, ".git")
+}
+
+// expandGitShorthand expands the hosted git shorthands that npm supports into
+// the URL of the repository.
+func expandGitShorthand(repository string) string {
+	hosts := map[string]string{
+		"github:":    "github.com",
+		"gitlab:":    "gitlab.com",
+		"bitbucket:": "bitbucket.org",
+		"gist:":      "gist.github.com",
+	}
+
+	for prefix, host := range hosts {
+		if strings.HasPrefix(repository, prefix) {
+			return fmt.Sprintf("git+https://%s/%s.git", host, strings.TrimPrefix(repository, prefix))
+		}
+	}
+
+	if strings.HasPrefix(repository, "git+") || strings.HasPrefix(repository, "git://") {
+		return repository
+	}
+
+	if u, err := url.Parse(repository); err == nil && u.Scheme != "" {
+		return "git+" + repository
+	}
+
+	return repository
+}
+
+func splitFragment(location string) (string, string) {
+	if index := strings.LastIndex(location, "#"); index >= 0 {
+		return location[:index], location[index+1:]
+	}
+
+	return location, ""
+}
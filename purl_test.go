@@ -0,0 +1,104 @@
+package nodemodulebom_test
+
+import (
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testPURL(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("PackageURL", func() {
+		it("builds the purl of a package", func() {
+			Expect(nodemodulebom.PackageURL("leftpad", "0.0.1", nil)).To(Equal("pkg:npm/leftpad@0.0.1"))
+		})
+
+		it("percent-encodes the scope as the namespace", func() {
+			Expect(nodemodulebom.PackageURL("@scope/rightpad", "1.0.0+build.1", nil)).To(Equal("pkg:npm/%40scope/rightpad@1.0.0%2Bbuild.1"))
+		})
+
+		it("omits a missing version", func() {
+			Expect(nodemodulebom.PackageURL("leftpad", "", nil)).To(Equal("pkg:npm/leftpad"))
+		})
+
+		it("sorts and encodes the qualifiers", func() {
+			Expect(nodemodulebom.PackageURL("leftpad", "0.0.1", map[string]string{
+				"vcs_url":        "git+https://github.com/user/leftpad.git@abc123",
+				"repository_url": "https://npm.example.com",
+				"empty":          "",
+			})).To(Equal("pkg:npm/leftpad@0.0.1?repository_url=https://npm.example.com&vcs_url=git+https://github.com/user/leftpad.git@abc123"))
+		})
+
+		it("encodes the characters that delimit qualifiers inside of their values", func() {
+			Expect(nodemodulebom.PackageURL("leftpad", "0.0.1", map[string]string{
+				"download_url": "https://example.com/download?name=leftpad&version=0.0.1#tarball",
+			})).To(Equal("pkg:npm/leftpad@0.0.1?download_url=https://example.com/download%3Fname%3Dleftpad%26version%3D0.0.1%23tarball"))
+		})
+	})
+
+	context("InstalledPackage.PURL", func() {
+		it("has no qualifiers for packages from the default registry", func() {
+			pkg := nodemodulebom.InstalledPackage{
+				Name:     "@scope/rightpad",
+				Version:  "1.0.0",
+				Resolved: "https://registry.npmjs.org/@scope/rightpad/-/rightpad-1.0.0.tgz",
+			}
+			Expect(pkg.PURL()).To(Equal("pkg:npm/%40scope/rightpad@1.0.0"))
+			Expect(pkg.Local()).To(BeFalse())
+		})
+
+		it("qualifies packages from other registries with the repository_url", func() {
+			pkg := nodemodulebom.InstalledPackage{
+				Name:     "leftpad",
+				Version:  "0.0.1",
+				Resolved: "https://npm.example.com/artifactory/api/npm/leftpad/-/leftpad-0.0.1.tgz",
+			}
+			Expect(pkg.PURL()).To(Equal("pkg:npm/leftpad@0.0.1?repository_url=https://npm.example.com/artifactory/api/npm"))
+		})
+
+		it("qualifies tarball dependencies with the download_url", func() {
+			pkg := nodemodulebom.InstalledPackage{
+				Name:     "leftpad",
+				Version:  "0.0.1",
+				Resolved: "https://example.com/downloads/leftpad.tgz",
+			}
+			Expect(pkg.PURL()).To(Equal("pkg:npm/leftpad@0.0.1?download_url=https://example.com/downloads/leftpad.tgz"))
+		})
+
+		it("qualifies git dependencies with the vcs_url and commit", func() {
+			pkg := nodemodulebom.InstalledPackage{
+				Name:     "leftpad",
+				Version:  "0.0.1",
+				Resolved: "git+ssh://git@github.com/user/leftpad.git#0123abcd",
+			}
+			Expect(pkg.PURL()).To(Equal("pkg:npm/leftpad@0.0.1?vcs_url=git+ssh://git@github.com/user/leftpad.git@0123abcd"))
+		})
+
+		it("uses the commit as the version of git dependencies recorded as specifiers", func() {
+			pkg := nodemodulebom.InstalledPackage{
+				Name:    "leftpad",
+				Version: "github:user/leftpad#0123abcd",
+			}
+			Expect(pkg.PURL()).To(Equal("pkg:npm/leftpad@0123abcd?vcs_url=git+https://github.com/user/leftpad.git@0123abcd"))
+			Expect(pkg.Component().Version).To(Equal("0123abcd"))
+		})
+
+		it("marks local dependencies as first-party", func() {
+			pkg := nodemodulebom.InstalledPackage{
+				Name:     "local-lib",
+				Version:  "1.0.0",
+				Resolved: "file:../local-lib",
+			}
+			Expect(pkg.PURL()).To(Equal("pkg:npm/local-lib@1.0.0"))
+			Expect(pkg.Local()).To(BeTrue())
+			Expect(pkg.Component().Properties).To(ContainElement(nodemodulebom.Property{
+				Name:  "paketo:node-module-bom:first-party",
+				Value: "true",
+			}))
+		})
+	})
+}
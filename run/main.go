@@ -7,7 +7,6 @@ import (
 	"github.com/paketo-buildpacks/packit/v2"
 	"github.com/paketo-buildpacks/packit/v2/cargo"
 	"github.com/paketo-buildpacks/packit/v2/chronos"
-	"github.com/paketo-buildpacks/packit/v2/pexec"
 	"github.com/paketo-buildpacks/packit/v2/postal"
 	"github.com/paketo-buildpacks/packit/v2/scribe"
 )
@@ -16,10 +15,11 @@ func main() {
 	logEmitter := scribe.NewEmitter(os.Stdout).WithLevel(os.Getenv("BP_LOG_LEVEL"))
 
 	packit.Run(
-		nodemodulebom.Detect(),
+		nodemodulebom.Detect(logEmitter),
 		nodemodulebom.Build(
 			postal.NewService(cargo.NewTransport()),
-			nodemodulebom.NewModuleBOM(pexec.NewExecutable("cyclonedx-bom"), scribe.NewEmitter(os.Stdout)),
+			nodemodulebom.NewModuleBOM(nodemodulebom.NewToolExecutable("cyclonedx-bom"), scribe.NewEmitter(os.Stdout)),
+			nodemodulebom.NewLockfileDrift(),
 			chronos.DefaultClock,
 			logEmitter,
 		),
@@ -0,0 +1,374 @@
+package nodemodulebom
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paketo-buildpacks/packit/v2/postal"
+)
+
+const (
+	// SBOMCacheLayer names the cached layer that keeps the last generated SBOM
+	// along with the fingerprint of its inputs.
+	SBOMCacheLayer = "node-module-bom-cache"
+
+	// FingerprintMetadataKey is the layer metadata key of the fingerprint of
+	// the cached SBOM.
+	FingerprintMetadataKey = "fingerprint"
+
+	cachedBOMFile = "bom.json"
+)
+
+// fingerprintFiles are the files of a Node project, relative to the project,
+// whose contents determine the packages that the SBOM describes: the manifest,
+// the workspace configuration of pnpm, the lockfiles of every supported package
+// manager and the state files that package managers write into node_modules
+// when they install it.
+var fingerprintFiles = []string{
+	"package.json",
+	"pnpm-workspace.yaml",
+	ShrinkwrapLockfile,
+	PackageLockLockfile,
+	BunLockfile,
+	"bun.lockb",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	HiddenLockfileSource,
+	"node_modules/.yarn-integrity",
+	"node_modules/.yarn-state.yml",
+	"node_modules/.modules.yaml",
+}
+
+// installBuildpacks are the buildpacks that install node_modules into a layer
+// of their own. The metadata of their layers changes whenever they reinstall
+// the packages.
+var installBuildpacks = []string{
+	"paketo-buildpacks/npm-install",
+	"paketo-buildpacks/yarn-install",
+}
+
+// Fingerprint returns a digest of everything that the SBOM of the given
+// projects is generated from: the manifests, lockfiles and installed state of
+// the projects, the manifests of their workspace packages, the name, version
+// and integrity of every package installed in their node_modules, the
+// contents of the download caches, the metadata of the node_modules layers of
+// the install buildpacks, the version of the generator and of this buildpack,
+// and the BP_NODE_MODULE_BOM_* configuration along with the variables that
+// move the download caches. The layers directory is the one of this
+// buildpack, the layers of the other buildpacks are its siblings. The walk of
+// node_modules stops with the error of the context once the context is done.
+func Fingerprint(ctx context.Context, layersDir, workingDir string, projectPaths []string, generator postal.Dependency, buildpackVersion string) (string, error) {
+	hash := sha256.New()
+	write := func(key, value string) {
+		fmt.Fprintf(hash, "%s\x00%s\n", key, value)
+	}
+
+	write("buildpack", buildpackVersion)
+	write("generator", strings.Join([]string{generator.ID, generator.Version, generator.SHA256}, "@")) //nolint:staticcheck
+
+	var env []string
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		if strings.HasPrefix(name, "BP_NODE_MODULE_BOM_") || name == ProjectPathEnv || containsString(downloadCacheEnv, name) {
+			env = append(env, variable)
+		}
+	}
+	sort.Strings(env)
+	for _, variable := range env {
+		write("env", variable)
+	}
+
+	for _, projectPath := range projectPaths {
+		rel, err := filepath.Rel(workingDir, projectPath)
+		if err != nil {
+			return "", err
+		}
+		write("project", filepath.ToSlash(rel))
+
+		for _, file := range fingerprintFiles {
+			sum, ok, err := fileSHA256(filepath.Join(projectPath, filepath.FromSlash(file)))
+			if err != nil {
+				return "", fmt.Errorf("failed to fingerprint %s: %w", file, err)
+			}
+
+			if ok {
+				write(file, sum)
+			}
+		}
+
+		workspaces, err := FindWorkspaces(projectPath)
+		if err != nil {
+			return "", err
+		}
+
+		for _, workspace := range workspaces {
+			sum, _, err := fileSHA256(filepath.Join(projectPath, filepath.FromSlash(workspace.Path), "package.json"))
+			if err != nil {
+				return "", fmt.Errorf("failed to fingerprint workspace package %s: %w", workspace.Path, err)
+			}
+			write("workspace", workspace.Path+"@"+sum)
+		}
+
+		err = fingerprintInstalledTree(ctx, projectPath, write)
+		if err != nil {
+			return "", fmt.Errorf("failed to fingerprint node_modules: %w", err)
+		}
+
+		for _, dir := range downloadCacheDirs(projectPath) {
+			err = fingerprintDownloadCache(dir, write)
+			if err != nil {
+				return "", fmt.Errorf("failed to fingerprint download cache %s: %w", dir, err)
+			}
+		}
+	}
+
+	for _, buildpack := range installBuildpacks {
+		dir := filepath.Join(filepath.Dir(layersDir), strings.ReplaceAll(buildpack, "/", "_"))
+		paths, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+		if err != nil {
+			return "", err
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			name := filepath.Base(path)
+			switch name {
+			case "launch.toml", "build.toml", "store.toml":
+				continue
+			}
+
+			sum, ok, err := fileSHA256(path)
+			if err != nil {
+				return "", fmt.Errorf("failed to fingerprint layer metadata of %s: %w", buildpack, err)
+			}
+
+			if ok {
+				write(buildpack, name+"@"+sum)
+			}
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fingerprintInstalledTree writes the name, version and integrity of every
+// package.json in the node_modules directory of the given project, and the
+// target of every symlink, so that the fingerprint changes whenever the
+// installed tree does, even when no lockfile or install state records it,
+// such as in a vendored node_modules. The browsers that Puppeteer and
+// Playwright download into their packages are recorded by name, and the
+// native binaries and the license, notice and readme files that the SBOM is
+// completed from by their size and modification time.
+func fingerprintInstalledTree(ctx context.Context, projectPath string, write func(key, value string)) error {
+	root := filepath.Join(projectPath, "node_modules")
+
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && path == root {
+				return nil
+			}
+
+			return err
+		}
+
+		err = ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(projectPath, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case entry.Type()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			write("symlink", rel+"@"+filepath.ToSlash(target))
+
+		case entry.IsDir() && (entry.Name() == ".local-chromium" || entry.Name() == ".local-browsers"):
+			err = fingerprintDownloadCache(path, write)
+			if err != nil {
+				return err
+			}
+
+			return filepath.SkipDir
+
+		case !entry.IsDir() && entry.Name() == "package.json":
+			pkg, err := readPackageJSON(path)
+			if err != nil {
+				// Packages ship malformed package.json files as test
+				// fixtures, which the generator ignores as well.
+				write("package", rel+"@malformed")
+				return nil
+			}
+			write("package", strings.Join([]string{rel, pkg.Name, pkg.Version, pkg.Integrity}, "@"))
+
+		case entry.Type().IsRegular() && (strings.HasSuffix(entry.Name(), ".node") || strings.HasSuffix(entry.Name(), ".wasm")):
+			return fingerprintFile(entry, "native", rel, write)
+
+		case entry.Type().IsRegular() && isLicenseOrNoticeFile(entry.Name()):
+			return fingerprintFile(entry, "license", rel, write)
+		}
+
+		return nil
+	})
+}
+
+// isLicenseOrNoticeFile reports whether the license file detection or the
+// copyright extraction read the file of the given name.
+func isLicenseOrNoticeFile(name string) bool {
+	name = strings.ToLower(name)
+	for _, prefix := range []string{"license", "licence", "copying", "unlicense", "notice", "readme"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fingerprintFile writes the size and modification time of the given file,
+// which change whenever its content is replaced.
+func fingerprintFile(entry fs.DirEntry, key, rel string, write func(key, value string)) error {
+	info, err := entry.Info()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+	write(key, strings.Join([]string{rel, strconv.FormatInt(info.Size(), 10), strconv.FormatInt(info.ModTime().UnixNano(), 10)}, "@"))
+
+	return nil
+}
+
+// fingerprintDownloadCache writes the names of the entries of the given
+// download cache directory and of its subdirectories, which name the
+// downloaded browsers and their versions.
+func fingerprintDownloadCache(dir string, write func(key, value string)) error {
+	names, err := readDirNames(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		write("download-cache", filepath.ToSlash(filepath.Join(dir, name)))
+
+		children, err := readDirNames(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+
+		for _, child := range children {
+			write("download-cache", filepath.ToSlash(filepath.Join(dir, name, child)))
+		}
+	}
+
+	return nil
+}
+
+// ReadCachedBOM reads the BOM that WriteCachedBOM kept in the given layer
+// directory. The returned bool is false when there is no cached BOM.
+func ReadCachedBOM(layerPath string) (BOM, bool, error) {
+	file, err := os.Open(filepath.Join(layerPath, cachedBOMFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return BOM{}, false, nil
+		}
+
+		return BOM{}, false, fmt.Errorf("failed to open cached BOM: %w", err)
+	}
+	defer file.Close()
+
+	var bom BOM
+	err = json.NewDecoder(file).Decode(&bom)
+	if err != nil {
+		return BOM{}, false, fmt.Errorf("failed to decode cached BOM: %w", err)
+	}
+
+	return bom, true, nil
+}
+
+// WriteCachedBOM keeps the given BOM in the given layer directory.
+func WriteCachedBOM(layerPath string, bom BOM) error {
+	content, err := json.Marshal(bom)
+	if err != nil {
+		return fmt.Errorf("failed to encode cached BOM: %w", err)
+	}
+
+	err = os.MkdirAll(layerPath, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create cache layer: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(layerPath, cachedBOMFile), content, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write cached BOM: %w", err)
+	}
+
+	return nil
+}
+
+// refreshGitMetadata replaces the git commit and branch of the metadata
+// component of a cached BOM with the current ones of the application, which
+// the fingerprint leaves out so that a new commit alone does not invalidate
+// the cache.
+func refreshGitMetadata(bom *BOM, application Application) {
+	if bom.Metadata.Component == nil {
+		return
+	}
+
+	var properties []Property
+	for _, property := range bom.Metadata.Component.Properties {
+		if property.Name != GitCommitProperty && property.Name != GitBranchProperty {
+			properties = append(properties, property)
+		}
+	}
+
+	if application.Commit != "" {
+		properties = append(properties, Property{Name: GitCommitProperty, Value: application.Commit})
+	}
+
+	if application.Branch != "" {
+		properties = append(properties, Property{Name: GitBranchProperty, Value: application.Branch})
+	}
+
+	bom.Metadata.Component.Properties = properties
+}
+
+func fileSHA256(path string) (string, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", false, nil
+		}
+
+		return "", false, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", false, err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), true, nil
+}
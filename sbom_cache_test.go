@@ -0,0 +1,236 @@
+package nodemodulebom_test
+
+import (
+	gocontext "context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/paketo-buildpacks/packit/v2/postal"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testSBOMCache(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("Fingerprint", func() {
+		var (
+			rootDir    string
+			layersDir  string
+			workingDir string
+			generator  postal.Dependency
+		)
+
+		fingerprint := func() string {
+			value, err := nodemodulebom.Fingerprint(gocontext.Background(), layersDir, workingDir, []string{workingDir}, generator, "1.2.3")
+			Expect(err).NotTo(HaveOccurred())
+
+			return value
+		}
+
+		it.Before(func() {
+			var err error
+			rootDir, err = os.MkdirTemp("", "layers")
+			Expect(err).NotTo(HaveOccurred())
+
+			layersDir = filepath.Join(rootDir, "paketo-buildpacks_node-module-bom")
+			Expect(os.MkdirAll(filepath.Join(rootDir, "paketo-buildpacks_npm-install"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(rootDir, "paketo-buildpacks_npm-install", "modules.toml"), []byte("[metadata]\ncache_sha = \"abc\"\n"), 0600)).To(Succeed())
+
+			workingDir, err = os.MkdirTemp("", "working-dir")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"name": "some-app"}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), []byte(`{"lockfileVersion": 3}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", ".package-lock.json"), []byte(`{"lockfileVersion": 3}`), 0600)).To(Succeed())
+
+			generator = postal.Dependency{ID: "cyclonedx-node-module", Version: "3.0.3", SHA256: "some-sha"}
+		})
+
+		it.After(func() {
+			Expect(os.Unsetenv("BP_NODE_MODULE_BOM_SOME_SETTING")).To(Succeed())
+			Expect(os.RemoveAll(rootDir)).To(Succeed())
+			Expect(os.RemoveAll(workingDir)).To(Succeed())
+		})
+
+		it("is the same for the same inputs", func() {
+			Expect(fingerprint()).To(HaveLen(64))
+			Expect(fingerprint()).To(Equal(fingerprint()))
+		})
+
+		it("ignores files that do not describe the installed packages", func() {
+			before := fingerprint()
+
+			Expect(os.WriteFile(filepath.Join(workingDir, "index.js"), []byte(`console.log("hi")`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(rootDir, "paketo-buildpacks_npm-install", "launch.toml"), []byte("[[processes]]\n"), 0600)).To(Succeed())
+
+			Expect(fingerprint()).To(Equal(before))
+		})
+
+		it("changes with the lockfile", func() {
+			before := fingerprint()
+			Expect(os.WriteFile(filepath.Join(workingDir, "package-lock.json"), []byte(`{"lockfileVersion": 2}`), 0600)).To(Succeed())
+			Expect(fingerprint()).NotTo(Equal(before))
+		})
+
+		it("changes with the hidden lockfile of node_modules", func() {
+			before := fingerprint()
+			Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", ".package-lock.json"), []byte(`{}`), 0600)).To(Succeed())
+			Expect(fingerprint()).NotTo(Equal(before))
+		})
+
+		it("changes with the packages installed in node_modules", func() {
+			Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules", "leftpad"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "leftpad", "package.json"), []byte(`{"name": "leftpad", "version": "0.0.1"}`), 0600)).To(Succeed())
+			Expect(os.Remove(filepath.Join(workingDir, "node_modules", ".package-lock.json"))).To(Succeed())
+
+			before := fingerprint()
+			Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "leftpad", "package.json"), []byte(`{"name": "leftpad", "version": "0.0.2"}`), 0600)).To(Succeed())
+			Expect(fingerprint()).NotTo(Equal(before))
+
+			before = fingerprint()
+			Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "leftpad", "package.json"), []byte(`{"name": "leftpad", "version": "0.0.2", "_integrity": "sha512-abc"}`), 0600)).To(Succeed())
+			Expect(fingerprint()).NotTo(Equal(before))
+		})
+
+		it("changes with the native binaries and license files of the installed packages", func() {
+			Expect(os.MkdirAll(filepath.Join(workingDir, "node_modules", "addon", "build", "Release"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "addon", "package.json"), []byte(`{"name": "addon", "version": "1.0.0"}`), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "addon", "build", "Release", "addon.node"), []byte("some-binary"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "addon", "LICENSE"), []byte("MIT License"), 0600)).To(Succeed())
+
+			before := fingerprint()
+			Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "addon", "build", "Release", "addon.node"), []byte("other-binary"), 0600)).To(Succeed())
+			Expect(fingerprint()).NotTo(Equal(before))
+
+			before = fingerprint()
+			Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "addon", "addon.wasm"), []byte("some-module"), 0600)).To(Succeed())
+			Expect(fingerprint()).NotTo(Equal(before))
+
+			before = fingerprint()
+			Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "addon", "LICENSE"), []byte("ISC License"), 0600)).To(Succeed())
+			Expect(os.Chtimes(filepath.Join(workingDir, "node_modules", "addon", "LICENSE"), time.Unix(1700000000, 0), time.Unix(1700000000, 0))).To(Succeed())
+			Expect(fingerprint()).NotTo(Equal(before))
+
+			before = fingerprint()
+			Expect(os.WriteFile(filepath.Join(workingDir, "node_modules", "addon", "COPYING"), []byte("GNU GPL"), 0600)).To(Succeed())
+			Expect(fingerprint()).NotTo(Equal(before))
+		})
+
+		it("changes with the manifests of the workspace packages", func() {
+			Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-workspace.yaml"), []byte("packages:\n  - packages/*\n"), 0600)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(workingDir, "packages", "api"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, "packages", "api", "package.json"), []byte(`{"name": "api"}`), 0600)).To(Succeed())
+
+			before := fingerprint()
+			Expect(os.WriteFile(filepath.Join(workingDir, "packages", "api", "package.json"), []byte(`{"name": "api", "dependencies": {"leftpad": "^0.0.1"}}`), 0600)).To(Succeed())
+			Expect(fingerprint()).NotTo(Equal(before))
+
+			before = fingerprint()
+			Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-workspace.yaml"), []byte("packages:\n  - apps/*\n"), 0600)).To(Succeed())
+			Expect(fingerprint()).NotTo(Equal(before))
+		})
+
+		it("changes with the contents of the download caches", func() {
+			cacheDir := filepath.Join(rootDir, "puppeteer")
+			Expect(os.Setenv("PUPPETEER_CACHE_DIR", cacheDir)).To(Succeed())
+			defer os.Unsetenv("PUPPETEER_CACHE_DIR")
+
+			before := fingerprint()
+			Expect(os.MkdirAll(filepath.Join(cacheDir, "chrome", "linux-121.0.6167.85"), os.ModePerm)).To(Succeed())
+			Expect(fingerprint()).NotTo(Equal(before))
+
+			before = fingerprint()
+			Expect(os.Setenv("PUPPETEER_CACHE_DIR", filepath.Join(rootDir, "other"))).To(Succeed())
+			Expect(fingerprint()).NotTo(Equal(before))
+		})
+
+		it("changes with the metadata of the node_modules layer", func() {
+			before := fingerprint()
+			Expect(os.WriteFile(filepath.Join(rootDir, "paketo-buildpacks_npm-install", "modules.toml"), []byte("[metadata]\ncache_sha = \"def\"\n"), 0600)).To(Succeed())
+			Expect(fingerprint()).NotTo(Equal(before))
+		})
+
+		it("changes with the version of the generator", func() {
+			before := fingerprint()
+			generator.Version = "3.0.4"
+			Expect(fingerprint()).NotTo(Equal(before))
+		})
+
+		it("changes with the configuration", func() {
+			before := fingerprint()
+			Expect(os.Setenv("BP_NODE_MODULE_BOM_SOME_SETTING", "true")).To(Succeed())
+			Expect(fingerprint()).NotTo(Equal(before))
+		})
+
+		context("when the context is done", func() {
+			it("stops walking the installed tree", func() {
+				ctx, cancel := gocontext.WithCancel(gocontext.Background())
+				cancel()
+
+				_, err := nodemodulebom.Fingerprint(ctx, layersDir, workingDir, []string{workingDir}, generator, "1.2.3")
+				Expect(err).To(MatchError(gocontext.Canceled))
+			})
+		})
+	})
+
+	context("ReadCachedBOM and WriteCachedBOM", func() {
+		var layerPath string
+
+		it.Before(func() {
+			dir, err := os.MkdirTemp("", "layers")
+			Expect(err).NotTo(HaveOccurred())
+
+			layerPath = filepath.Join(dir, "node-module-bom-cache")
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(filepath.Dir(layerPath))).To(Succeed())
+		})
+
+		it("reads back the BOM that was written", func() {
+			bom := nodemodulebom.BOM{
+				BOMFormat:   "CycloneDX",
+				SpecVersion: "1.5",
+				Version:     1,
+				Metadata: nodemodulebom.Metadata{
+					Component: &nodemodulebom.Component{BOMRef: "pkg:npm/some-app@1.0.0", Type: "application", Name: "some-app", Version: "1.0.0"},
+				},
+				Components: []nodemodulebom.Component{
+					{BOMRef: "pkg:npm/leftpad@0.0.1", Type: "library", Name: "leftpad", Version: "0.0.1"},
+				},
+				Dependencies: []nodemodulebom.Dependency{
+					{Ref: "pkg:npm/some-app@1.0.0", DependsOn: []string{"pkg:npm/leftpad@0.0.1"}},
+				},
+			}
+
+			Expect(nodemodulebom.WriteCachedBOM(layerPath, bom)).To(Succeed())
+
+			cached, ok, err := nodemodulebom.ReadCachedBOM(layerPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(cached).To(Equal(bom))
+		})
+
+		it("returns false when there is no cached BOM", func() {
+			_, ok, err := nodemodulebom.ReadCachedBOM(layerPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+
+		context("failure cases", func() {
+			it("returns an error when the cached BOM is malformed", func() {
+				Expect(os.MkdirAll(layerPath, os.ModePerm)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(layerPath, "bom.json"), []byte(`%%%`), 0600)).To(Succeed())
+
+				_, _, err := nodemodulebom.ReadCachedBOM(layerPath)
+				Expect(err).To(MatchError(ContainSubstring("failed to decode cached BOM")))
+			})
+		})
+	})
+}
@@ -0,0 +1,398 @@
+package nodemodulebom
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// SPDXVersion is the version of the SPDX specification that the SPDX
+	// rendering of a BOM conforms to.
+	SPDXVersion = "SPDX-2.3"
+
+	// spdxNoAssertion is the SPDX value for information that was not
+	// determined.
+	spdxNoAssertion = "NOASSERTION"
+)
+
+// SPDXDocument is an SPDX document describing the node modules of an
+// application.
+type SPDXDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      SPDXCreationInfo   `json:"creationInfo"`
+	Packages          []SPDXPackage      `json:"packages"`
+	Relationships     []SPDXRelationship `json:"relationships,omitempty"`
+
+	HasExtractedLicensingInfos []SPDXExtractedLicensingInfo `json:"hasExtractedLicensingInfos,omitempty"`
+}
+
+// SPDXCreationInfo records when and by which tools an SPDX document was
+// created.
+type SPDXCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+	Comment  string   `json:"comment,omitempty"`
+}
+
+// SPDXPackage is an SPDX package describing a single component of the BOM.
+type SPDXPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Checksums        []SPDXChecksum    `json:"checksums,omitempty"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	ExternalRefs     []SPDXExternalRef `json:"externalRefs,omitempty"`
+}
+
+// SPDXChecksum is the checksum of an SPDX package.
+type SPDXChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+// SPDXExternalRef is a reference from an SPDX package to an external
+// identifier, such as its purl.
+type SPDXExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+// SPDXExtractedLicensingInfo declares a license that is not on the SPDX
+// License List, which license expressions of the document refer to by its
+// LicenseRef- identifier.
+type SPDXExtractedLicensingInfo struct {
+	LicenseID     string `json:"licenseId"`
+	ExtractedText string `json:"extractedText"`
+	Name          string `json:"name,omitempty"`
+}
+
+// SPDXRelationship relates two SPDX elements, such as a package and the
+// package that it depends on.
+type SPDXRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// SPDX renders the BOM as an SPDX document. The metadata component becomes
+// the package that the document describes, and the dependency graph becomes
+// DEPENDS_ON relationships. The document namespace is derived from the
+// contents of the BOM, so that the same BOM always renders the same document.
+func (b BOM) SPDX() (SPDXDocument, error) {
+	content, err := json.Marshal(b)
+	if err != nil {
+		return SPDXDocument{}, fmt.Errorf("failed to encode BOM: %w", err)
+	}
+
+	created := b.Metadata.Timestamp
+	if created == "" {
+		created = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	name := "node-modules"
+	if b.Metadata.Component != nil && b.Metadata.Component.Name != "" {
+		name = b.Metadata.Component.PackageName()
+	}
+
+	document := SPDXDocument{
+		SPDXVersion:       SPDXVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: fmt.Sprintf("https://paketo.io/spdx/node-module-bom/%s-%x", escapePURL(name, ""), sha256.Sum256(content)),
+		CreationInfo: SPDXCreationInfo{
+			Created:  created,
+			Creators: []string{"Organization: Paketo Buildpacks", "Tool: node-module-bom"},
+		},
+	}
+
+	for _, property := range b.Metadata.Properties {
+		if property.Name == IncompleteProperty {
+			document.CreationInfo.Comment = fmt.Sprintf("This SBOM is incomplete: %s.", property.Value)
+		}
+	}
+
+	for _, tool := range b.Metadata.Tools {
+		creator := "Tool: " + tool.Name
+		if tool.Version != "" {
+			creator += "-" + tool.Version
+		}
+		document.CreationInfo.Creators = append(document.CreationInfo.Creators, creator)
+	}
+
+	ids := map[string]string{}
+	used := map[string]bool{}
+	licenses := newSPDXLicenses()
+	add := func(component Component) string {
+		id := spdxID(component, used)
+		if component.BOMRef != "" {
+			ids[component.BOMRef] = id
+		}
+
+		document.Packages = append(document.Packages, spdxPackage(id, component, licenses))
+		return id
+	}
+
+	if b.Metadata.Component != nil {
+		root := add(*b.Metadata.Component)
+		document.Relationships = append(document.Relationships, SPDXRelationship{
+			SPDXElementID:      document.SPDXID,
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: root,
+		})
+	}
+
+	for _, component := range b.Components {
+		id := add(component)
+		if b.Metadata.Component == nil {
+			document.Relationships = append(document.Relationships, SPDXRelationship{
+				SPDXElementID:      document.SPDXID,
+				RelationshipType:   "DESCRIBES",
+				RelatedSPDXElement: id,
+			})
+		}
+	}
+
+	for _, dependency := range b.Dependencies {
+		from, ok := ids[dependency.Ref]
+		if !ok {
+			continue
+		}
+
+		for _, ref := range dependency.DependsOn {
+			to, ok := ids[ref]
+			if !ok {
+				continue
+			}
+
+			document.Relationships = append(document.Relationships, SPDXRelationship{
+				SPDXElementID:      from,
+				RelationshipType:   "DEPENDS_ON",
+				RelatedSPDXElement: to,
+			})
+		}
+	}
+
+	document.HasExtractedLicensingInfos = licenses.infos
+
+	return document, nil
+}
+
+// spdxID returns a unique SPDX identifier for the component, derived from its
+// bom-ref or name. SPDX identifiers may only contain letters, numbers, "."
+// and "-".
+func spdxID(component Component, used map[string]bool) string {
+	source := component.BOMRef
+	if source == "" {
+		source = component.PackageName() + "-" + component.Version
+	}
+
+	var builder strings.Builder
+	builder.WriteString("SPDXRef-")
+	for _, r := range source {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '.', r == '-':
+			builder.WriteRune(r)
+		default:
+			builder.WriteByte('-')
+		}
+	}
+
+	id := builder.String()
+	for i := 2; used[id]; i++ {
+		id = fmt.Sprintf("%s-%d", builder.String(), i)
+	}
+	used[id] = true
+
+	return id
+}
+
+func spdxPackage(id string, component Component, licenses *spdxLicenses) SPDXPackage {
+	pkg := SPDXPackage{
+		SPDXID:           id,
+		Name:             component.PackageName(),
+		VersionInfo:      component.Version,
+		DownloadLocation: spdxNoAssertion,
+		LicenseConcluded: spdxNoAssertion,
+		LicenseDeclared:  licenses.expression(component.Licenses),
+		CopyrightText:    spdxNoAssertion,
+	}
+
+	if component.Copyright != "" {
+		pkg.CopyrightText = component.Copyright
+	}
+
+	for _, hash := range component.Hashes {
+		// CycloneDX spells SHA-2 algorithms "SHA-256" where SPDX spells them
+		// "SHA256"; the other algorithms are spelled the same.
+		algorithm := hash.Algorithm
+		if strings.HasPrefix(algorithm, "SHA-") {
+			algorithm = strings.Replace(algorithm, "-", "", 1)
+		}
+
+		pkg.Checksums = append(pkg.Checksums, SPDXChecksum{
+			Algorithm:     algorithm,
+			ChecksumValue: hash.Content,
+		})
+	}
+
+	if component.PURL != "" {
+		pkg.ExternalRefs = append(pkg.ExternalRefs, SPDXExternalRef{
+			ReferenceCategory: "PACKAGE-MANAGER",
+			ReferenceType:     "purl",
+			ReferenceLocator:  component.PURL,
+		})
+	}
+
+	return pkg
+}
+
+// spdxLicenses renders the licenses of components as SPDX license
+// expressions of a document, collecting the licenses that are not on the SPDX
+// License List as extracted licensing infos of the document.
+type spdxLicenses struct {
+	refs  map[string]string
+	used  map[string]bool
+	infos []SPDXExtractedLicensingInfo
+}
+
+func newSPDXLicenses() *spdxLicenses {
+	return &spdxLicenses{
+		refs: map[string]string{},
+		used: map[string]bool{},
+	}
+}
+
+// expression combines the licenses into an SPDX license expression.
+// Identifiers of the SPDX License List are written as the list spells them.
+// Any other license, such as "UNLICENSED", a license name or a value that is
+// not a valid SPDX expression, becomes a LicenseRef- of its own. Without
+// licenses, or with a license that only points to a file, as "SEE LICENSE IN
+// LICENSE.txt" does, the expression is NOASSERTION.
+func (l *spdxLicenses) expression(licenses []LicenseChoice) string {
+	var expressions []licenseExpression
+	for _, choice := range licenses {
+		value := strings.TrimSpace(choice.value())
+
+		if value == "" {
+			continue
+		}
+
+		if strings.HasPrefix(strings.ToUpper(value), "SEE LICENSE IN ") {
+			return spdxNoAssertion
+		}
+
+		expression, err := parseLicenseExpression(value)
+		if err != nil {
+			expressions = append(expressions, licenseExpression{License: l.ref(value)})
+			continue
+		}
+		expressions = append(expressions, l.listed(expression))
+	}
+
+	switch len(expressions) {
+	case 0:
+		return spdxNoAssertion
+	case 1:
+		return expressions[0].String()
+	}
+
+	return licenseExpression{Operator: "AND", Operands: expressions}.String()
+}
+
+// listed returns the expression with the identifiers of the SPDX License List
+// spelled as the list does and the other licenses replaced by a LicenseRef-.
+// A license with an exception that is not on the list is replaced as a whole.
+func (l *spdxLicenses) listed(expression licenseExpression) licenseExpression {
+	if expression.Operator != "" {
+		var operands []licenseExpression
+		for _, operand := range expression.Operands {
+			operands = append(operands, l.listed(operand))
+		}
+
+		return licenseExpression{Operator: expression.Operator, Operands: operands}
+	}
+
+	license, ok := listedLicenseID(expression.License)
+	if !ok {
+		if strings.HasPrefix(expression.License, "LicenseRef-") && expression.Exception == "" {
+			return licenseExpression{License: l.declare(expression.License, expression.License)}
+		}
+
+		return licenseExpression{License: l.ref(expression.String())}
+	}
+
+	if expression.Exception == "" {
+		return licenseExpression{License: license}
+	}
+
+	exception, ok := listedExceptionID(expression.Exception)
+	if !ok {
+		return licenseExpression{License: l.ref(expression.String())}
+	}
+
+	return licenseExpression{License: license, Exception: exception}
+}
+
+// ref returns the LicenseRef- of the license of the given text, made of the
+// characters of the text that SPDX identifiers allow.
+func (l *spdxLicenses) ref(text string) string {
+	if ref, ok := l.refs[text]; ok {
+		return ref
+	}
+
+	var builder strings.Builder
+	dash := false
+	for _, r := range text {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '.':
+			builder.WriteRune(r)
+			dash = false
+		case !dash && builder.Len() > 0:
+			builder.WriteByte('-')
+			dash = true
+		}
+	}
+
+	name := strings.TrimSuffix(builder.String(), "-")
+	if name == "" {
+		name = "unknown"
+	}
+
+	return l.declare("LicenseRef-"+name, text)
+}
+
+// declare records the extracted licensing info of the LicenseRef- for the
+// given text, numbering the identifier when another text already took it.
+func (l *spdxLicenses) declare(ref, text string) string {
+	if existing, ok := l.refs[text]; ok {
+		return existing
+	}
+
+	id := ref
+	for i := 2; l.used[id]; i++ {
+		id = fmt.Sprintf("%s-%d", ref, i)
+	}
+	l.used[id] = true
+	l.refs[text] = id
+
+	l.infos = append(l.infos, SPDXExtractedLicensingInfo{
+		LicenseID:     id,
+		ExtractedText: text,
+		Name:          text,
+	})
+
+	return id
+}
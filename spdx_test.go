@@ -0,0 +1,254 @@
+package nodemodulebom_test
+
+import (
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testSPDX(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		bom nodemodulebom.BOM
+	)
+
+	it.Before(func() {
+		bom = nodemodulebom.BOM{
+			BOMFormat:   "CycloneDX",
+			SpecVersion: "1.5",
+			Version:     1,
+			Metadata: nodemodulebom.Metadata{
+				Timestamp: "2021-08-16T19:35:52.107Z",
+				Tools: []nodemodulebom.Tool{
+					{Vendor: "CycloneDX", Name: "Node.js module", Version: "3.0.3"},
+				},
+				Component: &nodemodulebom.Component{
+					BOMRef:  "pkg:npm/my-app@1.0.0",
+					Type:    "application",
+					Name:    "my-app",
+					Version: "1.0.0",
+					PURL:    "pkg:npm/my-app@1.0.0",
+				},
+			},
+			Components: []nodemodulebom.Component{
+				{
+					BOMRef:  "pkg:npm/leftpad@0.0.1",
+					Type:    "library",
+					Name:    "leftpad",
+					Version: "0.0.1",
+					PURL:    "pkg:npm/leftpad@0.0.1",
+					Hashes: []nodemodulebom.Hash{
+						{Algorithm: "SHA-1", Content: "86b1a4de4face180ac545a83f1503523d8fed115"},
+						{Algorithm: "SHA-512", Content: "abcdef"},
+					},
+					Licenses: []nodemodulebom.LicenseChoice{
+						{License: &nodemodulebom.License{ID: "BSD-3-Clause"}},
+						{Expression: "MIT OR Apache-2.0"},
+					},
+					Copyright: "Copyright (c) 2015 Some Author\nCopyright 2016 Other Author",
+				},
+				{
+					BOMRef:  "pkg:npm/%40scope/rightpad@1.0.0",
+					Type:    "library",
+					Group:   "@scope",
+					Name:    "rightpad",
+					Version: "1.0.0",
+					PURL:    "pkg:npm/%40scope/rightpad@1.0.0",
+					Licenses: []nodemodulebom.LicenseChoice{
+						{License: &nodemodulebom.License{Name: "SEE LICENSE IN LICENSE.txt"}},
+					},
+				},
+			},
+			Dependencies: []nodemodulebom.Dependency{
+				{Ref: "pkg:npm/my-app@1.0.0", DependsOn: []string{"pkg:npm/leftpad@0.0.1"}},
+				{Ref: "pkg:npm/leftpad@0.0.1", DependsOn: []string{"pkg:npm/%40scope/rightpad@1.0.0"}},
+				{Ref: "pkg:npm/%40scope/rightpad@1.0.0"},
+			},
+		}
+	})
+
+	context("SPDX", func() {
+		it("renders the BOM as an SPDX document", func() {
+			document, err := bom.SPDX()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(document.DocumentNamespace).To(MatchRegexp(`^https://paketo\.io/spdx/node-module-bom/my-app-[0-9a-f]{64}$`))
+			document.DocumentNamespace = ""
+
+			Expect(document).To(Equal(nodemodulebom.SPDXDocument{
+				SPDXVersion: "SPDX-2.3",
+				DataLicense: "CC0-1.0",
+				SPDXID:      "SPDXRef-DOCUMENT",
+				Name:        "my-app",
+				CreationInfo: nodemodulebom.SPDXCreationInfo{
+					Created: "2021-08-16T19:35:52.107Z",
+					Creators: []string{
+						"Organization: Paketo Buildpacks",
+						"Tool: node-module-bom",
+						"Tool: Node.js module-3.0.3",
+					},
+				},
+				Packages: []nodemodulebom.SPDXPackage{
+					{
+						SPDXID:           "SPDXRef-pkg-npm-my-app-1.0.0",
+						Name:             "my-app",
+						VersionInfo:      "1.0.0",
+						DownloadLocation: "NOASSERTION",
+						LicenseConcluded: "NOASSERTION",
+						LicenseDeclared:  "NOASSERTION",
+						CopyrightText:    "NOASSERTION",
+						ExternalRefs: []nodemodulebom.SPDXExternalRef{
+							{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: "pkg:npm/my-app@1.0.0"},
+						},
+					},
+					{
+						SPDXID:           "SPDXRef-pkg-npm-leftpad-0.0.1",
+						Name:             "leftpad",
+						VersionInfo:      "0.0.1",
+						DownloadLocation: "NOASSERTION",
+						Checksums: []nodemodulebom.SPDXChecksum{
+							{Algorithm: "SHA1", ChecksumValue: "86b1a4de4face180ac545a83f1503523d8fed115"},
+							{Algorithm: "SHA512", ChecksumValue: "abcdef"},
+						},
+						LicenseConcluded: "NOASSERTION",
+						LicenseDeclared:  "BSD-3-Clause AND (MIT OR Apache-2.0)",
+						CopyrightText:    "Copyright (c) 2015 Some Author\nCopyright 2016 Other Author",
+						ExternalRefs: []nodemodulebom.SPDXExternalRef{
+							{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: "pkg:npm/leftpad@0.0.1"},
+						},
+					},
+					{
+						SPDXID:           "SPDXRef-pkg-npm--40scope-rightpad-1.0.0",
+						Name:             "@scope/rightpad",
+						VersionInfo:      "1.0.0",
+						DownloadLocation: "NOASSERTION",
+						LicenseConcluded: "NOASSERTION",
+						LicenseDeclared:  "NOASSERTION",
+						CopyrightText:    "NOASSERTION",
+						ExternalRefs: []nodemodulebom.SPDXExternalRef{
+							{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: "pkg:npm/%40scope/rightpad@1.0.0"},
+						},
+					},
+				},
+				Relationships: []nodemodulebom.SPDXRelationship{
+					{SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: "SPDXRef-pkg-npm-my-app-1.0.0"},
+					{SPDXElementID: "SPDXRef-pkg-npm-my-app-1.0.0", RelationshipType: "DEPENDS_ON", RelatedSPDXElement: "SPDXRef-pkg-npm-leftpad-0.0.1"},
+					{SPDXElementID: "SPDXRef-pkg-npm-leftpad-0.0.1", RelationshipType: "DEPENDS_ON", RelatedSPDXElement: "SPDXRef-pkg-npm--40scope-rightpad-1.0.0"},
+				},
+			}))
+		})
+
+		it("renders the same document for the same BOM", func() {
+			first, err := bom.SPDX()
+			Expect(err).NotTo(HaveOccurred())
+
+			second, err := bom.SPDX()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second).To(Equal(first))
+		})
+
+		context("when components declare licenses by name", func() {
+			it("declares the licenses of the SPDX License List and refers to the others", func() {
+				for license, declared := range map[string]string{
+					"MIT":                         "MIT",
+					"(mit or Apache-2.0) and ISC": "(MIT OR Apache-2.0) AND ISC",
+					"GPL-2.0-or-later WITH Classpath-exception-2.0": "GPL-2.0-or-later WITH Classpath-exception-2.0",
+					"GPL-2.0+":                         "GPL-2.0+",
+					"LicenseRef-Proprietary":           "LicenseRef-Proprietary",
+					"UNLICENSED":                       "LicenseRef-UNLICENSED",
+					"MIT OR Custom":                    "MIT OR LicenseRef-Custom",
+					"Apache-2.0 WITH Custom-exception": "LicenseRef-Apache-2.0-WITH-Custom-exception",
+					"MIT © Jane Doe":                   "LicenseRef-MIT-Jane-Doe",
+					"Licensed MIT, (c) 2020 Foo":       "LicenseRef-Licensed-MIT-c-2020-Foo",
+					"GNU General Public License v3.0":  "LicenseRef-GNU-General-Public-License-v3.0",
+					"(MIT OR":                          "LicenseRef-MIT-OR",
+					"SEE LICENSE IN LICENSE.txt":       "NOASSERTION",
+				} {
+					bom.Components[1].Licenses = []nodemodulebom.LicenseChoice{{License: &nodemodulebom.License{Name: license}}}
+
+					document, err := bom.SPDX()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(document.Packages[2].LicenseDeclared).To(Equal(declared), license)
+				}
+			})
+
+			it("declares the extracted licensing info of the licenses that are not on the SPDX License List", func() {
+				bom.Components[0].Licenses = []nodemodulebom.LicenseChoice{{License: &nodemodulebom.License{ID: "UNLICENSED"}}}
+				bom.Components[1].Licenses = []nodemodulebom.LicenseChoice{
+					{License: &nodemodulebom.License{Name: "Acme Commercial License"}},
+					{License: &nodemodulebom.License{ID: "UNLICENSED"}},
+				}
+
+				document, err := bom.SPDX()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(document.Packages[1].LicenseDeclared).To(Equal("LicenseRef-UNLICENSED"))
+				Expect(document.Packages[2].LicenseDeclared).To(Equal("LicenseRef-Acme-Commercial-License AND LicenseRef-UNLICENSED"))
+				Expect(document.HasExtractedLicensingInfos).To(Equal([]nodemodulebom.SPDXExtractedLicensingInfo{
+					{LicenseID: "LicenseRef-UNLICENSED", ExtractedText: "UNLICENSED", Name: "UNLICENSED"},
+					{LicenseID: "LicenseRef-Acme-Commercial-License", ExtractedText: "Acme Commercial License", Name: "Acme Commercial License"},
+				}))
+			})
+
+			it("gives licenses whose names look alike their own references", func() {
+				bom.Components[1].Licenses = []nodemodulebom.LicenseChoice{
+					{License: &nodemodulebom.License{Name: "Acme License"}},
+					{License: &nodemodulebom.License{Name: "Acme/License"}},
+				}
+
+				document, err := bom.SPDX()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(document.Packages[2].LicenseDeclared).To(Equal("LicenseRef-Acme-License AND LicenseRef-Acme-License-2"))
+			})
+		})
+
+		context("when the BOM is incomplete", func() {
+			it("says so in the creation info", func() {
+				document, err := bom.Incomplete("generation timed out after 1m0s").SPDX()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(document.CreationInfo.Comment).To(Equal("This SBOM is incomplete: generation timed out after 1m0s."))
+			})
+		})
+
+		context("when the BOM has no metadata component", func() {
+			it.Before(func() {
+				bom.Metadata.Component = nil
+				bom.Components = append(bom.Components, nodemodulebom.Component{
+					Type:    "library",
+					Name:    "leftpad",
+					Version: "0.0.1",
+				}, nodemodulebom.Component{
+					Type:    "library",
+					Name:    "leftpad",
+					Version: "0.0.1",
+				})
+			})
+
+			it("describes every component and gives each a unique ID", func() {
+				document, err := bom.SPDX()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(document.Name).To(Equal("node-modules"))
+
+				var ids []string
+				for _, pkg := range document.Packages {
+					ids = append(ids, pkg.SPDXID)
+				}
+				Expect(ids).To(Equal([]string{
+					"SPDXRef-pkg-npm-leftpad-0.0.1",
+					"SPDXRef-pkg-npm--40scope-rightpad-1.0.0",
+					"SPDXRef-leftpad-0.0.1",
+					"SPDXRef-leftpad-0.0.1-2",
+				}))
+
+				Expect(document.Relationships).To(ContainElements(
+					nodemodulebom.SPDXRelationship{SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: "SPDXRef-pkg-npm-leftpad-0.0.1"},
+					nodemodulebom.SPDXRelationship{SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: "SPDXRef-leftpad-0.0.1-2"},
+				))
+			})
+		})
+	})
+}
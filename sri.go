@@ -0,0 +1,69 @@
+package nodemodulebom
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+var sriAlgorithms = map[string]string{
+	"sha1":   "SHA-1",
+	"sha256": "SHA-256",
+	"sha384": "SHA-384",
+	"sha512": "SHA-512",
+}
+
+// ParseIntegrity decodes a Subresource Integrity value, such as the
+// "integrity" field of a lockfile, into hex-encoded hashes. The value may
+// contain multiple space-separated hashes, each of which is returned. Hashes
+// that use an unknown algorithm or that are malformed are ignored, as they
+// are by the SRI specification.
+func ParseIntegrity(integrity string) []Hash {
+	var hashes []Hash
+	for _, metadata := range strings.Fields(integrity) {
+		algorithm, digest, ok := strings.Cut(metadata, "-")
+		if !ok {
+			continue
+		}
+
+		algorithm, ok = sriAlgorithms[strings.ToLower(algorithm)]
+		if !ok {
+			continue
+		}
+
+		// Options follow the digest after a "?" and are not part of it.
+		digest, _, _ = strings.Cut(digest, "?")
+
+		content, err := base64.StdEncoding.DecodeString(digest)
+		if err != nil {
+			continue
+		}
+
+		hashes = append(hashes, Hash{
+			Algorithm: algorithm,
+			Content:   hex.EncodeToString(content),
+		})
+	}
+
+	return hashes
+}
+
+// mergeHashes appends the additional hashes that are not already in the given
+// set of hashes.
+func mergeHashes(hashes []Hash, additional ...Hash) []Hash {
+	for _, hash := range additional {
+		var found bool
+		for _, existing := range hashes {
+			if strings.EqualFold(existing.Algorithm, hash.Algorithm) && strings.EqualFold(existing.Content, hash.Content) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			hashes = append(hashes, hash)
+		}
+	}
+
+	return hashes
+}
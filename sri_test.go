@@ -0,0 +1,46 @@
+package nodemodulebom_test
+
+import (
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testSRI(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	context("ParseIntegrity", func() {
+		it("decodes the integrity into a hex-encoded hash", func() {
+			Expect(nodemodulebom.ParseIntegrity("sha512-N9DJPF4XpR4XKbuk9aoVh3B5ZO25/yqv+ZkqZTbuOXjOSp/BR7WzuuCy6Gzd5O7zPLWLNFKhPfrCv2xG67fYfA==")).To(Equal([]nodemodulebom.Hash{
+				{
+					Algorithm: "SHA-512",
+					Content:   "37d0c93c5e17a51e1729bba4f5aa1587707964edb9ff2aaff9992a6536ee3978ce4a9fc147b5b3bae0b2e86cdde4eef33cb58b3452a13dfac2bf6c46ebb7d87c",
+				},
+			}))
+		})
+
+		it("decodes every space-separated hash", func() {
+			Expect(nodemodulebom.ParseIntegrity("sha1-hrGk3k+s4YCsVFqD8VA1I9j+0RU=  sha384-11LCxR+6DimqGQVwqdQlPkQHegWNMpf6OlYw1b0BJiL5fCisrtMTtcg7uZDKp9qF?some-option")).To(Equal([]nodemodulebom.Hash{
+				{
+					Algorithm: "SHA-1",
+					Content:   "86b1a4de4face180ac545a83f1503523d8fed115",
+				},
+				{
+					Algorithm: "SHA-384",
+					Content:   "d752c2c51fba0e29aa190570a9d4253e44077a058d3297fa3a5630d5bd012622f97c28acaed313b5c83bb990caa7da85",
+				},
+			}))
+		})
+
+		it("ignores unknown algorithms and malformed hashes", func() {
+			Expect(nodemodulebom.ParseIntegrity("md5-AAAA sha256-%%% nonsense")).To(BeEmpty())
+		})
+
+		it("returns nothing for an empty integrity", func() {
+			Expect(nodemodulebom.ParseIntegrity("")).To(BeEmpty())
+		})
+	})
+}
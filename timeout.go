@@ -0,0 +1,99 @@
+package nodemodulebom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// TimeoutEnv names the environment variable that holds the time budget
+	// for generating the SBOM, as a Go duration such as "5m". A budget of 0
+	// disables the timeout.
+	TimeoutEnv = "BP_NODE_MODULE_BOM_TIMEOUT"
+
+	// OnTimeoutEnv names the environment variable that holds what to do when
+	// generating the SBOM takes longer than its budget: OnTimeoutFail or
+	// OnTimeoutWarn.
+	OnTimeoutEnv = "BP_NODE_MODULE_BOM_ON_TIMEOUT"
+
+	// DefaultTimeout is the time budget for generating the SBOM when
+	// BP_NODE_MODULE_BOM_TIMEOUT is not set.
+	DefaultTimeout = 10 * time.Minute
+
+	// OnTimeoutFail fails the build when generating the SBOM times out.
+	OnTimeoutFail = "fail"
+
+	// OnTimeoutWarn continues the build with an SBOM that is marked as
+	// incomplete when generating the SBOM times out.
+	OnTimeoutWarn = "warn"
+)
+
+// TimeoutPolicy is the time budget for generating the SBOM and what to do
+// when it runs out.
+type TimeoutPolicy struct {
+	Timeout   time.Duration
+	OnTimeout string
+}
+
+// LoadTimeoutPolicy reads the TimeoutPolicy from BP_NODE_MODULE_BOM_TIMEOUT and
+// BP_NODE_MODULE_BOM_ON_TIMEOUT, defaulting to DefaultTimeout and
+// OnTimeoutFail.
+func LoadTimeoutPolicy() (TimeoutPolicy, error) {
+	policy := TimeoutPolicy{
+		Timeout:   DefaultTimeout,
+		OnTimeout: OnTimeoutFail,
+	}
+
+	if str, ok := os.LookupEnv(TimeoutEnv); ok {
+		timeout, err := time.ParseDuration(str)
+		if err != nil {
+			return TimeoutPolicy{}, fmt.Errorf("failed to parse %s value %s: %w", TimeoutEnv, str, err)
+		}
+
+		if timeout < 0 {
+			return TimeoutPolicy{}, fmt.Errorf("failed to parse %s value %s: must not be negative", TimeoutEnv, str)
+		}
+
+		policy.Timeout = timeout
+	}
+
+	if str, ok := os.LookupEnv(OnTimeoutEnv); ok {
+		switch value := strings.ToLower(strings.TrimSpace(str)); value {
+		case OnTimeoutFail, OnTimeoutWarn:
+			policy.OnTimeout = value
+		default:
+			return TimeoutPolicy{}, fmt.Errorf("failed to parse %s value %s: must be %q or %q", OnTimeoutEnv, str, OnTimeoutFail, OnTimeoutWarn)
+		}
+	}
+
+	return policy, nil
+}
+
+// Context returns the context that generating the SBOM runs in. It is done
+// once the time budget runs out, or when the build is interrupted or
+// terminated, so that the generator is stopped rather than left running.
+func (p TimeoutPolicy) Context() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if p.Timeout == 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// IsTimeout returns whether the error is due to generating the SBOM taking
+// longer than its time budget.
+func IsTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,92 @@
+package nodemodulebom_test
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testTimeout(t *testing.T, context spec.G, it spec.S) {
+	var Expect = NewWithT(t).Expect
+
+	it.After(func() {
+		Expect(os.Unsetenv("BP_NODE_MODULE_BOM_TIMEOUT")).To(Succeed())
+		Expect(os.Unsetenv("BP_NODE_MODULE_BOM_ON_TIMEOUT")).To(Succeed())
+	})
+
+	context("LoadTimeoutPolicy", func() {
+		it("defaults to failing after 10 minutes", func() {
+			policy, err := nodemodulebom.LoadTimeoutPolicy()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy).To(Equal(nodemodulebom.TimeoutPolicy{Timeout: 10 * time.Minute, OnTimeout: "fail"}))
+		})
+
+		it("reads the timeout and what to do on timeout", func() {
+			Expect(os.Setenv("BP_NODE_MODULE_BOM_TIMEOUT", "90s")).To(Succeed())
+			Expect(os.Setenv("BP_NODE_MODULE_BOM_ON_TIMEOUT", "Warn")).To(Succeed())
+
+			policy, err := nodemodulebom.LoadTimeoutPolicy()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy).To(Equal(nodemodulebom.TimeoutPolicy{Timeout: 90 * time.Second, OnTimeout: "warn"}))
+		})
+
+		context("failure cases", func() {
+			it("returns an error when the timeout is not a duration", func() {
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_TIMEOUT", "ten minutes")).To(Succeed())
+
+				_, err := nodemodulebom.LoadTimeoutPolicy()
+				Expect(err).To(MatchError(ContainSubstring("failed to parse BP_NODE_MODULE_BOM_TIMEOUT value ten minutes")))
+			})
+
+			it("returns an error when the timeout is negative", func() {
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_TIMEOUT", "-1m")).To(Succeed())
+
+				_, err := nodemodulebom.LoadTimeoutPolicy()
+				Expect(err).To(MatchError("failed to parse BP_NODE_MODULE_BOM_TIMEOUT value -1m: must not be negative"))
+			})
+
+			it("returns an error when what to do on timeout is unknown", func() {
+				Expect(os.Setenv("BP_NODE_MODULE_BOM_ON_TIMEOUT", "ignore")).To(Succeed())
+
+				_, err := nodemodulebom.LoadTimeoutPolicy()
+				Expect(err).To(MatchError(`failed to parse BP_NODE_MODULE_BOM_ON_TIMEOUT value ignore: must be "fail" or "warn"`))
+			})
+		})
+	})
+
+	context("Context", func() {
+		it("is done once the timeout expires", func() {
+			ctx, cancel := nodemodulebom.TimeoutPolicy{Timeout: time.Millisecond}.Context()
+			defer cancel()
+
+			<-ctx.Done()
+			Expect(nodemodulebom.IsTimeout(ctx.Err())).To(BeTrue())
+		})
+
+		it("has no deadline when the timeout is 0", func() {
+			ctx, cancel := nodemodulebom.TimeoutPolicy{}.Context()
+
+			_, ok := ctx.Deadline()
+			Expect(ok).To(BeFalse())
+
+			cancel()
+			Expect(ctx.Err()).To(Equal(gocontext.Canceled))
+			Expect(nodemodulebom.IsTimeout(ctx.Err())).To(BeFalse())
+		})
+	})
+
+	context("IsTimeout", func() {
+		it("recognizes a wrapped timeout", func() {
+			Expect(nodemodulebom.IsTimeout(fmt.Errorf("failed to run cyclonedx-bom: %w", gocontext.DeadlineExceeded))).To(BeTrue())
+			Expect(nodemodulebom.IsTimeout(errors.New("some-error"))).To(BeFalse())
+		})
+	})
+}
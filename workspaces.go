@@ -0,0 +1,292 @@
+package nodemodulebom
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// FirstPartyProperty marks components that are part of the application
+	// rather than third-party dependencies.
+	FirstPartyProperty = "paketo:node-module-bom:first-party"
+
+	// WorkspaceProperty records the directory of a workspace package relative
+	// to the application.
+	WorkspaceProperty = "paketo:node-module-bom:workspace"
+)
+
+// Workspace is a package of an npm, Yarn or pnpm workspace (monorepo).
+type Workspace struct {
+	Name         string
+	Version      string
+	Path         string
+	Dependencies map[string]string
+}
+
+// FindWorkspaces returns the workspace packages of the application in the
+// given working directory. The workspaces are declared by the "workspaces"
+// field of the package.json, either as a list of globs or as an object with a
+// "packages" list, or by the "packages" list of pnpm-workspace.yaml.
+func FindWorkspaces(workingDir string) ([]Workspace, error) {
+	patterns, err := workspacePatterns(workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	var includes, excludes []string
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "./"), "/")
+		if strings.HasPrefix(pattern, "!") {
+			excludes = append(excludes, strings.TrimPrefix(strings.TrimPrefix(pattern, "!"), "./"))
+			continue
+		}
+		includes = append(includes, pattern)
+	}
+
+	var workspaces []Workspace
+	err = filepath.WalkDir(workingDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() || path == workingDir {
+			return nil
+		}
+
+		if entry.Name() == "node_modules" || strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(workingDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if !matchAnyGlob(includes, rel) || matchAnyGlob(excludes, rel) {
+			return nil
+		}
+
+		pkg, err := readPackageJSON(filepath.Join(path, "package.json"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		workspaces = append(workspaces, Workspace{
+			Name:         pkg.Name,
+			Version:      pkg.Version,
+			Path:         rel,
+			Dependencies: mergeDependencies(pkg.Dependencies, pkg.OptionalDependencies, pkg.DevDependencies),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find workspace packages: %w", err)
+	}
+
+	sort.Slice(workspaces, func(i, j int) bool {
+		return workspaces[i].Path < workspaces[j].Path
+	})
+
+	return workspaces, nil
+}
+
+func workspacePatterns(workingDir string) ([]string, error) {
+	content, err := os.ReadFile(filepath.Join(workingDir, "pnpm-workspace.yaml"))
+	if err == nil {
+		var config struct {
+			Packages []string `yaml:"packages"`
+		}
+
+		err = yaml.Unmarshal(content, &config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse pnpm-workspace.yaml: %w", err)
+		}
+
+		return config.Packages, nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read pnpm-workspace.yaml: %w", err)
+	}
+
+	content, err = os.ReadFile(filepath.Join(workingDir, "package.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+
+	var pkg struct {
+		Workspaces json.RawMessage `json:"workspaces"`
+	}
+
+	err = json.Unmarshal(content, &pkg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %w", err)
+	}
+
+	if len(pkg.Workspaces) == 0 {
+		return nil, nil
+	}
+
+	var patterns []string
+	if json.Unmarshal(pkg.Workspaces, &patterns) == nil {
+		return patterns, nil
+	}
+
+	var object struct {
+		Packages []string `json:"packages"`
+	}
+
+	err = json.Unmarshal(pkg.Workspaces, &object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: workspaces: %w", err)
+	}
+
+	return object.Packages, nil
+}
+
+func matchAnyGlob(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Ref returns the CycloneDX bom-ref that identifies the workspace package in
+// the dependency graph.
+func (w Workspace) Ref() string {
+	return PackageURL(w.packageName(), w.Version, nil)
+}
+
+// Component returns the first-party CycloneDX component describing the
+// workspace package.
+func (w Workspace) Component() Component {
+	group, name := splitPackageName(w.packageName())
+
+	return Component{
+		BOMRef:  w.Ref(),
+		Type:    "library",
+		Group:   group,
+		Name:    name,
+		Version: w.Version,
+		PURL:    PackageURL(w.packageName(), w.Version, nil),
+		Properties: []Property{
+			{Name: FirstPartyProperty, Value: "true"},
+			{Name: WorkspaceProperty, Value: w.Path},
+		},
+	}
+}
+
+// packageName returns the name of the workspace package. A workspace package
+// does not need a name, as it is never published, so the directory of one
+// without a name stands in for it, keeping its component apart from those of
+// the other workspace packages.
+func (w Workspace) packageName() string {
+	if w.Name == "" {
+		return w.Path
+	}
+
+	return w.Name
+}
+
+// workspaceComponents replaces any component that the generator reported for
+// a workspace package with the first-party component of the workspace.
+func workspaceComponents(components []Component, workspaces []Workspace) []Component {
+	names := map[string]bool{}
+	for _, workspace := range workspaces {
+		if workspace.Name != "" {
+			names[workspace.Name] = true
+		}
+	}
+
+	var result []Component
+	for _, component := range components {
+		if names[component.PackageName()] {
+			continue
+		}
+
+		result = append(result, component)
+	}
+
+	for _, workspace := range workspaces {
+		result = append(result, workspace.Component())
+	}
+
+	return result
+}
+
+// WriteWorkspaceSBOMs writes a CycloneDX SBOM for the sub-graph of every
+// workspace package of the BOM into the given directory, returning the paths
+// of the files that were written.
+func WriteWorkspaceSBOMs(dir string, bom BOM) ([]string, error) {
+	var paths []string
+	for _, component := range bom.Components {
+		if !hasProperty(component.Properties, WorkspaceProperty) {
+			continue
+		}
+
+		err := os.MkdirAll(dir, os.ModePerm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create workspace SBOM directory: %w", err)
+		}
+
+		content, err := json.MarshalIndent(bom.Subgraph(component.BOMRef), "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode workspace SBOM: %w", err)
+		}
+
+		// The name is escaped rather than flattened so that the file of a
+		// scoped package such as @acme/api-core cannot be confused with
+		// that of acme-api/core, and the package name can be read back.
+		path := filepath.Join(dir, fmt.Sprintf("%s.cdx.json", url.PathEscape(component.PackageName())))
+
+		err = os.WriteFile(path, content, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write workspace SBOM: %w", err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+func hasProperty(properties []Property, name string) bool {
+	_, ok := propertyValue(properties, name)
+	return ok
+}
+
+func propertyValue(properties []Property, name string) (string, bool) {
+	for _, property := range properties {
+		if property.Name == name {
+			return property.Value, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,267 @@
+package nodemodulebom_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	nodemodulebom "github.com/paketo-buildpacks/node-module-bom"
+	"github.com/sclevine/spec"
+
+	. "github.com/onsi/gomega"
+)
+
+func testWorkspaces(t *testing.T, context spec.G, it spec.S) {
+	var (
+		Expect = NewWithT(t).Expect
+
+		workingDir string
+	)
+
+	it.Before(func() {
+		var err error
+		workingDir, err = os.MkdirTemp("", "working-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		for path, content := range map[string]string{
+			"packages/api/package.json":              `{"name": "@acme/api", "version": "1.0.0", "dependencies": {"leftpad": "^1.0.0", "@acme/utils": "*"}}`,
+			"packages/utils/package.json":            `{"name": "@acme/utils", "version": "2.0.0", "devDependencies": {"rightpad": "^1.0.0"}}`,
+			"packages/utils/test/package.json":       `{"name": "@acme/utils-test", "version": "0.0.0"}`,
+			"packages/no-package-json/index.js":      ``,
+			"apps/web/nested/package.json":           `{"name": "web", "version": "3.0.0"}`,
+			"node_modules/@acme/api/package.json":    `{"name": "@acme/api", "version": "1.0.0"}`,
+			"packages/api/node_modules/package.json": `{"name": "not-a-workspace", "version": "1.0.0"}`,
+		} {
+			Expect(os.MkdirAll(filepath.Join(workingDir, filepath.Dir(path)), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(workingDir, path), []byte(content), 0600)).To(Succeed())
+		}
+
+		Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{
+			"name": "monorepo",
+			"workspaces": ["packages/*", "./apps/**/"]
+		}`), 0600)).To(Succeed())
+	})
+
+	it.After(func() {
+		Expect(os.RemoveAll(workingDir)).To(Succeed())
+	})
+
+	context("FindWorkspaces", func() {
+		it("returns the packages matching the package.json workspaces", func() {
+			workspaces, err := nodemodulebom.FindWorkspaces(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(workspaces).To(Equal([]nodemodulebom.Workspace{
+				{
+					Name:    "web",
+					Version: "3.0.0",
+					Path:    "apps/web/nested",
+				},
+				{
+					Name:         "@acme/api",
+					Version:      "1.0.0",
+					Path:         "packages/api",
+					Dependencies: map[string]string{"leftpad": "^1.0.0", "@acme/utils": "*"},
+				},
+				{
+					Name:         "@acme/utils",
+					Version:      "2.0.0",
+					Path:         "packages/utils",
+					Dependencies: map[string]string{"rightpad": "^1.0.0"},
+				},
+			}))
+		})
+
+		context("when the workspaces are declared as an object", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{
+					"workspaces": {"packages": ["packages/api"], "nohoist": ["**"]}
+				}`), 0600)).To(Succeed())
+			})
+
+			it("returns the packages matching the workspaces packages", func() {
+				workspaces, err := nodemodulebom.FindWorkspaces(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(workspaces).To(HaveLen(1))
+				Expect(workspaces[0].Name).To(Equal("@acme/api"))
+			})
+		})
+
+		context("when there is a pnpm-workspace.yaml", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-workspace.yaml"), []byte(`packages:
+  - 'packages/**'
+  - '!**/test/**'
+`), 0600)).To(Succeed())
+			})
+
+			it("returns the packages matching the pnpm workspace packages", func() {
+				workspaces, err := nodemodulebom.FindWorkspaces(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+
+				var names []string
+				for _, workspace := range workspaces {
+					names = append(names, workspace.Name)
+				}
+				Expect(names).To(Equal([]string{"@acme/api", "@acme/utils"}))
+			})
+		})
+
+		context("when the application has no workspaces", func() {
+			it.Before(func() {
+				Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"name": "some-app"}`), 0600)).To(Succeed())
+			})
+
+			it("returns no workspaces", func() {
+				workspaces, err := nodemodulebom.FindWorkspaces(workingDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(workspaces).To(BeEmpty())
+			})
+		})
+
+		context("failure cases", func() {
+			context("when the package.json cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`%%%`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := nodemodulebom.FindWorkspaces(workingDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse package.json")))
+				})
+			})
+
+			context("when the workspaces field is malformed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "package.json"), []byte(`{"workspaces": 1}`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := nodemodulebom.FindWorkspaces(workingDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse package.json: workspaces")))
+				})
+			})
+
+			context("when the pnpm-workspace.yaml cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "pnpm-workspace.yaml"), []byte(`packages: {`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := nodemodulebom.FindWorkspaces(workingDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to parse pnpm-workspace.yaml")))
+				})
+			})
+
+			context("when a workspace package.json cannot be parsed", func() {
+				it.Before(func() {
+					Expect(os.WriteFile(filepath.Join(workingDir, "packages", "api", "package.json"), []byte(`%%%`), 0600)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := nodemodulebom.FindWorkspaces(workingDir)
+					Expect(err).To(MatchError(ContainSubstring("failed to find workspace packages")))
+				})
+			})
+		})
+	})
+
+	context("WriteWorkspaceSBOMs", func() {
+		var (
+			outputDir string
+			bom       nodemodulebom.BOM
+		)
+
+		it.Before(func() {
+			var err error
+			outputDir, err = os.MkdirTemp("", "output")
+			Expect(err).NotTo(HaveOccurred())
+
+			workspaces, err := nodemodulebom.FindWorkspaces(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			bom = nodemodulebom.BOM{
+				BOMFormat:   "CycloneDX",
+				SpecVersion: "1.5",
+				Version:     1,
+				Components: []nodemodulebom.Component{
+					workspaces[1].Component(),
+					{BOMRef: "pkg:npm/leftpad@1.0.0", Type: "library", Name: "leftpad", Version: "1.0.0"},
+				},
+				Dependencies: []nodemodulebom.Dependency{
+					{Ref: "pkg:npm/%40acme/api@1.0.0", DependsOn: []string{"pkg:npm/leftpad@1.0.0"}},
+					{Ref: "pkg:npm/leftpad@1.0.0"},
+				},
+			}
+		})
+
+		it.After(func() {
+			Expect(os.RemoveAll(outputDir)).To(Succeed())
+		})
+
+		it("writes an SBOM for each workspace package", func() {
+			paths, err := nodemodulebom.WriteWorkspaceSBOMs(filepath.Join(outputDir, "workspaces"), bom)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{filepath.Join(outputDir, "workspaces", "@acme%2Fapi.cdx.json")}))
+
+			content, err := os.ReadFile(paths[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			var sbom nodemodulebom.BOM
+			Expect(json.Unmarshal(content, &sbom)).To(Succeed())
+			Expect(sbom.Metadata.Component.PackageName()).To(Equal("@acme/api"))
+			Expect(sbom.Components).To(Equal([]nodemodulebom.Component{
+				{BOMRef: "pkg:npm/leftpad@1.0.0", Type: "library", Name: "leftpad", Version: "1.0.0"},
+			}))
+		})
+
+		it("writes distinct files for packages whose flattened names would collide", func() {
+			bom.Components = []nodemodulebom.Component{
+				nodemodulebom.Workspace{Name: "@acme/api-core", Version: "1.0.0", Path: "packages/api-core"}.Component(),
+				nodemodulebom.Workspace{Name: "acme-api-core", Version: "1.0.0", Path: "packages/legacy"}.Component(),
+			}
+			bom.Dependencies = nil
+
+			paths, err := nodemodulebom.WriteWorkspaceSBOMs(filepath.Join(outputDir, "workspaces"), bom)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{
+				filepath.Join(outputDir, "workspaces", "@acme%2Fapi-core.cdx.json"),
+				filepath.Join(outputDir, "workspaces", "acme-api-core.cdx.json"),
+			}))
+		})
+
+		it("writes distinct files for workspace packages without a name", func() {
+			api := nodemodulebom.Workspace{Version: "1.0.0", Path: "packages/api"}
+			web := nodemodulebom.Workspace{Version: "1.0.0", Path: "packages/web"}
+			Expect(api.Ref()).To(Equal("pkg:npm/packages%2Fapi@1.0.0"))
+			Expect(api.Ref()).NotTo(Equal(web.Ref()))
+
+			bom.Components = []nodemodulebom.Component{api.Component(), web.Component()}
+			bom.Dependencies = nil
+
+			paths, err := nodemodulebom.WriteWorkspaceSBOMs(filepath.Join(outputDir, "workspaces"), bom)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{
+				filepath.Join(outputDir, "workspaces", "packages%2Fapi.cdx.json"),
+				filepath.Join(outputDir, "workspaces", "packages%2Fweb.cdx.json"),
+			}))
+		})
+
+		context("failure cases", func() {
+			context("when the output directory cannot be created", func() {
+				it.Before(func() {
+					Expect(os.Chmod(outputDir, 0000)).To(Succeed())
+				})
+
+				it.After(func() {
+					Expect(os.Chmod(outputDir, os.ModePerm)).To(Succeed())
+				})
+
+				it("returns an error", func() {
+					_, err := nodemodulebom.WriteWorkspaceSBOMs(filepath.Join(outputDir, "workspaces"), bom)
+					Expect(err).To(MatchError(ContainSubstring("failed to create workspace SBOM directory")))
+				})
+			})
+		})
+	})
+}